@@ -1,17 +1,151 @@
 package database
 
 import (
+	"context"
 	"github.com/mgmu/hortus/internal/plants"
+	"time"
 )
 
 // Database defines the API to store and retrieve plants and related data from a
-// database.
+// database. Operations stop when their context is done.
 type Database interface {
-	Connect() error
+	Connect(ctx context.Context) error
 	Close() error
-	GetPlantsShortDescription() ([]plants.PlantShortDesc, error)
-	AddNewPlant(comm, gen, spe string) (int, error)
-	GetPlantNames(id int) (string, string, string, error)
-	GetPlantLogs(id int) ([]plants.PlantLog, error)
-	AddNewPlantLog(id int, desc string, event int) error
+	GetPlantsShortDescription(
+		ctx context.Context,
+		q PlantQuery,
+	) ([]plants.PlantShortDesc, error)
+	AddNewPlant(
+		ctx context.Context,
+		householdId int,
+		comm string,
+		taxonId int,
+	) (int, error)
+	GetPlantRole(ctx context.Context, userId, plantId int) (plants.Role, error)
+	GetPlantNames(ctx context.Context, id int) (string, plants.Taxon, error)
+	UpdatePlant(ctx context.Context, id int, comm string, taxonId int) error
+	DeletePlant(ctx context.Context, id int) error
+	GetPlantLogs(ctx context.Context, id int) ([]plants.PlantLog, error)
+	AddNewPlantLog(
+		ctx context.Context,
+		id int,
+		desc string,
+		event plants.EventType,
+		occurredAt time.Time,
+	) (int, error)
+	GetPlantLog(ctx context.Context, plantId, logId int) (plants.PlantLog, error)
+	UpdatePlantLog(
+		ctx context.Context,
+		plantId, logId int,
+		desc string,
+		event plants.EventType,
+		occurredAt time.Time,
+	) error
+	DeletePlantLog(ctx context.Context, plantId, logId int) error
+	Search(
+		ctx context.Context,
+		userId int,
+		query string,
+		limit int,
+	) ([]plants.SearchResult, error)
+	GetCareSchedules(ctx context.Context, plantId int) ([]plants.CareSchedule, error)
+	GetCareSchedule(
+		ctx context.Context,
+		plantId, scheduleId int,
+	) (plants.CareSchedule, error)
+	AddCareSchedule(ctx context.Context, s plants.CareSchedule) (int, error)
+	UpdateCareSchedule(ctx context.Context, s plants.CareSchedule) error
+	DeleteCareSchedule(ctx context.Context, plantId, scheduleId int) error
+	GetCareTasks(
+		ctx context.Context,
+		userId, plantId int,
+		now time.Time,
+	) ([]plants.CareTask, error)
+	AddPhoto(ctx context.Context, p plants.Photo) (int, error)
+	GetPhotos(ctx context.Context, plantId int) ([]plants.Photo, error)
+	GetPhoto(ctx context.Context, plantId, photoId int) (plants.Photo, error)
+	DeletePhoto(ctx context.Context, plantId, photoId int) error
+	AddUser(ctx context.Context, username, passwordHash string) (int, error)
+	GetUser(ctx context.Context, id int) (plants.User, error)
+	GetUserByName(ctx context.Context, username string) (plants.User, string, error)
+	AddToken(ctx context.Context, t plants.Token, hash string) (int, error)
+	GetTokenByHash(ctx context.Context, hash string) (plants.Token, error)
+	GetTokens(ctx context.Context, userId int) ([]plants.Token, error)
+	RevokeToken(ctx context.Context, userId, id int) error
+	AddHousehold(ctx context.Context, userId int, name string) (int, error)
+	GetHouseholds(ctx context.Context, userId int) ([]plants.Household, error)
+	GetHousehold(ctx context.Context, id int) (plants.Household, error)
+	UpdateHousehold(ctx context.Context, id int, name string) error
+	DeleteHousehold(ctx context.Context, id int) error
+	GetMemberRole(ctx context.Context, householdId, userId int) (plants.Role, error)
+	SetMemberRole(
+		ctx context.Context,
+		householdId, userId int,
+		role plants.Role,
+	) error
+	DeleteMember(ctx context.Context, householdId, userId int) error
+	AddInvitation(
+		ctx context.Context,
+		inv plants.Invitation,
+		hash string,
+	) (int, error)
+	GetInvitations(
+		ctx context.Context,
+		householdId int,
+		now time.Time,
+	) ([]plants.Invitation, error)
+	GetInvitationByHash(
+		ctx context.Context,
+		hash string,
+		now time.Time,
+	) (plants.Invitation, error)
+	AcceptInvitation(ctx context.Context, id, userId int, now time.Time) error
+	DeleteInvitation(ctx context.Context, householdId, id int) error
+	AddLocation(ctx context.Context, l plants.Location) (int, error)
+	GetLocations(ctx context.Context, userId int) ([]plants.Location, error)
+	GetLocation(ctx context.Context, id int) (plants.Location, error)
+	UpdateLocation(ctx context.Context, l plants.Location) error
+	DeleteLocation(ctx context.Context, id int) error
+	GetPlantLocation(
+		ctx context.Context,
+		plantId int,
+	) (int, plants.Location, error)
+	MovePlant(
+		ctx context.Context,
+		plantId, locationId int,
+		desc string,
+		occurredAt time.Time,
+	) (int, error)
+	AddPlantTags(ctx context.Context, plantId int, tags []string) error
+	RemovePlantTag(ctx context.Context, plantId int, tag string) error
+	GetPlantTags(ctx context.Context, plantId int) ([]string, error)
+	GetTagCounts(ctx context.Context, userId int) ([]plants.TagCount, error)
+	AddTaxon(ctx context.Context, t plants.Taxon) (int, error)
+	GetTaxon(ctx context.Context, id int) (plants.Taxon, error)
+	GetTaxa(ctx context.Context, q TaxonQuery) ([]plants.Taxon, error)
+	AddTaxa(ctx context.Context, taxa []plants.Taxon) (int, error)
+	GetTaxonNames(
+		ctx context.Context,
+		genus, prefix string,
+		limit int,
+	) ([]string, error)
+	GetPlants(ctx context.Context, userId int) ([]plants.Plant, error)
+	GetLogs(ctx context.Context, userId int) ([]plants.PlantLog, error)
+	AddPlants(
+		ctx context.Context,
+		householdId int,
+		ps []plants.Plant,
+	) ([]int, error)
+}
+
+// Returns a copy of ctx cancelled after timeout, or ctx itself if timeout is
+// not positive.
+func withTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
 }
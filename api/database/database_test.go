@@ -0,0 +1,394 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"github.com/mgmu/hortus/internal/plants"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Returns the databases the tests run against, by name: a new MemoryDatabase,
+// and a new SQLiteDatabase in a temporary file, migrated to the latest schema.
+func testDatabases(t *testing.T) map[string]Database {
+	t.Helper()
+	ctx := context.Background()
+	mem := &MemoryDatabase{}
+	err := mem.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HORTUS_SQLITE_PATH", filepath.Join(t.TempDir(), "hortus.db"))
+	lite := &SQLiteDatabase{}
+	err = lite.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lite.Close() })
+	_, err = lite.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Database{"memory": mem, "sqlite": lite}
+}
+
+func TestPlantsSortedByName(t *testing.T) {
+	names := []string{"Zeta", "alpha", "b", "Beta", "a", "Delta", "beta", "A"}
+	want := []string{"a", "A", "alpha", "b", "Beta", "beta", "Delta", "Zeta"}
+	ctx := context.Background()
+	for backend, db := range testDatabases(t) {
+		for _, name := range names {
+			_, err := db.AddNewPlant(ctx, 0, name, 0)
+			if err != nil {
+				t.Fatalf("%s: %v", backend, err)
+			}
+		}
+
+		for _, desc := range []bool{false, true} {
+			want := slices.Clone(want)
+			if desc {
+				slices.Reverse(want)
+			}
+			// Pages of 3 plants, each following the cursor of the last
+			// one. Equal names are ordered by identifier.
+			var got []string
+			q := PlantQuery{Sort: SortByName, Desc: desc, Limit: 3}
+			for range len(names) {
+				page, err := db.GetPlantsShortDescription(ctx, q)
+				if err != nil {
+					t.Fatalf("%s: %v", backend, err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				for _, p := range page {
+					got = append(got, p.CommonName)
+				}
+				last := page[len(page)-1]
+				q.After = &PlantCursor{Id: last.Id, Name: last.CommonName}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf(
+					"%s, desc %t: names = %q, want %q",
+					backend,
+					desc,
+					got,
+					want,
+				)
+			}
+		}
+	}
+}
+
+// Fills db with the households of two users, alice (1) and bob (2), and their
+// plants:
+//   - 1 "Monstera du salon", of alice, in the room 2 of the site 1, tagged
+//     "grande" and "intérieur"
+//   - 2 "Figuier", of alice, on the shelf 3 of the room 2, tagged "intérieur"
+//   - 3 "Basilic", of alice, in no location, tagged "cuisine"
+//   - 4 "Monstera de Bob", of bob, on the balcony 4, tagged "intérieur"
+//
+// The moves are the log entries 1 to 3, then Basilic has the log entry 4
+// "Récolte des feuilles", and Monstera de Bob the log entry 5 "Arrosage du
+// monstera".
+func seed(t *testing.T, db Database) {
+	t.Helper()
+	ctx := context.Background()
+	ids := func(id int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alice := ids(db.AddUser(ctx, "alice", "hash"))
+	bob := ids(db.AddUser(ctx, "bob", "hash"))
+	garden := ids(db.AddHousehold(ctx, alice, "Jardin"))
+	flat := ids(db.AddHousehold(ctx, bob, "Appartement"))
+	site := ids(db.AddLocation(ctx, plants.Location{
+		HouseholdId: garden,
+		Name:        "Maison",
+		Kind:        plants.LocationSite,
+	}))
+	room := ids(db.AddLocation(ctx, plants.Location{
+		HouseholdId: garden,
+		ParentId:    site,
+		Name:        "Salon",
+		Kind:        plants.LocationRoom,
+	}))
+	shelf := ids(db.AddLocation(ctx, plants.Location{
+		HouseholdId: garden,
+		ParentId:    room,
+		Name:        "Étagère",
+		Kind:        plants.LocationShelf,
+	}))
+	balcony := ids(db.AddLocation(ctx, plants.Location{
+		HouseholdId: flat,
+		Name:        "Balcon",
+		Kind:        plants.LocationSite,
+	}))
+	monstera := ids(db.AddTaxon(ctx, plants.Taxon{
+		Genus:   "Monstera",
+		Species: "deliciosa",
+	}))
+	ficus := ids(db.AddTaxon(ctx, plants.Taxon{
+		Genus:   "Ficus",
+		Species: "carica",
+	}))
+
+	now := time.Now()
+	for _, p := range []struct {
+		household int
+		name      string
+		taxon     int
+		location  int
+		tags      []string
+	}{
+		{
+			garden,
+			"Monstera du salon",
+			monstera,
+			room,
+			[]string{"grande", "intérieur"},
+		},
+		{garden, "Figuier", ficus, shelf, []string{"intérieur"}},
+		{garden, "Basilic", 0, 0, []string{"cuisine"}},
+		{flat, "Monstera de Bob", monstera, balcony, []string{"intérieur"}},
+	} {
+		id := ids(db.AddNewPlant(ctx, p.household, p.name, p.taxon))
+		if p.location != 0 {
+			ids(db.MovePlant(ctx, id, p.location, "", now))
+		}
+		check(db.AddPlantTags(ctx, id, p.tags))
+	}
+	ids(db.AddNewPlantLog(
+		ctx,
+		3,
+		"Récolte des feuilles",
+		plants.EventHarvest,
+		now,
+	))
+	ids(db.AddNewPlantLog(
+		ctx,
+		4,
+		"Arrosage du monstera",
+		plants.EventWatering,
+		now,
+	))
+}
+
+func TestPlantQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    PlantQuery
+		want []int
+	}{
+		{"all", PlantQuery{}, []int{1, 2, 3, 4}},
+		{"alice", PlantQuery{UserId: 1}, []int{1, 2, 3}},
+		{"bob", PlantQuery{UserId: 2}, []int{4}},
+		{"unknown user", PlantQuery{UserId: 3}, []int{}},
+		{"site", PlantQuery{LocationId: 1}, []int{1, 2}},
+		{"room", PlantQuery{LocationId: 2}, []int{1, 2}},
+		{"shelf", PlantQuery{LocationId: 3}, []int{2}},
+		{"bob in site", PlantQuery{UserId: 2, LocationId: 1}, []int{}},
+		{"tag", PlantQuery{Tags: []string{"intérieur"}}, []int{1, 2, 4}},
+		{
+			"all tags",
+			PlantQuery{Tags: []string{"intérieur", "grande"}},
+			[]int{1},
+		},
+		{
+			"any tag",
+			PlantQuery{Tags: []string{"grande", "cuisine"}, AnyTag: true},
+			[]int{1, 3},
+		},
+		{"genus", PlantQuery{GenericName: "MONSTERA"}, []int{1, 4}},
+		{"species", PlantQuery{SpecificName: "Carica"}, []int{2}},
+		{
+			"alice genus",
+			PlantQuery{UserId: 1, GenericName: "monstera"},
+			[]int{1},
+		},
+		{"desc", PlantQuery{Desc: true}, []int{4, 3, 2, 1}},
+		{
+			"page",
+			PlantQuery{Desc: true, Limit: 2, After: &PlantCursor{Id: 3}},
+			[]int{2, 1},
+		},
+		{
+			"name",
+			PlantQuery{Sort: SortByName, UserId: 1},
+			[]int{3, 2, 1},
+		},
+	}
+	ctx := context.Background()
+	for backend, db := range testDatabases(t) {
+		seed(t, db)
+		for _, test := range tests {
+			descs, err := db.GetPlantsShortDescription(ctx, test.q)
+			if err != nil {
+				t.Errorf("%s, %s: %v", backend, test.name, err)
+				continue
+			}
+			got := []int{}
+			for _, d := range descs {
+				got = append(got, d.Id)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf(
+					"%s, %s: plants = %v, want %v",
+					backend,
+					test.name,
+					got,
+					test.want,
+				)
+			}
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	// Results, as kind, plant and log entry, in any order since the ranks
+	// differ by backend
+	type result struct {
+		kind         string
+		plant, entry int
+	}
+	tests := []struct {
+		user  int
+		query string
+		want  []result
+	}{
+		{
+			0,
+			"monstera",
+			[]result{{"log", 4, 5}, {"plant", 1, 0}, {"plant", 4, 0}},
+		},
+		{1, "monstera", []result{{"plant", 1, 0}}},
+		{2, "monstera", []result{{"log", 4, 5}, {"plant", 4, 0}}},
+		{0, "DELICIOSA salon", []result{{"plant", 1, 0}}},
+		{0, "fig", []result{{"plant", 2, 0}}},
+		// Accents are ignored, and words match as prefixes
+		{0, "recolte feuil", []result{{"log", 3, 4}}},
+		{2, "récolte", []result{}},
+		{0, "cactus", []result{}},
+		{0, " ", []result{}},
+	}
+	ctx := context.Background()
+	for backend, db := range testDatabases(t) {
+		seed(t, db)
+		for _, test := range tests {
+			results, err := db.Search(ctx, test.user, test.query, 10)
+			if err != nil {
+				t.Errorf("%s, %q: %v", backend, test.query, err)
+				continue
+			}
+			got := []result{}
+			for _, r := range results {
+				got = append(got, result{r.Kind, r.PlantId, r.LogId})
+			}
+			slices.SortFunc(got, func(a, b result) int {
+				return cmp.Or(
+					cmp.Compare(a.kind, b.kind),
+					cmp.Compare(a.plant, b.plant),
+					cmp.Compare(a.entry, b.entry),
+				)
+			})
+			if !slices.Equal(got, test.want) {
+				t.Errorf(
+					"%s, user %d, %q: results = %v, want %v",
+					backend,
+					test.user,
+					test.query,
+					got,
+					test.want,
+				)
+			}
+		}
+	}
+}
+
+func TestMovePlant(t *testing.T) {
+	tests := []struct {
+		plant, location int
+		err             error
+		// Location of the plant after the move
+		want int
+	}{
+		{3, 3, nil, 3},
+		{3, 1, nil, 1},
+		{3, 0, nil, 0},
+		// The location must belong to the household of the plant
+		{3, 4, ErrNotFound, 0},
+		{4, 2, ErrNotFound, 4},
+		{4, 99, ErrNotFound, 4},
+		{99, 1, ErrNotFound, 0},
+	}
+	ctx := context.Background()
+	occurredAt := time.Date(2025, time.June, 2, 8, 0, 0, 0, time.UTC)
+	for backend, db := range testDatabases(t) {
+		seed(t, db)
+		for _, test := range tests {
+			logId, err := db.MovePlant(
+				ctx,
+				test.plant,
+				test.location,
+				"Déménagement",
+				occurredAt,
+			)
+			if !errors.Is(err, test.err) {
+				t.Errorf(
+					"%s, %d to %d: error = %v, want %v",
+					backend,
+					test.plant,
+					test.location,
+					err,
+					test.err,
+				)
+				continue
+			}
+			if test.plant == 99 {
+				continue
+			}
+
+			_, l, err := db.GetPlantLocation(ctx, test.plant)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.Id != test.want {
+				t.Errorf(
+					"%s, %d to %d: location = %d, want %d",
+					backend,
+					test.plant,
+					test.location,
+					l.Id,
+					test.want,
+				)
+			}
+			// The move is logged
+			if test.err != nil {
+				continue
+			}
+			entry, err := db.GetPlantLog(ctx, test.plant, logId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.EventType != plants.EventMove ||
+				entry.Desc != "Déménagement" ||
+				!entry.OccurredAt.Equal(occurredAt) {
+				t.Errorf("%s: log entry = %+v, want the move", backend, entry)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Kinds of errors returned by the Database implementations. They are wrapped
+// in an *Error, use errors.Is to check the kind of an error.
+var (
+	// The requested entity does not exist.
+	ErrNotFound = errors.New("database: Not found")
+	// The data violates a constraint of the schema, such as a reference to a
+	// missing entity or an empty common name.
+	ErrConstraint = errors.New("database: Constraint violation")
+	// The operation conflicts with the current state of the database, such as
+	// a duplicate value.
+	ErrConflict = errors.New("database: Conflict")
+)
+
+// Error is an error of a known kind returned by a Database. Its message
+// describes the error without exposing the underlying query or driver error,
+// it can be shown to clients.
+type Error struct {
+	Kind error
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return "database: " + e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+var (
+	errPlantNotFound      = &Error{ErrNotFound, "Plant not found"}
+	errLogNotFound        = &Error{ErrNotFound, "Log entry not found"}
+	errEmptyCommName      = &Error{ErrConstraint, "Common name is empty"}
+	errUnknownEvent       = &Error{ErrConstraint, "Unknown event type"}
+	errMissingPlant       = &Error{ErrConstraint, "Plant does not exist"}
+	errScheduleNotFound   = &Error{ErrNotFound, "Care schedule not found"}
+	errInvalidSchedule    = &Error{ErrConstraint, "Invalid care schedule"}
+	errPhotoNotFound      = &Error{ErrNotFound, "Photo not found"}
+	errUserNotFound       = &Error{ErrNotFound, "User not found"}
+	errUsernameTaken      = &Error{ErrConflict, "Username is already taken"}
+	errTokenNotFound      = &Error{ErrNotFound, "Token not found"}
+	errTokenExists        = &Error{ErrConflict, "Token already exists"}
+	errHouseholdNotFound  = &Error{ErrNotFound, "Household not found"}
+	errHouseholdNotEmpty  = &Error{ErrConflict, "Household still has plants"}
+	errMemberNotFound     = &Error{ErrNotFound, "Member not found"}
+	errAlreadyMember      = &Error{ErrConflict, "User is already a member"}
+	errLastOwner          = &Error{ErrConflict, "Household must keep an owner"}
+	errInvitationNotFound = &Error{ErrNotFound, "Invitation not found or expired"}
+	errLocationNotFound   = &Error{ErrNotFound, "Location not found"}
+	errLocationNotEmpty   = &Error{ErrConflict, "Location is not empty"}
+	errTagNotFound        = &Error{ErrNotFound, "Tag not found"}
+	errTaxonNotFound      = &Error{ErrNotFound, "Taxon not found"}
+)
+
+// Translates the errors of pgx that are caused by the data into errors of a
+// known kind. Other errors are returned unchanged.
+func pgError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Error{ErrNotFound, "Not found"}
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	// See https://www.postgresql.org/docs/current/errcodes-appendix.html
+	switch pgErr.Code {
+	case "23503":
+		return &Error{ErrConstraint, "Referenced entity does not exist"}
+	case "23502", "23514", "22001":
+		return &Error{ErrConstraint, "Invalid value"}
+	case "23505":
+		return &Error{ErrConflict, "Already exists"}
+	case "40001", "40P01":
+		return &Error{ErrConflict, "Concurrent modification, try again"}
+	}
+	return err
+}
+
+// Translates the errors of SQLite that are caused by the data into errors of a
+// known kind. Other errors are returned unchanged.
+func sqliteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{ErrNotFound, "Not found"}
+	}
+	var liteErr *sqlite.Error
+	if !errors.As(err, &liteErr) {
+		return err
+	}
+	switch liteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return &Error{ErrConstraint, "Referenced entity does not exist"}
+	case sqlite3.SQLITE_CONSTRAINT_CHECK, sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		return &Error{ErrConstraint, "Invalid value"}
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return &Error{ErrConflict, "Already exists"}
+	case sqlite3.SQLITE_BUSY:
+		return &Error{ErrConflict, "Concurrent modification, try again"}
+	}
+	return err
+}
@@ -0,0 +1,1915 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"github.com/mgmu/hortus/internal/plants"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryDatabase is a Database that keeps plants and their logs in memory. It
+// is meant for development and tests, its content is lost when the process
+// exits. It is safe for concurrent use. Its operations never block, so contexts
+// are ignored.
+type MemoryDatabase struct {
+	mu        sync.RWMutex
+	plants    []memoryPlant
+	logs      []plants.PlantLog
+	schedules []plants.CareSchedule
+	photos    []plants.Photo
+	users     []memoryUser
+	tokens    []memoryToken
+	houses    []memoryHousehold
+	members   []memoryMember
+	invits    []memoryInvitation
+	places    []plants.Location
+	tags      []memoryPlantTag
+	taxa      []plants.Taxon
+	nextPlant int
+	nextLog   int
+	nextSched int
+	nextPhoto int
+	nextUser  int
+	nextToken int
+	nextHouse int
+	nextInvit int
+	nextPlace int
+	nextTaxon int
+}
+
+// Row of the in-memory 'plant' table. taxonId, householdId and locationId are 0
+// if the plant has no taxon, no household or no location.
+type memoryPlant struct {
+	id          int
+	comm        string
+	taxonId     int
+	createdAt   time.Time
+	householdId int
+	locationId  int
+}
+
+// Row of the in-memory 'user' table.
+type memoryUser struct {
+	user plants.User
+	hash string
+}
+
+// Row of the in-memory 'household' table.
+type memoryHousehold struct {
+	id        int
+	name      string
+	createdAt time.Time
+}
+
+// Row of the in-memory 'household_member' table.
+type memoryMember struct {
+	householdId, userId int
+	role                plants.Role
+	joinedAt            time.Time
+}
+
+// Row of the in-memory 'household_invitation' table. The name of the household
+// of inv is not set.
+type memoryInvitation struct {
+	inv        plants.Invitation
+	hash       string
+	acceptedAt time.Time
+}
+
+// Row of the in-memory 'plant_tag' table, naming the tag instead of referring to
+// a row of a 'tag' table.
+type memoryPlantTag struct {
+	plantId int
+	name    string
+}
+
+// Row of the in-memory 'api_token' table.
+type memoryToken struct {
+	token plants.Token
+	hash  string
+}
+
+// Connect initializes the in-memory tables. Identifiers start at 1, like the
+// SERIAL columns of the Postgres schema. Always returns a nil error.
+func (db *MemoryDatabase) Connect(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.plants = nil
+	db.logs = nil
+	db.schedules = nil
+	db.photos = nil
+	db.users = nil
+	db.tokens = nil
+	db.houses = nil
+	db.members = nil
+	db.invits = nil
+	db.places = nil
+	db.tags = nil
+	db.taxa = nil
+	db.nextPlant = 1
+	db.nextLog = 1
+	db.nextSched = 1
+	db.nextPhoto = 1
+	db.nextUser = 1
+	db.nextToken = 1
+	db.nextHouse = 1
+	db.nextInvit = 1
+	db.nextPlace = 1
+	db.nextTaxon = 1
+	return nil
+}
+
+// Close drops the content of the database. Always returns a nil error.
+func (db *MemoryDatabase) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.plants = nil
+	db.logs = nil
+	db.schedules = nil
+	db.photos = nil
+	db.users = nil
+	db.tokens = nil
+	db.houses = nil
+	db.members = nil
+	db.invits = nil
+	db.places = nil
+	db.tags = nil
+	db.taxa = nil
+	return nil
+}
+
+// GetPlantsShortDescription returns the identifier, common name, creation date,
+// location and tags of the plants selected by q.
+func (db *MemoryDatabase) GetPlantsShortDescription(
+	ctx context.Context,
+	q PlantQuery,
+) ([]plants.PlantShortDesc, error) {
+	db.mu.RLock()
+	descs := []plants.PlantShortDesc{}
+	for _, p := range db.plants {
+		if !db.visible(q.UserId, p) {
+			continue
+		}
+		if q.LocationId != 0 && !db.inLocation(p.locationId, q.LocationId) {
+			continue
+		}
+		tags := db.plantTags(p.id)
+		if len(q.Tags) > 0 && !hasTags(tags, q.Tags, q.AnyTag) {
+			continue
+		}
+		t := db.plantTaxon(p)
+		if q.GenericName != "" && !strings.EqualFold(t.Genus, q.GenericName) {
+			continue
+		}
+		if q.SpecificName != "" &&
+			!strings.EqualFold(t.Species, q.SpecificName) {
+			continue
+		}
+		descs = append(descs, plants.PlantShortDesc{
+			Id:         p.id,
+			CommonName: p.comm,
+			CreatedAt:  p.createdAt,
+			LocationId: p.locationId,
+			Tags:       tags,
+		})
+	}
+	db.mu.RUnlock()
+
+	// Compares the plants a and b according to the sort key of q
+	compare := func(a, b PlantCursor) int {
+		c := 0
+		switch q.Sort {
+		case SortByName:
+			c = strings.Compare(
+				strings.ToLower(a.Name),
+				strings.ToLower(b.Name),
+			)
+		case SortByCreation:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = a.Id - b.Id
+		}
+		if q.Desc {
+			c = -c
+		}
+		return c
+	}
+	cursor := func(p plants.PlantShortDesc) PlantCursor {
+		return PlantCursor{p.Id, p.CommonName, p.CreatedAt}
+	}
+
+	slices.SortFunc(descs, func(a, b plants.PlantShortDesc) int {
+		return compare(cursor(a), cursor(b))
+	})
+	if q.After != nil {
+		descs = slices.DeleteFunc(descs, func(p plants.PlantShortDesc) bool {
+			return compare(cursor(p), *q.After) <= 0
+		})
+	}
+	if q.Limit > 0 && len(descs) > q.Limit {
+		descs = descs[:q.Limit]
+	}
+	return descs, nil
+}
+
+// AddNewPlant stores a new plant with the provided common name, of the taxon of
+// identifier taxonId and owned by the household of identifier householdId, or
+// of no taxon and by no one if they are 0. Like the constraints of the Postgres
+// schema, returns an error if the common name is empty or if the taxon or the
+// household does not exist. Otherwise returns the identifier of the new plant
+// and a nil error.
+func (db *MemoryDatabase) AddNewPlant(
+	ctx context.Context,
+	householdId int,
+	comm string,
+	taxonId int,
+) (int, error) {
+	if comm == "" {
+		return 0, errEmptyCommName
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if (householdId != 0 && db.findHousehold(householdId) < 0) ||
+		(taxonId != 0 && db.findTaxon(taxonId) < 0) {
+		return 0, &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	id := db.nextPlant
+	db.nextPlant++
+	db.plants = append(
+		db.plants,
+		memoryPlant{id, comm, taxonId, time.Now(), householdId, 0},
+	)
+	return id, nil
+}
+
+// GetPlantRole returns the role of the user of identifier userId in the
+// household owning the plant of identifier plantId. The plant is not found if
+// the user is not a member of its household.
+func (db *MemoryDatabase) GetPlantRole(
+	ctx context.Context,
+	userId, plantId int,
+) (plants.Role, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	p, ok := db.findPlant(plantId)
+	if !ok {
+		return "", errPlantNotFound
+	}
+	i := db.findMember(p.householdId, userId)
+	if i < 0 {
+		return "", errPlantNotFound
+	}
+	return db.members[i].role, nil
+}
+
+// Reports whether the plant p belongs to one of the households of the user of
+// identifier userId, or whether userId is 0. The caller must hold db.mu.
+func (db *MemoryDatabase) visible(userId int, p memoryPlant) bool {
+	return userId == 0 || db.findMember(p.householdId, userId) >= 0
+}
+
+// GetPlantNames returns the common name and the taxon of the plant of given id,
+// the zero Taxon if it has none.
+func (db *MemoryDatabase) GetPlantNames(
+	ctx context.Context,
+	id int,
+) (string, plants.Taxon, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	p, ok := db.findPlant(id)
+	if !ok {
+		return "", plants.Taxon{}, errPlantNotFound
+	}
+	return p.comm, db.plantTaxon(p), nil
+}
+
+// UpdatePlant replaces the common name and the taxon of the plant of given id,
+// which has no taxon if taxonId is 0. Returns an error if the common name is
+// empty, if the taxon does not exist or if no plant has this identifier.
+func (db *MemoryDatabase) UpdatePlant(
+	ctx context.Context,
+	id int,
+	comm string,
+	taxonId int,
+) error {
+	if comm == "" {
+		return errEmptyCommName
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if taxonId != 0 && db.findTaxon(taxonId) < 0 {
+		return &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	for i := range db.plants {
+		if db.plants[i].id == id {
+			db.plants[i].comm = comm
+			db.plants[i].taxonId = taxonId
+			return nil
+		}
+	}
+	return errPlantNotFound
+}
+
+// DeletePlant removes the plant of given id, all its logs, its care schedules
+// and its photos. Returns an error if no plant has this identifier.
+func (db *MemoryDatabase) DeletePlant(ctx context.Context, id int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	found := false
+	db.plants = slices.DeleteFunc(db.plants, func(p memoryPlant) bool {
+		found = found || p.id == id
+		return p.id == id
+	})
+	if !found {
+		return errPlantNotFound
+	}
+	db.logs = slices.DeleteFunc(db.logs, func(l plants.PlantLog) bool {
+		return l.PlantId == id
+	})
+	db.schedules = slices.DeleteFunc(
+		db.schedules,
+		func(s plants.CareSchedule) bool {
+			return s.PlantId == id
+		},
+	)
+	db.photos = slices.DeleteFunc(db.photos, func(p plants.Photo) bool {
+		return p.PlantId == id
+	})
+	db.tags = slices.DeleteFunc(db.tags, func(t memoryPlantTag) bool {
+		return t.plantId == id
+	})
+	return nil
+}
+
+// GetPlantLogs returns all the logs of the plant of given identifier, in
+// chronological order.
+func (db *MemoryDatabase) GetPlantLogs(
+	ctx context.Context,
+	id int,
+) ([]plants.PlantLog, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	plantLogs := []plants.PlantLog{}
+	for _, l := range db.logs {
+		if l.PlantId == id {
+			plantLogs = append(plantLogs, l)
+		}
+	}
+	sort.SliceStable(plantLogs, func(i, j int) bool {
+		return plantLogs[i].OccurredAt.Before(plantLogs[j].OccurredAt)
+	})
+	return plantLogs, nil
+}
+
+// AddNewPlantLog stores a new log for the plant of given identifier with given
+// description, event type and time of occurrence, recorded at the current
+// time, and returns its identifier. Returns an error if no plant has this
+// identifier or if the event type is unknown.
+func (db *MemoryDatabase) AddNewPlantLog(
+	ctx context.Context,
+	id int,
+	desc string,
+	event plants.EventType,
+	occurredAt time.Time,
+) (int, error) {
+	if !event.Valid() {
+		return 0, errUnknownEvent
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.findPlant(id); !ok {
+		return 0, errMissingPlant
+	}
+	logId := db.nextLog
+	db.logs = append(db.logs, plants.PlantLog{
+		Id:         logId,
+		PlantId:    id,
+		Desc:       desc,
+		EventType:  event,
+		OccurredAt: occurredAt,
+		RecordedAt: time.Now(),
+	})
+	db.nextLog++
+	return logId, nil
+}
+
+// Returns the plant of given identifier. The caller must hold db.mu.
+func (db *MemoryDatabase) findPlant(id int) (memoryPlant, bool) {
+	for _, p := range db.plants {
+		if p.id == id {
+			return p, true
+		}
+	}
+	return memoryPlant{}, false
+}
+
+// Returns the index in db.logs of the log entry of identifier logId belonging to
+// the plant of identifier plantId, or -1. The caller must hold db.mu.
+func (db *MemoryDatabase) findLog(plantId, logId int) int {
+	return slices.IndexFunc(db.logs, func(l plants.PlantLog) bool {
+		return l.Id == logId && l.PlantId == plantId
+	})
+}
+
+// GetPlantLog returns the log entry of identifier logId, if it belongs to the
+// plant of identifier plantId.
+func (db *MemoryDatabase) GetPlantLog(
+	ctx context.Context,
+	plantId, logId int,
+) (plants.PlantLog, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findLog(plantId, logId)
+	if i < 0 {
+		return plants.PlantLog{}, errLogNotFound
+	}
+	return db.logs[i], nil
+}
+
+// UpdatePlantLog replaces the description, event type and time of occurrence of
+// the log entry of identifier logId, if it belongs to the plant of identifier
+// plantId.
+func (db *MemoryDatabase) UpdatePlantLog(
+	ctx context.Context,
+	plantId, logId int,
+	desc string,
+	event plants.EventType,
+	occurredAt time.Time,
+) error {
+	if !event.Valid() {
+		return errUnknownEvent
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findLog(plantId, logId)
+	if i < 0 {
+		return errLogNotFound
+	}
+	db.logs[i].Desc = desc
+	db.logs[i].EventType = event
+	db.logs[i].OccurredAt = occurredAt
+	return nil
+}
+
+// DeletePlantLog removes the log entry of identifier logId, if it belongs to the
+// plant of identifier plantId. Its photos are kept as photos of the plant.
+func (db *MemoryDatabase) DeletePlantLog(ctx context.Context, plantId, logId int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findLog(plantId, logId)
+	if i < 0 {
+		return errLogNotFound
+	}
+	db.logs = slices.Delete(db.logs, i, i+1)
+	for i := range db.photos {
+		if db.photos[i].LogId == logId {
+			db.photos[i].LogId = 0
+		}
+	}
+	return nil
+}
+
+// Search returns the plants whose names and the log entries whose descriptions
+// contain words starting with all the words of query, ignoring case and
+// accents. Only the plants of the households of the user of given identifier
+// are searched, unless it is 0. Returns at most limit results, the best ranked
+// first.
+func (db *MemoryDatabase) Search(
+	ctx context.Context,
+	userId int,
+	query string,
+	limit int,
+) ([]plants.SearchResult, error) {
+	terms := searchTerms(query)
+	results := []plants.SearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, p := range db.plants {
+		if !db.visible(userId, p) {
+			continue
+		}
+		t := db.plantTaxon(p)
+		names := slices.DeleteFunc([]string{p.comm, t.Genus, t.Species}, func(n string) bool {
+			return n == ""
+		})
+		text := strings.Join(names, " ")
+		snippet, rank := highlightTerms(text, terms)
+		if rank > 0 {
+			results = append(results, plants.SearchResult{
+				Kind:       "plant",
+				PlantId:    p.id,
+				CommonName: p.comm,
+				Snippet:    snippet,
+				Rank:       rank,
+			})
+		}
+	}
+	for _, l := range db.logs {
+		p, _ := db.findPlant(l.PlantId)
+		if !db.visible(userId, p) {
+			continue
+		}
+		snippet, rank := highlightTerms(l.Desc, terms)
+		if rank > 0 {
+			results = append(results, plants.SearchResult{
+				Kind:       "log",
+				PlantId:    l.PlantId,
+				LogId:      l.Id,
+				CommonName: p.comm,
+				Snippet:    snippet,
+				Rank:       rank,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Splits text into fragments, the words starting with one of the terms being
+// matches. The rank is the proportion of matching words, zero if a term
+// matches no word.
+func highlightTerms(text string, terms []string) ([]plants.SnippetFragment, float64) {
+	fragments := []plants.SnippetFragment{}
+	found := make(map[string]bool)
+	words, matches := 0, 0
+	for text != "" {
+		// Split the next word from the text before it
+		start := strings.IndexFunc(text, isWordRune)
+		if start < 0 {
+			fragments = appendFragment(fragments, text, false)
+			break
+		}
+		end := strings.IndexFunc(text[start:], func(r rune) bool {
+			return !isWordRune(r)
+		})
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		fragments = appendFragment(fragments, text[:start], false)
+
+		word := foldText(text[start:end])
+		match := false
+		for _, t := range terms {
+			if strings.HasPrefix(word, t) {
+				found[t] = true
+				match = true
+			}
+		}
+		words++
+		if match {
+			matches++
+		}
+		fragments = appendFragment(fragments, text[start:end], match)
+		text = text[end:]
+	}
+	if len(found) < len(terms) {
+		return nil, 0
+	}
+	return fragments, float64(matches) / float64(words)
+}
+
+// Appends text to fragments, merging it with the last fragment if both match
+// or both do not.
+func appendFragment(
+	fragments []plants.SnippetFragment,
+	text string,
+	match bool,
+) []plants.SnippetFragment {
+	if text == "" {
+		return fragments
+	}
+	if n := len(fragments); n > 0 && fragments[n-1].Match == match {
+		fragments[n-1].Text += text
+		return fragments
+	}
+	return append(fragments, plants.SnippetFragment{Text: text, Match: match})
+}
+
+// Reports whether r is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// GetCareSchedules returns the care schedules of the plant of given identifier.
+func (db *MemoryDatabase) GetCareSchedules(
+	ctx context.Context,
+	plantId int,
+) ([]plants.CareSchedule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	schedules := []plants.CareSchedule{}
+	for _, s := range db.schedules {
+		if s.PlantId == plantId {
+			schedules = append(schedules, s)
+		}
+	}
+	return schedules, nil
+}
+
+// Returns the index in db.schedules of the care schedule of identifier
+// scheduleId belonging to the plant of identifier plantId, or -1. The caller
+// must hold db.mu.
+func (db *MemoryDatabase) findSchedule(plantId, scheduleId int) int {
+	return slices.IndexFunc(db.schedules, func(s plants.CareSchedule) bool {
+		return s.Id == scheduleId && s.PlantId == plantId
+	})
+}
+
+// GetCareSchedule returns the care schedule of identifier scheduleId, if it
+// belongs to the plant of identifier plantId.
+func (db *MemoryDatabase) GetCareSchedule(
+	ctx context.Context,
+	plantId, scheduleId int,
+) (plants.CareSchedule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findSchedule(plantId, scheduleId)
+	if i < 0 {
+		return plants.CareSchedule{}, errScheduleNotFound
+	}
+	return db.schedules[i], nil
+}
+
+// AddCareSchedule stores the care schedule s of the plant of identifier
+// s.PlantId, created at the current time, and returns its identifier. Like the
+// constraints of the Postgres schema, returns an error if the plant does not
+// exist or if the schedule is not valid.
+func (db *MemoryDatabase) AddCareSchedule(
+	ctx context.Context,
+	s plants.CareSchedule,
+) (int, error) {
+	if !s.Valid() {
+		return 0, errInvalidSchedule
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.findPlant(s.PlantId); !ok {
+		return 0, errMissingPlant
+	}
+	s.Id = db.nextSched
+	s.CreatedAt = time.Now()
+	db.nextSched++
+	db.schedules = append(db.schedules, s)
+	return s.Id, nil
+}
+
+// UpdateCareSchedule replaces the event type, interval and season of the care
+// schedule of identifier s.Id, if it belongs to the plant of identifier
+// s.PlantId.
+func (db *MemoryDatabase) UpdateCareSchedule(
+	ctx context.Context,
+	s plants.CareSchedule,
+) error {
+	if !s.Valid() {
+		return errInvalidSchedule
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findSchedule(s.PlantId, s.Id)
+	if i < 0 {
+		return errScheduleNotFound
+	}
+	db.schedules[i].EventType = s.EventType
+	db.schedules[i].IntervalDays = s.IntervalDays
+	db.schedules[i].SeasonStart = s.SeasonStart
+	db.schedules[i].SeasonEnd = s.SeasonEnd
+	return nil
+}
+
+// DeleteCareSchedule removes the care schedule of identifier scheduleId, if it
+// belongs to the plant of identifier plantId.
+func (db *MemoryDatabase) DeleteCareSchedule(
+	ctx context.Context,
+	plantId, scheduleId int,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findSchedule(plantId, scheduleId)
+	if i < 0 {
+		return errScheduleNotFound
+	}
+	db.schedules = slices.Delete(db.schedules, i, i+1)
+	return nil
+}
+
+// GetCareTasks returns the next occurrence as of now of the care schedules of
+// the plant of given identifier, or of all the plants of the households of the
+// user if it is 0. A user identifier of 0 selects the plants of all the
+// households.
+func (db *MemoryDatabase) GetCareTasks(
+	ctx context.Context,
+	userId, plantId int,
+	now time.Time,
+) ([]plants.CareTask, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	tasks := []plants.CareTask{}
+	for _, s := range db.schedules {
+		if plantId != 0 && s.PlantId != plantId {
+			continue
+		}
+		p, _ := db.findPlant(s.PlantId)
+		if !db.visible(userId, p) {
+			continue
+		}
+		var lastDone time.Time
+		for _, l := range db.logs {
+			if l.PlantId == s.PlantId &&
+				l.EventType == s.EventType &&
+				l.OccurredAt.After(lastDone) {
+				lastDone = l.OccurredAt
+			}
+		}
+		task := s.Task(p.comm, lastDone, now)
+		task.HouseholdId = p.householdId
+		tasks = append(tasks, task)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].PlantId < tasks[j].PlantId
+	})
+	return tasks, nil
+}
+
+// AddPhoto stores the metadata of the photo p of the plant of identifier
+// p.PlantId, created at the current time, and returns its identifier. Like the
+// constraints of the Postgres schema, returns an error if the plant or the log
+// entry does not exist, or if the key is already used.
+func (db *MemoryDatabase) AddPhoto(ctx context.Context, p plants.Photo) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.findPlant(p.PlantId); !ok {
+		return 0, errMissingPlant
+	}
+	if p.LogId != 0 && !slices.ContainsFunc(db.logs, func(l plants.PlantLog) bool {
+		return l.Id == p.LogId
+	}) {
+		return 0, &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	if slices.ContainsFunc(db.photos, func(q plants.Photo) bool {
+		return q.Key == p.Key
+	}) {
+		return 0, &Error{ErrConflict, "Already exists"}
+	}
+	p.Id = db.nextPhoto
+	p.CreatedAt = time.Now()
+	db.nextPhoto++
+	db.photos = append(db.photos, p)
+	return p.Id, nil
+}
+
+// GetPhotos returns the metadata of the photos of the plant of given
+// identifier, in the order they were added.
+func (db *MemoryDatabase) GetPhotos(
+	ctx context.Context,
+	plantId int,
+) ([]plants.Photo, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	photos := []plants.Photo{}
+	for _, p := range db.photos {
+		if p.PlantId == plantId {
+			photos = append(photos, p)
+		}
+	}
+	return photos, nil
+}
+
+// Returns the index in db.photos of the photo of identifier photoId belonging
+// to the plant of identifier plantId, or -1. The caller must hold db.mu.
+func (db *MemoryDatabase) findPhoto(plantId, photoId int) int {
+	return slices.IndexFunc(db.photos, func(p plants.Photo) bool {
+		return p.Id == photoId && p.PlantId == plantId
+	})
+}
+
+// GetPhoto returns the metadata of the photo of identifier photoId, if it
+// belongs to the plant of identifier plantId.
+func (db *MemoryDatabase) GetPhoto(
+	ctx context.Context,
+	plantId, photoId int,
+) (plants.Photo, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findPhoto(plantId, photoId)
+	if i < 0 {
+		return plants.Photo{}, errPhotoNotFound
+	}
+	return db.photos[i], nil
+}
+
+// DeletePhoto removes the metadata of the photo of identifier photoId, if it
+// belongs to the plant of identifier plantId.
+func (db *MemoryDatabase) DeletePhoto(ctx context.Context, plantId, photoId int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findPhoto(plantId, photoId)
+	if i < 0 {
+		return errPhotoNotFound
+	}
+	db.photos = slices.Delete(db.photos, i, i+1)
+	return nil
+}
+
+// Returns the index in db.users of the user of given identifier, or -1. The
+// caller must hold db.mu.
+func (db *MemoryDatabase) findUser(id int) int {
+	return slices.IndexFunc(db.users, func(u memoryUser) bool {
+		return u.user.Id == id
+	})
+}
+
+// AddUser stores a user of given username and bcrypt password hash, and
+// returns its identifier. The user is the owner of a new household named after
+// it. The household of the first user is given the plants that have no
+// household. Like the unique index of the Postgres schema, returns an error if
+// the username is already taken, ignoring case.
+func (db *MemoryDatabase) AddUser(
+	ctx context.Context,
+	username, passwordHash string,
+) (int, error) {
+	if username == "" {
+		return 0, &Error{ErrConstraint, "Invalid value"}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if slices.ContainsFunc(db.users, func(u memoryUser) bool {
+		return strings.EqualFold(u.user.Username, username)
+	}) {
+		return 0, errUsernameTaken
+	}
+	u := plants.User{Id: db.nextUser, Username: username, CreatedAt: time.Now()}
+	db.nextUser++
+	db.users = append(db.users, memoryUser{u, passwordHash})
+	householdId := db.addHousehold(u.Id, username)
+	if len(db.users) == 1 {
+		for i := range db.plants {
+			if db.plants[i].householdId == 0 {
+				db.plants[i].householdId = householdId
+			}
+		}
+	}
+	return u.Id, nil
+}
+
+// GetUser returns the user of given identifier.
+func (db *MemoryDatabase) GetUser(ctx context.Context, id int) (plants.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findUser(id)
+	if i < 0 {
+		return plants.User{}, errUserNotFound
+	}
+	return db.users[i].user, nil
+}
+
+// GetUserByName returns the user of given username, ignoring case, and the
+// bcrypt hash of its password.
+func (db *MemoryDatabase) GetUserByName(
+	ctx context.Context,
+	username string,
+) (plants.User, string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, u := range db.users {
+		if strings.EqualFold(u.user.Username, username) {
+			return u.user, u.hash, nil
+		}
+	}
+	return plants.User{}, "", errUserNotFound
+}
+
+// AddToken stores the token t, identified by the SHA-256 hash of its secret,
+// and returns its identifier. Like the constraints of the Postgres schema,
+// returns an error if a token of same hash exists or if the user of the token
+// does not exist.
+func (db *MemoryDatabase) AddToken(
+	ctx context.Context,
+	t plants.Token,
+	hash string,
+) (int, error) {
+	if t.Name == "" ||
+		(t.Scope != plants.ScopeRead && t.Scope != plants.ScopeWrite) {
+		return 0, &Error{ErrConstraint, "Invalid value"}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if t.UserId != 0 && db.findUser(t.UserId) < 0 {
+		return 0, &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	if slices.ContainsFunc(db.tokens, func(m memoryToken) bool {
+		return m.hash == hash
+	}) {
+		return 0, errTokenExists
+	}
+	t.Id = db.nextToken
+	t.CreatedAt = time.Now()
+	t.RevokedAt = time.Time{}
+	db.nextToken++
+	db.tokens = append(db.tokens, memoryToken{t, hash})
+	return t.Id, nil
+}
+
+// GetTokenByHash returns the token whose secret has the given SHA-256 hash.
+// Revoked tokens are not found.
+func (db *MemoryDatabase) GetTokenByHash(
+	ctx context.Context,
+	hash string,
+) (plants.Token, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, m := range db.tokens {
+		if m.hash == hash && m.token.RevokedAt.IsZero() {
+			return m.token, nil
+		}
+	}
+	return plants.Token{}, errTokenNotFound
+}
+
+// GetTokens returns the tokens of the user of given identifier, or all the
+// tokens if userId is 0, including the revoked ones.
+func (db *MemoryDatabase) GetTokens(
+	ctx context.Context,
+	userId int,
+) ([]plants.Token, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	tokens := []plants.Token{}
+	for _, m := range db.tokens {
+		if userId == 0 || m.token.UserId == userId {
+			tokens = append(tokens, m.token)
+		}
+	}
+	return tokens, nil
+}
+
+// RevokeToken revokes the token of given identifier, if it belongs to the user
+// of identifier userId or if userId is 0. Revoking a revoked token is an error.
+func (db *MemoryDatabase) RevokeToken(ctx context.Context, userId, id int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i := range db.tokens {
+		t := &db.tokens[i].token
+		if t.Id == id && (userId == 0 || t.UserId == userId) &&
+			t.RevokedAt.IsZero() {
+			t.RevokedAt = time.Now()
+			return nil
+		}
+	}
+	return errTokenNotFound
+}
+
+// Returns the index of the household of given identifier in db.houses, or
+// -1 if there is none. The caller must hold db.mu.
+func (db *MemoryDatabase) findHousehold(id int) int {
+	return slices.IndexFunc(db.houses, func(h memoryHousehold) bool {
+		return h.id == id
+	})
+}
+
+// Returns the index of the membership of the user of identifier userId in the
+// household of identifier householdId in db.members, or -1 if there is none.
+// The caller must hold db.mu.
+func (db *MemoryDatabase) findMember(householdId, userId int) int {
+	return slices.IndexFunc(db.members, func(m memoryMember) bool {
+		return m.householdId == householdId && m.userId == userId
+	})
+}
+
+// Stores a household of given name, of which the user of identifier userId is
+// the owner, and returns its identifier. The caller must hold db.mu for
+// writing.
+func (db *MemoryDatabase) addHousehold(userId int, name string) int {
+	now := time.Now()
+	id := db.nextHouse
+	db.nextHouse++
+	db.houses = append(db.houses, memoryHousehold{id, name, now})
+	db.members = append(
+		db.members,
+		memoryMember{id, userId, plants.RoleOwner, now},
+	)
+	return id
+}
+
+// AddHousehold stores a household of given name, of which the user of
+// identifier userId is the owner, and returns its identifier. Like the
+// constraints of the Postgres schema, returns an error if the name is empty or
+// if the user does not exist.
+func (db *MemoryDatabase) AddHousehold(
+	ctx context.Context,
+	userId int,
+	name string,
+) (int, error) {
+	if name == "" {
+		return 0, &Error{ErrConstraint, "Invalid value"}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.findUser(userId) < 0 {
+		return 0, &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	return db.addHousehold(userId, name), nil
+}
+
+// GetHouseholds returns the households of which the user of given identifier is
+// a member, with the role of the user.
+func (db *MemoryDatabase) GetHouseholds(
+	ctx context.Context,
+	userId int,
+) ([]plants.Household, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	households := []plants.Household{}
+	for _, h := range db.houses {
+		if i := db.findMember(h.id, userId); i >= 0 {
+			households = append(households, plants.Household{
+				Id:        h.id,
+				Name:      h.name,
+				Role:      db.members[i].role,
+				CreatedAt: h.createdAt,
+			})
+		}
+	}
+	return households, nil
+}
+
+// GetHousehold returns the household of given identifier and its members, in
+// the order they joined.
+func (db *MemoryDatabase) GetHousehold(
+	ctx context.Context,
+	id int,
+) (plants.Household, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findHousehold(id)
+	if i < 0 {
+		return plants.Household{}, errHouseholdNotFound
+	}
+	h := plants.Household{
+		Id:        id,
+		Name:      db.houses[i].name,
+		CreatedAt: db.houses[i].createdAt,
+		Members:   []plants.Member{},
+	}
+	for _, m := range db.members {
+		if m.householdId != id {
+			continue
+		}
+		u := db.users[db.findUser(m.userId)].user
+		h.Members = append(h.Members, plants.Member{
+			UserId:   m.userId,
+			Username: u.Username,
+			Role:     m.role,
+			JoinedAt: m.joinedAt,
+		})
+	}
+	return h, nil
+}
+
+// UpdateHousehold renames the household of given identifier.
+func (db *MemoryDatabase) UpdateHousehold(
+	ctx context.Context,
+	id int,
+	name string,
+) error {
+	if name == "" {
+		return &Error{ErrConstraint, "Invalid value"}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findHousehold(id)
+	if i < 0 {
+		return errHouseholdNotFound
+	}
+	db.houses[i].name = name
+	return nil
+}
+
+// DeleteHousehold deletes the household of given identifier, with its members,
+// invitations and locations. Returns an error if the household still has plants.
+func (db *MemoryDatabase) DeleteHousehold(ctx context.Context, id int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findHousehold(id)
+	if i < 0 {
+		return errHouseholdNotFound
+	}
+	if slices.ContainsFunc(db.plants, func(p memoryPlant) bool {
+		return p.householdId == id
+	}) {
+		return errHouseholdNotEmpty
+	}
+	db.houses = slices.Delete(db.houses, i, i+1)
+	db.members = slices.DeleteFunc(db.members, func(m memoryMember) bool {
+		return m.householdId == id
+	})
+	db.invits = slices.DeleteFunc(
+		db.invits,
+		func(m memoryInvitation) bool {
+			return m.inv.HouseholdId == id
+		},
+	)
+	db.places = slices.DeleteFunc(db.places, func(l plants.Location) bool {
+		return l.HouseholdId == id
+	})
+	return nil
+}
+
+// GetMemberRole returns the role of the user of identifier userId in the
+// household of identifier householdId.
+func (db *MemoryDatabase) GetMemberRole(
+	ctx context.Context,
+	householdId, userId int,
+) (plants.Role, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findMember(householdId, userId)
+	if i < 0 {
+		return "", errMemberNotFound
+	}
+	return db.members[i].role, nil
+}
+
+// SetMemberRole changes the role of the user of identifier userId in the
+// household of identifier householdId. Returns an error if the household would
+// be left without owner.
+func (db *MemoryDatabase) SetMemberRole(
+	ctx context.Context,
+	householdId, userId int,
+	role plants.Role,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findMember(householdId, userId)
+	if i < 0 {
+		return errMemberNotFound
+	}
+	if role != plants.RoleOwner && db.lastOwner(i) {
+		return errLastOwner
+	}
+	db.members[i].role = role
+	return nil
+}
+
+// DeleteMember removes the user of identifier userId from the household of
+// identifier householdId. Returns an error if the household would be left
+// without owner.
+func (db *MemoryDatabase) DeleteMember(
+	ctx context.Context,
+	householdId, userId int,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findMember(householdId, userId)
+	if i < 0 {
+		return errMemberNotFound
+	}
+	if db.lastOwner(i) {
+		return errLastOwner
+	}
+	db.members = slices.Delete(db.members, i, i+1)
+	return nil
+}
+
+// Reports whether the member at index i of db.members is the only owner of its
+// household. The caller must hold db.mu.
+func (db *MemoryDatabase) lastOwner(i int) bool {
+	if db.members[i].role != plants.RoleOwner {
+		return false
+	}
+	for j, m := range db.members {
+		if j != i && m.householdId == db.members[i].householdId &&
+			m.role == plants.RoleOwner {
+			return false
+		}
+	}
+	return true
+}
+
+// AddInvitation stores the invitation inv, identified by the SHA-256 hash of
+// the secret of its link, and returns its identifier. Like the constraints of
+// the Postgres schema, returns an error if the household does not exist.
+func (db *MemoryDatabase) AddInvitation(
+	ctx context.Context,
+	inv plants.Invitation,
+	hash string,
+) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.findHousehold(inv.HouseholdId) < 0 {
+		return 0, &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	if slices.ContainsFunc(db.invits, func(m memoryInvitation) bool {
+		return m.hash == hash
+	}) {
+		return 0, &Error{ErrConflict, "Already exists"}
+	}
+	inv.Id = db.nextInvit
+	inv.HouseholdName = ""
+	inv.CreatedAt = time.Now()
+	db.nextInvit++
+	db.invits = append(db.invits, memoryInvitation{inv, hash, time.Time{}})
+	return inv.Id, nil
+}
+
+// GetInvitations returns the invitations to the household of given identifier
+// that are neither accepted nor expired at now.
+func (db *MemoryDatabase) GetInvitations(
+	ctx context.Context,
+	householdId int,
+	now time.Time,
+) ([]plants.Invitation, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	invitations := []plants.Invitation{}
+	for _, m := range db.invits {
+		if m.inv.HouseholdId == householdId && db.pending(m, now) {
+			invitations = append(invitations, db.invitation(m))
+		}
+	}
+	return invitations, nil
+}
+
+// GetInvitationByHash returns the invitation whose link has a secret of given
+// SHA-256 hash, if it is neither accepted nor expired at now.
+func (db *MemoryDatabase) GetInvitationByHash(
+	ctx context.Context,
+	hash string,
+	now time.Time,
+) (plants.Invitation, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, m := range db.invits {
+		if m.hash == hash && db.pending(m, now) {
+			return db.invitation(m), nil
+		}
+	}
+	return plants.Invitation{}, errInvitationNotFound
+}
+
+// Reports whether the invitation m is neither accepted nor expired at now.
+func (db *MemoryDatabase) pending(m memoryInvitation, now time.Time) bool {
+	return m.acceptedAt.IsZero() && m.inv.ExpiresAt.After(now)
+}
+
+// Returns the invitation of m with the name of its household. The caller must
+// hold db.mu.
+func (db *MemoryDatabase) invitation(m memoryInvitation) plants.Invitation {
+	inv := m.inv
+	inv.HouseholdName = db.houses[db.findHousehold(inv.HouseholdId)].name
+	return inv
+}
+
+// AcceptInvitation adds the user of identifier userId to the household of the
+// invitation of identifier id, with the role of the invitation, if it is
+// neither accepted nor expired at now. The invitation cannot be used again.
+func (db *MemoryDatabase) AcceptInvitation(
+	ctx context.Context,
+	id, userId int,
+	now time.Time,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := slices.IndexFunc(db.invits, func(m memoryInvitation) bool {
+		return m.inv.Id == id
+	})
+	if i < 0 || !db.pending(db.invits[i], now) {
+		return errInvitationNotFound
+	}
+	inv := db.invits[i].inv
+	if db.findUser(userId) < 0 {
+		return &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	if db.findMember(inv.HouseholdId, userId) >= 0 {
+		return errAlreadyMember
+	}
+	db.invits[i].acceptedAt = now
+	db.members = append(
+		db.members,
+		memoryMember{inv.HouseholdId, userId, inv.Role, now},
+	)
+	return nil
+}
+
+// DeleteInvitation deletes the invitation of identifier id to the household of
+// identifier householdId, if it is not accepted yet.
+func (db *MemoryDatabase) DeleteInvitation(
+	ctx context.Context,
+	householdId, id int,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := slices.IndexFunc(db.invits, func(m memoryInvitation) bool {
+		return m.inv.Id == id && m.inv.HouseholdId == householdId &&
+			m.acceptedAt.IsZero()
+	})
+	if i < 0 {
+		return errInvitationNotFound
+	}
+	db.invits = slices.Delete(db.invits, i, i+1)
+	return nil
+}
+
+// Returns the index in db.places of the location of given identifier, or -1.
+// The caller must hold db.mu.
+func (db *MemoryDatabase) findLocation(id int) int {
+	return slices.IndexFunc(db.places, func(l plants.Location) bool {
+		return l.Id == id
+	})
+}
+
+// Returns the location at index i of db.places with its path. The caller must
+// hold db.mu.
+func (db *MemoryDatabase) location(i int) plants.Location {
+	l := db.places[i]
+	l.Path = l.Name
+	for p := db.findLocation(l.ParentId); p >= 0; {
+		l.Path = db.places[p].Name + " / " + l.Path
+		p = db.findLocation(db.places[p].ParentId)
+	}
+	return l
+}
+
+// Reports whether the location of identifier id is the location of identifier
+// ancestor or one of the locations it contains. The caller must hold db.mu.
+func (db *MemoryDatabase) inLocation(id, ancestor int) bool {
+	for id != 0 {
+		if id == ancestor {
+			return true
+		}
+		i := db.findLocation(id)
+		if i < 0 {
+			return false
+		}
+		id = db.places[i].ParentId
+	}
+	return false
+}
+
+// Checks the location l against the constraints of the Postgres schema: its
+// name is not empty, its kind is known, only sites have no parent, and its
+// household and parent exist. The caller must hold db.mu.
+func (db *MemoryDatabase) checkLocation(l plants.Location) error {
+	if _, err := plants.ParseLocationKind(string(l.Kind)); err != nil ||
+		l.Name == "" || l.Kind.IsTop() != (l.ParentId == 0) {
+		return &Error{ErrConstraint, "Invalid value"}
+	}
+	if db.findHousehold(l.HouseholdId) < 0 ||
+		(l.ParentId != 0 && db.findLocation(l.ParentId) < 0) {
+		return &Error{ErrConstraint, "Referenced entity does not exist"}
+	}
+	return nil
+}
+
+// AddLocation stores the location l, in the parent location of identifier
+// l.ParentId unless it is 0, and returns its identifier.
+func (db *MemoryDatabase) AddLocation(
+	ctx context.Context,
+	l plants.Location,
+) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	err := db.checkLocation(l)
+	if err != nil {
+		return 0, err
+	}
+	l.Id = db.nextPlace
+	l.Path = ""
+	db.places = append(db.places, l)
+	db.nextPlace++
+	return l.Id, nil
+}
+
+// GetLocations returns the locations of the households of the user of
+// identifier userId, or all the locations if it is 0, ordered by household and
+// path.
+func (db *MemoryDatabase) GetLocations(
+	ctx context.Context,
+	userId int,
+) ([]plants.Location, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	locations := []plants.Location{}
+	for i, l := range db.places {
+		if userId == 0 || db.findMember(l.HouseholdId, userId) >= 0 {
+			locations = append(locations, db.location(i))
+		}
+	}
+	slices.SortFunc(locations, func(a, b plants.Location) int {
+		if a.HouseholdId != b.HouseholdId {
+			return a.HouseholdId - b.HouseholdId
+		}
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return a.Id - b.Id
+	})
+	return locations, nil
+}
+
+// GetLocation returns the location of given identifier.
+func (db *MemoryDatabase) GetLocation(
+	ctx context.Context,
+	id int,
+) (plants.Location, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findLocation(id)
+	if i < 0 {
+		return plants.Location{}, errLocationNotFound
+	}
+	return db.location(i), nil
+}
+
+// UpdateLocation replaces the name and the parent location of the location of
+// identifier l.Id. Its household and kind are left unchanged.
+func (db *MemoryDatabase) UpdateLocation(
+	ctx context.Context,
+	l plants.Location,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findLocation(l.Id)
+	if i < 0 {
+		return errLocationNotFound
+	}
+	updated := db.places[i]
+	updated.Name = l.Name
+	updated.ParentId = l.ParentId
+	err := db.checkLocation(updated)
+	if err != nil {
+		return err
+	}
+	db.places[i] = updated
+	return nil
+}
+
+// DeleteLocation deletes the location of given identifier, which must contain
+// neither plants nor other locations.
+func (db *MemoryDatabase) DeleteLocation(ctx context.Context, id int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := db.findLocation(id)
+	if i < 0 {
+		return errLocationNotFound
+	}
+	if slices.ContainsFunc(db.plants, func(p memoryPlant) bool {
+		return p.locationId == id
+	}) || slices.ContainsFunc(db.places, func(l plants.Location) bool {
+		return l.ParentId == id
+	}) {
+		return errLocationNotEmpty
+	}
+	db.places = slices.Delete(db.places, i, i+1)
+	return nil
+}
+
+// GetPlantLocation returns the identifier of the household of the plant of
+// given identifier, 0 if it has none, and the location of the plant, the zero
+// Location if it has none.
+func (db *MemoryDatabase) GetPlantLocation(
+	ctx context.Context,
+	plantId int,
+) (int, plants.Location, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	p, ok := db.findPlant(plantId)
+	if !ok {
+		return 0, plants.Location{}, errPlantNotFound
+	}
+	i := db.findLocation(p.locationId)
+	if i < 0 {
+		return p.householdId, plants.Location{}, nil
+	}
+	return p.householdId, db.location(i), nil
+}
+
+// MovePlant moves the plant of identifier plantId to the location of
+// identifier locationId, or out of any location if it is 0, and logs the move
+// with an entry of description desc that occurred at occurredAt. The location
+// must belong to the household of the plant. Returns the identifier of the
+// entry.
+func (db *MemoryDatabase) MovePlant(
+	ctx context.Context,
+	plantId, locationId int,
+	desc string,
+	occurredAt time.Time,
+) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := slices.IndexFunc(db.plants, func(p memoryPlant) bool {
+		return p.id == plantId
+	})
+	if i < 0 {
+		return 0, errPlantNotFound
+	}
+	if locationId != 0 {
+		l := db.findLocation(locationId)
+		if l < 0 || db.places[l].HouseholdId != db.plants[i].householdId {
+			return 0, errLocationNotFound
+		}
+	}
+	db.plants[i].locationId = locationId
+	logId := db.nextLog
+	db.logs = append(db.logs, plants.PlantLog{
+		Id:         logId,
+		PlantId:    plantId,
+		Desc:       desc,
+		EventType:  plants.EventMove,
+		OccurredAt: occurredAt,
+		RecordedAt: time.Now(),
+	})
+	db.nextLog++
+	return logId, nil
+}
+
+// Returns the tags of the plant of identifier plantId, in alphabetical order,
+// nil if it has none. The caller must hold db.mu.
+func (db *MemoryDatabase) plantTags(plantId int) []string {
+	var tags []string
+	for _, t := range db.tags {
+		if t.plantId == plantId {
+			tags = append(tags, t.name)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// Reports whether tags contains all the tags of want, or at least one of them
+// if anyTag is true.
+func hasTags(tags, want []string, anyTag bool) bool {
+	for _, tag := range want {
+		if slices.Contains(tags, tag) == anyTag {
+			return anyTag
+		}
+	}
+	return !anyTag
+}
+
+// AddPlantTags adds the tags to the plant of identifier plantId. The tags the
+// plant already has are left as is.
+func (db *MemoryDatabase) AddPlantTags(
+	ctx context.Context,
+	plantId int,
+	tags []string,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.findPlant(plantId); !ok {
+		return errPlantNotFound
+	}
+	for _, tag := range tags {
+		t := memoryPlantTag{plantId, tag}
+		if !slices.Contains(db.tags, t) {
+			db.tags = append(db.tags, t)
+		}
+	}
+	return nil
+}
+
+// RemovePlantTag removes the tag from the plant of identifier plantId.
+func (db *MemoryDatabase) RemovePlantTag(
+	ctx context.Context,
+	plantId int,
+	tag string,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	i := slices.Index(db.tags, memoryPlantTag{plantId, tag})
+	if i < 0 {
+		return errTagNotFound
+	}
+	db.tags = slices.Delete(db.tags, i, i+1)
+	return nil
+}
+
+// GetPlantTags returns the tags of the plant of given identifier, in
+// alphabetical order.
+func (db *MemoryDatabase) GetPlantTags(
+	ctx context.Context,
+	plantId int,
+) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	tags := db.plantTags(plantId)
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags, nil
+}
+
+// GetTagCounts returns the tags of the plants of the households of the user of
+// identifier userId, or of all the plants if it is 0, with the number of
+// plants having each of them, in alphabetical order.
+func (db *MemoryDatabase) GetTagCounts(
+	ctx context.Context,
+	userId int,
+) ([]plants.TagCount, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, t := range db.tags {
+		p, ok := db.findPlant(t.plantId)
+		if ok && db.visible(userId, p) {
+			counts[t.name]++
+		}
+	}
+	tagCounts := []plants.TagCount{}
+	for name, count := range counts {
+		tagCounts = append(tagCounts, plants.TagCount{Name: name, Count: count})
+	}
+	slices.SortFunc(tagCounts, func(a, b plants.TagCount) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return tagCounts, nil
+}
+
+// Returns the index of the taxon of given identifier in db.taxa, or -1 if there
+// is none. The caller must hold db.mu.
+func (db *MemoryDatabase) findTaxon(id int) int {
+	return slices.IndexFunc(db.taxa, func(t plants.Taxon) bool {
+		return t.Id == id
+	})
+}
+
+// Returns the taxon of the plant p, the zero Taxon if it has none. The caller
+// must hold db.mu.
+func (db *MemoryDatabase) plantTaxon(p memoryPlant) plants.Taxon {
+	i := db.findTaxon(p.taxonId)
+	if i < 0 {
+		return plants.Taxon{}
+	}
+	return db.taxa[i]
+}
+
+// AddTaxon stores the taxon t, unless a taxon of the same name exists, and
+// returns its identifier. The family of an existing taxon is only set if it
+// was empty. Like the constraints of the Postgres schema, returns an error if
+// the genus is empty.
+func (db *MemoryDatabase) AddTaxon(
+	ctx context.Context,
+	t plants.Taxon,
+) (int, error) {
+	if t.Genus == "" {
+		return 0, &Error{ErrConstraint, "Invalid value"}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	id, _ := db.addTaxon(t)
+	return id, nil
+}
+
+// Stores the taxon t, unless a taxon of the same name exists, and returns its
+// identifier and whether it was stored. The family of an existing taxon is only
+// set if it was empty. The caller must hold db.mu.
+func (db *MemoryDatabase) addTaxon(t plants.Taxon) (int, bool) {
+	for i, u := range db.taxa {
+		u.Id, u.Family = t.Id, t.Family
+		if u == t {
+			if db.taxa[i].Family == "" {
+				db.taxa[i].Family = t.Family
+			}
+			return db.taxa[i].Id, false
+		}
+	}
+	t.Id = db.nextTaxon
+	db.nextTaxon++
+	db.taxa = append(db.taxa, t)
+	return t.Id, true
+}
+
+// GetTaxon returns the taxon of given identifier.
+func (db *MemoryDatabase) GetTaxon(
+	ctx context.Context,
+	id int,
+) (plants.Taxon, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	i := db.findTaxon(id)
+	if i < 0 {
+		return plants.Taxon{}, errTaxonNotFound
+	}
+	return db.taxa[i], nil
+}
+
+// GetTaxa returns the taxa selected by q, in alphabetical order of their names.
+func (db *MemoryDatabase) GetTaxa(
+	ctx context.Context,
+	q TaxonQuery,
+) ([]plants.Taxon, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	taxa := []plants.Taxon{}
+	for _, t := range db.taxa {
+		if (q.Family == "" || strings.EqualFold(t.Family, q.Family)) &&
+			(q.Genus == "" || strings.EqualFold(t.Genus, q.Genus)) &&
+			(q.Species == "" || strings.EqualFold(t.Species, q.Species)) {
+			taxa = append(taxa, t)
+		}
+	}
+	slices.SortFunc(taxa, func(a, b plants.Taxon) int {
+		return cmp.Or(
+			strings.Compare(a.Genus, b.Genus),
+			strings.Compare(a.Species, b.Species),
+			strings.Compare(string(a.Rank), string(b.Rank)),
+			strings.Compare(a.Infraspecies, b.Infraspecies),
+			strings.Compare(a.Cultivar, b.Cultivar),
+		)
+	})
+	if q.Limit > 0 && len(taxa) > q.Limit {
+		taxa = taxa[:q.Limit]
+	}
+	return taxa, nil
+}
+
+// AddTaxa stores the taxa and returns the number of taxa stored. Like AddTaxon,
+// the taxa whose name is already stored are not stored again, and only their
+// empty family is set. No taxon is stored if one of them has no genus.
+func (db *MemoryDatabase) AddTaxa(
+	ctx context.Context,
+	taxa []plants.Taxon,
+) (int, error) {
+	for _, t := range taxa {
+		if t.Genus == "" {
+			return 0, &Error{ErrConstraint, "Invalid value"}
+		}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	added := 0
+	for _, t := range taxa {
+		if _, ok := db.addTaxon(t); ok {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// GetTaxonNames returns the genera of the taxa, or the species of the taxa of
+// genus if it is not empty, starting with prefix, after their hybrid marker if
+// any, and ignoring case, in alphabetical order. At most limit names are
+// returned, all of them if it is not positive.
+func (db *MemoryDatabase) GetTaxonNames(
+	ctx context.Context,
+	genus, prefix string,
+	limit int,
+) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	prefix = strings.ToLower(prefix)
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, t := range db.taxa {
+		name := t.Genus
+		if genus != "" {
+			if !strings.EqualFold(t.Genus, genus) || t.Species == "" {
+				continue
+			}
+			name = t.Species
+		}
+		lower := strings.ToLower(name)
+		if !seen[name] && (strings.HasPrefix(lower, prefix) ||
+			strings.HasPrefix(lower, plants.HybridMarker+prefix)) {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+	return names, nil
+}
+
+// GetPlants returns the plants of the households of the user of identifier
+// userId, or all the plants if it is 0, with their taxon, household, location
+// and tags, but without their log entries, ordered by identifier.
+func (db *MemoryDatabase) GetPlants(
+	ctx context.Context,
+	userId int,
+) ([]plants.Plant, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	ps := []plants.Plant{}
+	for _, p := range db.plants {
+		if !db.visible(userId, p) {
+			continue
+		}
+		plant := plants.Plant{
+			Id:          p.id,
+			CommonName:  p.comm,
+			HouseholdId: p.householdId,
+			LocationId:  p.locationId,
+			Tags:        db.plantTags(p.id),
+		}
+		if t := db.plantTaxon(p); t.Id != 0 {
+			plant.Taxon = &t
+			plant.GenericName, plant.SpecificName = t.Genus, t.Species
+		}
+		if i := db.findLocation(p.locationId); i >= 0 {
+			plant.LocationPath = db.location(i).Path
+		}
+		ps = append(ps, plant)
+	}
+	return ps, nil
+}
+
+// GetLogs returns the log entries of the plants of the households of the user
+// of identifier userId, or of all the plants if it is 0, ordered by plant, then
+// in chronological order.
+func (db *MemoryDatabase) GetLogs(
+	ctx context.Context,
+	userId int,
+) ([]plants.PlantLog, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	logs := []plants.PlantLog{}
+	for _, l := range db.logs {
+		p, ok := db.findPlant(l.PlantId)
+		if ok && db.visible(userId, p) {
+			logs = append(logs, l)
+		}
+	}
+	slices.SortStableFunc(logs, func(a, b plants.PlantLog) int {
+		return cmp.Or(
+			cmp.Compare(a.PlantId, b.PlantId),
+			a.OccurredAt.Compare(b.OccurredAt),
+			cmp.Compare(a.Id, b.Id),
+		)
+	})
+	return logs, nil
+}
+
+// AddPlants stores the plants ps, owned by the household of identifier
+// householdId, or by no one if it is 0, and returns their identifiers. Each
+// plant has its common name, its location if LocationId is not 0, its tags, and
+// its taxon if Taxon is not nil, which is stored like AddTaxon does. Like the
+// constraints of the Postgres schema, returns an error if a common name or a
+// genus is empty, or if the household or a location does not exist, in which
+// case no plant is stored.
+func (db *MemoryDatabase) AddPlants(
+	ctx context.Context,
+	householdId int,
+	ps []plants.Plant,
+) ([]int, error) {
+	for _, p := range ps {
+		if p.CommonName == "" {
+			return nil, errEmptyCommName
+		}
+		if p.Taxon != nil && p.Taxon.Genus == "" {
+			return nil, &Error{ErrConstraint, "Invalid value"}
+		}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, p := range ps {
+		if (householdId != 0 && db.findHousehold(householdId) < 0) ||
+			(p.LocationId != 0 && db.findLocation(p.LocationId) < 0) {
+			return nil, &Error{ErrConstraint, "Referenced entity does not exist"}
+		}
+	}
+
+	ids := make([]int, 0, len(ps))
+	for _, p := range ps {
+		taxonId := 0
+		if p.Taxon != nil {
+			taxonId, _ = db.addTaxon(*p.Taxon)
+		}
+		id := db.nextPlant
+		db.nextPlant++
+		db.plants = append(db.plants, memoryPlant{
+			id,
+			p.CommonName,
+			taxonId,
+			time.Now(),
+			householdId,
+			p.LocationId,
+		})
+		for _, tag := range p.Tags {
+			t := memoryPlantTag{id, tag}
+			if !slices.Contains(db.tags, t) {
+				db.tags = append(db.tags, t)
+			}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
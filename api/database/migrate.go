@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration scripts, one directory per SQL dialect. Each migration is a pair
+// of files named <version>_<name>.up.sql and <version>_<name>.down.sql, the
+// version being a positive number. A script can report what it did to data that
+// did not fit the new schema by inserting messages in the temporary
+// 'migration_note' table, which are logged once the migration is applied.
+//
+//go:embed migrations
+var migrationsFS embed.FS
+
+// ErrPendingMigrations is returned by CheckMigrations when the schema of the
+// database is older than the one expected by this binary.
+var ErrPendingMigrations = errors.New(
+	"database: Schema is not up to date, run 'migrate up'",
+)
+
+// Migrator is implemented by the databases whose schema is versioned by the
+// embedded migration scripts.
+type Migrator interface {
+	// MigrateUp applies all the pending migrations in order and returns how
+	// many were applied.
+	MigrateUp(ctx context.Context) (int, error)
+	// MigrateDown reverts the last applied migration. Returns false if no
+	// migration was applied.
+	MigrateDown(ctx context.Context) (bool, error)
+	// MigrationStatus returns all the known migrations, ordered by version.
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+// MigrationStatus describes a migration and whether it has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// CheckMigrations returns ErrPendingMigrations if m has migrations left to
+// apply.
+func CheckMigrations(ctx context.Context, m Migrator) error {
+	status, err := m.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		if !s.Applied {
+			return ErrPendingMigrations
+		}
+	}
+	return nil
+}
+
+// A migration loaded from the embedded scripts.
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+// Operations a database provides for migrations to be applied to it.
+type migrationTarget interface {
+	// Creates the 'schema_migrations' table if it does not exist.
+	createMigrationsTable(ctx context.Context) error
+	// Returns the application time of the applied migrations, by version.
+	appliedMigrations(ctx context.Context) (map[int]time.Time, error)
+	// Runs the up (or down) script of m and records (or forgets) it in the
+	// 'schema_migrations' table, in a single transaction. Returns the messages
+	// the script inserted in the 'migration_note' table.
+	runMigration(ctx context.Context, m migration, up bool) ([]string, error)
+}
+
+// Loads the migrations of given dialect, ordered by version.
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		base, up := strings.CutSuffix(file, ".up.sql")
+		if !up {
+			var down bool
+			base, down = strings.CutSuffix(file, ".down.sql")
+			if !down {
+				return nil, fmt.Errorf("database: Bad migration file %s", file)
+			}
+		}
+		v, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(v)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("database: Bad migration file %s", file)
+		}
+		content, err := fs.ReadFile(migrationsFS, path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		} else if m.name != name {
+			return nil, fmt.Errorf("database: Duplicate migration %d", version)
+		}
+		if up {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf(
+				"database: Migration %d needs both up and down scripts",
+				m.version,
+			)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// Applies the migrations of given dialect not applied yet to t.
+func migrateUp(
+	ctx context.Context,
+	t migrationTarget,
+	dialect string,
+) (int, error) {
+	migrations, applied, err := prepareMigrations(ctx, t, dialect)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		notes, err := t.runMigration(ctx, m, true)
+		if err != nil {
+			return n, fmt.Errorf(
+				"database: Migration %d_%s failed: %w",
+				m.version,
+				m.name,
+				err,
+			)
+		}
+		logMigrationNotes(m, notes)
+		n++
+	}
+	return n, nil
+}
+
+// Reverts the last migration of given dialect applied to t.
+func migrateDown(
+	ctx context.Context,
+	t migrationTarget,
+	dialect string,
+) (bool, error) {
+	migrations, applied, err := prepareMigrations(ctx, t, dialect)
+	if err != nil {
+		return false, err
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		notes, err := t.runMigration(ctx, m, false)
+		if err != nil {
+			return false, fmt.Errorf(
+				"database: Reverting migration %d_%s failed: %w",
+				m.version,
+				m.name,
+				err,
+			)
+		}
+		logMigrationNotes(m, notes)
+		return true, nil
+	}
+	return false, nil
+}
+
+// Logs the messages left by the script of m.
+func logMigrationNotes(m migration, notes []string) {
+	for _, note := range notes {
+		log.Printf("database: Migration %d_%s: %s", m.version, m.name, note)
+	}
+}
+
+// Returns the status of the migrations of given dialect on t.
+func migrationStatus(
+	ctx context.Context,
+	t migrationTarget,
+	dialect string,
+) ([]MigrationStatus, error) {
+	migrations, applied, err := prepareMigrations(ctx, t, dialect)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		at, ok := applied[m.version]
+		status[i] = MigrationStatus{m.version, m.name, ok, at}
+	}
+	return status, nil
+}
+
+// Loads the migrations of given dialect and the ones already applied to t.
+func prepareMigrations(
+	ctx context.Context,
+	t migrationTarget,
+	dialect string,
+) ([]migration, map[int]time.Time, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = t.createMigrationsTable(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := t.appliedMigrations(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrations, applied, nil
+}
@@ -0,0 +1,2 @@
+DROP TABLE plant_log;
+DROP TABLE plant;
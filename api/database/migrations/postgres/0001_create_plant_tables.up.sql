@@ -1,10 +1,6 @@
--- Creates schema and sets the search path
-DROP SCHEMA IF EXISTS hortus_schema CASCADE;
-CREATE SCHEMA hortus_schema;
-SET search_path TO hortus_schema;
-
--- Creates tables
-CREATE TABLE hortus_schema.plant (
+-- Tables may already exist on installations created with the former
+-- init_hortus_db.sql script, in which case they are kept as is.
+CREATE TABLE IF NOT EXISTS plant (
        id SERIAL PRIMARY KEY,
        common_name VARCHAR(255) NOT NULL,
        generic_name VARCHAR(255),
@@ -12,10 +8,9 @@ CREATE TABLE hortus_schema.plant (
        CHECK (common_name <> '')
 );
 
-CREATE TABLE hortus_schema.plant_log (
+CREATE TABLE IF NOT EXISTS plant_log (
        id SERIAL PRIMARY KEY,
        plant_id INTEGER NOT NULL,
-       -- log_timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
        description VARCHAR(255) NOT NULL,
        event_type INTEGER NOT NULL,
        FOREIGN KEY (plant_id) REFERENCES plant(id)
@@ -0,0 +1,3 @@
+ALTER TABLE plant_log
+      DROP COLUMN occurred_at,
+      DROP COLUMN recorded_at;
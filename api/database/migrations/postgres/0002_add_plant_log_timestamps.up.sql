@@ -0,0 +1,5 @@
+-- Existing entries are considered to have happened when the migration is
+-- applied.
+ALTER TABLE plant_log
+      ADD COLUMN occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+      ADD COLUMN recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
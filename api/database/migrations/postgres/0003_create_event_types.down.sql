@@ -0,0 +1,2 @@
+ALTER TABLE plant_log DROP CONSTRAINT plant_log_event_type_fkey;
+DROP TABLE event_type;
@@ -0,0 +1,21 @@
+-- Values match the plants.EventType constants
+CREATE TABLE event_type (
+       id INTEGER PRIMARY KEY,
+       name VARCHAR(64) NOT NULL UNIQUE
+);
+
+INSERT INTO event_type (id, name) VALUES
+       (0, 'note'),
+       (1, 'watering'),
+       (2, 'fertilizing'),
+       (3, 'repotting'),
+       (4, 'pruning'),
+       (5, 'pest_treatment'),
+       (6, 'flowering'),
+       (7, 'harvest'),
+       (8, 'propagation'),
+       (9, 'misting');
+
+ALTER TABLE plant_log
+      ADD CONSTRAINT plant_log_event_type_fkey
+      FOREIGN KEY (event_type) REFERENCES event_type(id);
@@ -0,0 +1,7 @@
+-- Existing plants are considered created when the migration is applied
+ALTER TABLE plant
+      ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+-- Keyset pagination of the plant list
+CREATE INDEX plant_common_name_idx ON plant (common_name, id);
+CREATE INDEX plant_created_at_idx ON plant (created_at, id);
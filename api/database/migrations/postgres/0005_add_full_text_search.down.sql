@@ -0,0 +1,4 @@
+DROP INDEX plant_log_search_idx;
+DROP INDEX plant_search_idx;
+DROP TEXT SEARCH CONFIGURATION hortus_french;
+-- The unaccent extension may be used by something else, it is kept
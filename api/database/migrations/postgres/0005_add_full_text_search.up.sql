@@ -0,0 +1,18 @@
+-- French text search ignoring accents
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE TEXT SEARCH CONFIGURATION hortus_french (COPY = french);
+ALTER TEXT SEARCH CONFIGURATION hortus_french
+      ALTER MAPPING FOR hword, hword_part, word WITH unaccent, french_stem;
+
+-- The indexed expressions must be the ones of the search queries
+CREATE INDEX plant_search_idx ON plant USING GIN (
+       to_tsvector(
+              'hortus_french',
+              common_name || ' ' ||
+              coalesce(generic_name, '') || ' ' ||
+              coalesce(specific_name, '')
+       )
+);
+CREATE INDEX plant_log_search_idx ON plant_log USING GIN (
+       to_tsvector('hortus_french', description)
+);
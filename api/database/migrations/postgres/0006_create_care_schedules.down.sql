@@ -0,0 +1,2 @@
+DROP INDEX plant_log_event_idx;
+DROP TABLE care_schedule;
@@ -0,0 +1,21 @@
+-- Seasons are months, from 1 (January) to 12 (December). A season ending before
+-- it starts spans the new year.
+CREATE TABLE care_schedule (
+       id SERIAL PRIMARY KEY,
+       plant_id INTEGER NOT NULL,
+       event_type INTEGER NOT NULL,
+       interval_days INTEGER NOT NULL,
+       season_start INTEGER NOT NULL DEFAULT 1,
+       season_end INTEGER NOT NULL DEFAULT 12,
+       created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+       FOREIGN KEY (plant_id) REFERENCES plant(id) ON DELETE CASCADE,
+       FOREIGN KEY (event_type) REFERENCES event_type(id),
+       CHECK (interval_days > 0),
+       CHECK (season_start BETWEEN 1 AND 12),
+       CHECK (season_end BETWEEN 1 AND 12)
+);
+
+CREATE INDEX care_schedule_plant_id_idx ON care_schedule (plant_id);
+
+-- Latest log entry of an event type of a plant, when the care was last done
+CREATE INDEX plant_log_event_idx ON plant_log (plant_id, event_type, occurred_at);
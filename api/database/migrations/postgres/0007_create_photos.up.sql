@@ -0,0 +1,18 @@
+-- Photos of plants and log entries. The images are kept in a blob store, under
+-- blob_key. A photo of a deleted log entry is kept as a photo of the plant.
+CREATE TABLE photo (
+       id SERIAL PRIMARY KEY,
+       plant_id INTEGER NOT NULL,
+       log_id INTEGER,
+       blob_key VARCHAR(255) NOT NULL UNIQUE,
+       content_type VARCHAR(255) NOT NULL,
+       size BIGINT NOT NULL,
+       caption VARCHAR(255) NOT NULL DEFAULT '',
+       created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+       FOREIGN KEY (plant_id) REFERENCES plant(id) ON DELETE CASCADE,
+       FOREIGN KEY (log_id) REFERENCES plant_log(id) ON DELETE SET NULL,
+       CHECK (size >= 0)
+);
+
+CREATE INDEX photo_plant_id_idx ON photo (plant_id);
+CREATE INDEX photo_log_id_idx ON photo (log_id);
@@ -0,0 +1,3 @@
+ALTER TABLE photo
+      DROP COLUMN taken_at,
+      DROP COLUMN thumbnail_key;
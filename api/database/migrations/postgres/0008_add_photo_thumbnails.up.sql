@@ -0,0 +1,5 @@
+-- Existing photos have no thumbnail, the original image is shown instead.
+-- taken_at is the capture time read from the EXIF metadata, if any.
+ALTER TABLE photo
+      ADD COLUMN thumbnail_key VARCHAR(255) NOT NULL DEFAULT '',
+      ADD COLUMN taken_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+DROP INDEX plant_owner_id_idx;
+ALTER TABLE plant DROP COLUMN owner_id;
+DROP TABLE "user";
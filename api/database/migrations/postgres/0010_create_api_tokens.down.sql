@@ -0,0 +1,2 @@
+DROP INDEX api_token_user_id_idx;
+DROP TABLE api_token;
@@ -0,0 +1,15 @@
+-- Free-form tags of the plants, such as "succulent" or "toxic-to-cats", shared
+-- by all the households. See plants.ParseTag for the format of their names.
+CREATE TABLE tag (
+       id SERIAL PRIMARY KEY,
+       name VARCHAR(32) NOT NULL UNIQUE,
+       CHECK (name <> '')
+);
+
+CREATE TABLE plant_tag (
+       plant_id INTEGER NOT NULL REFERENCES plant(id) ON DELETE CASCADE,
+       tag_id INTEGER NOT NULL REFERENCES tag(id) ON DELETE CASCADE,
+       PRIMARY KEY (plant_id, tag_id)
+);
+
+CREATE INDEX plant_tag_tag_id_idx ON plant_tag (tag_id);
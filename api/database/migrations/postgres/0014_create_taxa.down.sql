@@ -0,0 +1,27 @@
+DROP TRIGGER taxon_search_vector_update ON taxon;
+DROP FUNCTION taxon_search_vector_update();
+DROP TRIGGER plant_search_vector_update ON plant;
+DROP FUNCTION plant_search_vector_update();
+DROP INDEX plant_search_idx;
+ALTER TABLE plant DROP COLUMN search_vector;
+DROP FUNCTION plant_search_vector(TEXT, INTEGER);
+
+-- The plants get back the genus and the species of their taxon
+ALTER TABLE plant ADD COLUMN generic_name VARCHAR(255);
+ALTER TABLE plant ADD COLUMN specific_name VARCHAR(255);
+UPDATE plant SET generic_name = t.genus, specific_name = t.species
+FROM taxon t
+WHERE t.id = plant.taxon_id;
+
+CREATE INDEX plant_search_idx ON plant USING GIN (
+       to_tsvector(
+              'hortus_french',
+              common_name || ' ' ||
+              coalesce(generic_name, '') || ' ' ||
+              coalesce(specific_name, '')
+       )
+);
+
+DROP INDEX plant_taxon_id_idx;
+ALTER TABLE plant DROP COLUMN taxon_id;
+DROP TABLE taxon;
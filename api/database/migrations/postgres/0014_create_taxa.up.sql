@@ -0,0 +1,138 @@
+-- Botanical taxa of the plants, named once instead of on every plant. Missing
+-- parts of the names are empty rather than NULL so that taxa are unique.
+CREATE TABLE taxon (
+       id SERIAL PRIMARY KEY,
+       family VARCHAR(255) NOT NULL DEFAULT '',
+       genus VARCHAR(255) NOT NULL,
+       species VARCHAR(255) NOT NULL DEFAULT '',
+       infraspecific_rank VARCHAR(8) NOT NULL DEFAULT '',
+       infraspecific_name VARCHAR(255) NOT NULL DEFAULT '',
+       cultivar VARCHAR(255) NOT NULL DEFAULT '',
+       CHECK (genus <> ''),
+       CHECK (infraspecific_rank IN ('', 'subsp.', 'var.', 'f.')),
+       CHECK ((infraspecific_rank = '') = (infraspecific_name = '')),
+       CHECK (infraspecific_rank = '' OR species <> ''),
+       UNIQUE (genus, species, infraspecific_rank, infraspecific_name, cultivar)
+);
+
+-- A species cannot make a taxon without its genus: the plants named so keep it
+-- at the end of their common name rather than losing it
+INSERT INTO migration_note (message)
+SELECT count(*) || ' plant(s) without genus kept their species in their common name'
+FROM plant
+WHERE trim(coalesce(generic_name, '')) = ''
+      AND trim(coalesce(specific_name, '')) <> ''
+HAVING count(*) > 0;
+
+UPDATE plant
+SET common_name = substr(
+       common_name || ' (' || trim(specific_name) || ')',
+       1,
+       255
+)
+WHERE trim(coalesce(generic_name, '')) = ''
+      AND trim(coalesce(specific_name, '')) <> '';
+
+-- The names of the plants become taxa, spelled as plants.NormalizeTaxon does:
+-- the genus capitalized, the species in lower case, and the hybrid marker typed
+-- as "x " written as the multiplication sign, without space
+UPDATE plant
+SET generic_name = trim(generic_name),
+    specific_name = lower(trim(coalesce(specific_name, '')))
+WHERE trim(coalesce(generic_name, '')) <> '';
+
+UPDATE plant SET generic_name = '×' || substr(generic_name, 3)
+WHERE lower(substr(generic_name, 1, 2)) = 'x ';
+UPDATE plant SET generic_name = '×' || ltrim(substr(generic_name, 2))
+WHERE generic_name LIKE '×%';
+UPDATE plant SET specific_name = '×' || substr(specific_name, 3)
+WHERE substr(specific_name, 1, 2) = 'x ';
+UPDATE plant SET specific_name = '×' || ltrim(substr(specific_name, 2))
+WHERE specific_name LIKE '×%';
+
+UPDATE plant
+SET generic_name = CASE
+       WHEN generic_name LIKE '×%' THEN
+              '×' || upper(substr(generic_name, 2, 1)) ||
+              lower(substr(generic_name, 3))
+       ELSE
+              upper(substr(generic_name, 1, 1)) || lower(substr(generic_name, 2))
+       END
+WHERE generic_name <> '';
+
+INSERT INTO taxon (genus, species)
+SELECT DISTINCT generic_name, specific_name
+FROM plant
+WHERE trim(coalesce(generic_name, '')) <> '';
+
+-- Names that do not follow the rules of botanical nomenclature, see
+-- checkNomenclature, are kept as is, to be fixed by the users
+INSERT INTO migration_note (message)
+SELECT count(*) || ' taxon name(s) are not valid, see the "taxa check" command'
+FROM taxon
+WHERE genus !~ '^×?[A-Z][a-zäëïöü]+$'
+      OR species <> '' AND (
+             species !~ '^×?[a-zäëïöü]+(-[a-zäëïöü]+)*$'
+             OR length(ltrim(species, '×')) < 2
+      )
+HAVING count(*) > 0;
+
+ALTER TABLE plant ADD COLUMN taxon_id INTEGER REFERENCES taxon(id);
+
+UPDATE plant SET taxon_id = t.id
+FROM taxon t
+WHERE trim(coalesce(plant.generic_name, '')) <> ''
+      AND t.genus = plant.generic_name
+      AND t.species = plant.specific_name
+      AND t.infraspecific_rank = '' AND t.cultivar = '';
+
+CREATE INDEX plant_taxon_id_idx ON plant (taxon_id);
+
+-- The names of the plants are no longer all in the plant table, so they cannot
+-- be indexed by an expression anymore. The plants keep their text search vector
+-- in a column instead, kept up to date when their common name or their taxon
+-- change, and when the names of their taxon change.
+DROP INDEX plant_search_idx;
+ALTER TABLE plant DROP COLUMN generic_name;
+ALTER TABLE plant DROP COLUMN specific_name;
+
+CREATE FUNCTION plant_search_vector(TEXT, INTEGER) RETURNS tsvector AS $$
+       SELECT to_tsvector(
+              'hortus_french',
+              $1 || ' ' || coalesce(
+                     (SELECT t.genus || ' ' || t.species
+                      FROM taxon t
+                      WHERE t.id = $2),
+                     ''
+              )
+       );
+$$ LANGUAGE SQL STABLE;
+
+ALTER TABLE plant ADD COLUMN search_vector tsvector;
+UPDATE plant SET search_vector = plant_search_vector(common_name, taxon_id);
+ALTER TABLE plant ALTER COLUMN search_vector SET NOT NULL;
+CREATE INDEX plant_search_idx ON plant USING GIN (search_vector);
+
+CREATE FUNCTION plant_search_vector_update() RETURNS trigger AS $$
+BEGIN
+       NEW.search_vector := plant_search_vector(NEW.common_name, NEW.taxon_id);
+       RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER plant_search_vector_update
+BEFORE INSERT OR UPDATE OF common_name, taxon_id ON plant
+FOR EACH ROW EXECUTE FUNCTION plant_search_vector_update();
+
+CREATE FUNCTION taxon_search_vector_update() RETURNS trigger AS $$
+BEGIN
+       UPDATE plant
+       SET search_vector = plant_search_vector(common_name, taxon_id)
+       WHERE taxon_id = NEW.id;
+       RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER taxon_search_vector_update
+AFTER UPDATE OF genus, species ON taxon
+FOR EACH ROW EXECUTE FUNCTION taxon_search_vector_update();
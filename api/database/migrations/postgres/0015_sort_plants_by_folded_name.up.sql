@@ -0,0 +1,4 @@
+-- Plants are sorted by name ignoring case, comparing bytes like SQLite does
+-- rather than following the collation of the database
+DROP INDEX plant_common_name_idx;
+CREATE INDEX plant_common_name_idx ON plant ((lower(common_name)) COLLATE "C", id);
@@ -0,0 +1,17 @@
+-- Tables may already exist on installations created before migrations, in
+-- which case they are kept as is.
+CREATE TABLE IF NOT EXISTS plant (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       common_name VARCHAR(255) NOT NULL,
+       generic_name VARCHAR(255),
+       specific_name VARCHAR(255),
+       CHECK (common_name <> '')
+);
+
+CREATE TABLE IF NOT EXISTS plant_log (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       plant_id INTEGER NOT NULL,
+       description VARCHAR(255) NOT NULL,
+       event_type INTEGER NOT NULL,
+       FOREIGN KEY (plant_id) REFERENCES plant(id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE plant_log DROP COLUMN occurred_at;
+ALTER TABLE plant_log DROP COLUMN recorded_at;
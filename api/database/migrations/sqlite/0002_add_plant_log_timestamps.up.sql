@@ -0,0 +1,8 @@
+-- SQLite does not allow adding a column whose default is the current time,
+-- existing entries are considered to have happened when the migration is
+-- applied.
+ALTER TABLE plant_log ADD COLUMN occurred_at TIMESTAMP NOT NULL DEFAULT 0;
+ALTER TABLE plant_log ADD COLUMN recorded_at TIMESTAMP NOT NULL DEFAULT 0;
+UPDATE plant_log
+SET occurred_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    recorded_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
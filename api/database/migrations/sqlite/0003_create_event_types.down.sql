@@ -0,0 +1,16 @@
+CREATE TABLE plant_log_old (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       plant_id INTEGER NOT NULL,
+       description VARCHAR(255) NOT NULL,
+       event_type INTEGER NOT NULL,
+       occurred_at TIMESTAMP NOT NULL,
+       recorded_at TIMESTAMP NOT NULL,
+       FOREIGN KEY (plant_id) REFERENCES plant(id)
+);
+INSERT INTO plant_log_old
+SELECT id, plant_id, description, event_type, occurred_at, recorded_at
+FROM plant_log;
+DROP TABLE plant_log;
+ALTER TABLE plant_log_old RENAME TO plant_log;
+
+DROP TABLE event_type;
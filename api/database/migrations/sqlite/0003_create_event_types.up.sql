@@ -0,0 +1,34 @@
+-- Values match the plants.EventType constants
+CREATE TABLE event_type (
+       id INTEGER PRIMARY KEY,
+       name VARCHAR(64) NOT NULL UNIQUE
+);
+
+INSERT INTO event_type (id, name) VALUES
+       (0, 'note'),
+       (1, 'watering'),
+       (2, 'fertilizing'),
+       (3, 'repotting'),
+       (4, 'pruning'),
+       (5, 'pest_treatment'),
+       (6, 'flowering'),
+       (7, 'harvest'),
+       (8, 'propagation'),
+       (9, 'misting');
+
+-- SQLite cannot add a foreign key to an existing table, it is rebuilt
+CREATE TABLE plant_log_new (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       plant_id INTEGER NOT NULL,
+       description VARCHAR(255) NOT NULL,
+       event_type INTEGER NOT NULL,
+       occurred_at TIMESTAMP NOT NULL,
+       recorded_at TIMESTAMP NOT NULL,
+       FOREIGN KEY (plant_id) REFERENCES plant(id),
+       FOREIGN KEY (event_type) REFERENCES event_type(id)
+);
+INSERT INTO plant_log_new
+SELECT id, plant_id, description, event_type, occurred_at, recorded_at
+FROM plant_log;
+DROP TABLE plant_log;
+ALTER TABLE plant_log_new RENAME TO plant_log;
@@ -0,0 +1,3 @@
+DROP INDEX plant_created_at_idx;
+DROP INDEX plant_common_name_idx;
+ALTER TABLE plant DROP COLUMN created_at;
@@ -0,0 +1,10 @@
+-- SQLite does not allow adding a column whose default is the current time,
+-- existing plants are considered created when the migration is applied. The
+-- creation time is stored in the format of strftime so that it can be compared
+-- as text.
+ALTER TABLE plant ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT 0;
+UPDATE plant SET created_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+
+-- Keyset pagination of the plant list
+CREATE INDEX plant_common_name_idx ON plant (common_name, id);
+CREATE INDEX plant_created_at_idx ON plant (created_at, id);
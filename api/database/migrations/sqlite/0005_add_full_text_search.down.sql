@@ -0,0 +1,8 @@
+DROP TRIGGER plant_log_fts_update;
+DROP TRIGGER plant_log_fts_delete;
+DROP TRIGGER plant_log_fts_insert;
+DROP TRIGGER plant_fts_update;
+DROP TRIGGER plant_fts_delete;
+DROP TRIGGER plant_fts_insert;
+DROP TABLE plant_log_fts;
+DROP TABLE plant_fts;
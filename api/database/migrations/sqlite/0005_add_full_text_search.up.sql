@@ -0,0 +1,52 @@
+-- Full text indexes of plants and log entries ignoring accents, kept up to date
+-- by triggers
+CREATE VIRTUAL TABLE plant_fts USING fts5(
+       common_name,
+       generic_name,
+       specific_name,
+       content='plant',
+       content_rowid='id',
+       tokenize='unicode61 remove_diacritics 2'
+);
+CREATE VIRTUAL TABLE plant_log_fts USING fts5(
+       description,
+       content='plant_log',
+       content_rowid='id',
+       tokenize='unicode61 remove_diacritics 2'
+);
+INSERT INTO plant_fts (plant_fts) VALUES ('rebuild');
+INSERT INTO plant_log_fts (plant_log_fts) VALUES ('rebuild');
+
+CREATE TRIGGER plant_fts_insert AFTER INSERT ON plant BEGIN
+       INSERT INTO plant_fts (rowid, common_name, generic_name, specific_name)
+       VALUES (new.id, new.common_name, new.generic_name, new.specific_name);
+END;
+CREATE TRIGGER plant_fts_delete AFTER DELETE ON plant BEGIN
+       INSERT INTO plant_fts
+       (plant_fts, rowid, common_name, generic_name, specific_name)
+       VALUES
+       ('delete', old.id, old.common_name, old.generic_name, old.specific_name);
+END;
+CREATE TRIGGER plant_fts_update AFTER UPDATE ON plant BEGIN
+       INSERT INTO plant_fts
+       (plant_fts, rowid, common_name, generic_name, specific_name)
+       VALUES
+       ('delete', old.id, old.common_name, old.generic_name, old.specific_name);
+       INSERT INTO plant_fts (rowid, common_name, generic_name, specific_name)
+       VALUES (new.id, new.common_name, new.generic_name, new.specific_name);
+END;
+
+CREATE TRIGGER plant_log_fts_insert AFTER INSERT ON plant_log BEGIN
+       INSERT INTO plant_log_fts (rowid, description)
+       VALUES (new.id, new.description);
+END;
+CREATE TRIGGER plant_log_fts_delete AFTER DELETE ON plant_log BEGIN
+       INSERT INTO plant_log_fts (plant_log_fts, rowid, description)
+       VALUES ('delete', old.id, old.description);
+END;
+CREATE TRIGGER plant_log_fts_update AFTER UPDATE ON plant_log BEGIN
+       INSERT INTO plant_log_fts (plant_log_fts, rowid, description)
+       VALUES ('delete', old.id, old.description);
+       INSERT INTO plant_log_fts (rowid, description)
+       VALUES (new.id, new.description);
+END;
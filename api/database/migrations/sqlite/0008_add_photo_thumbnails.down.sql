@@ -0,0 +1,2 @@
+ALTER TABLE photo DROP COLUMN taken_at;
+ALTER TABLE photo DROP COLUMN thumbnail_key;
@@ -0,0 +1,17 @@
+-- Accounts of the users, whose passwords are stored as bcrypt hashes. Usernames
+-- are unique regardless of case.
+CREATE TABLE "user" (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       username VARCHAR(64) NOT NULL,
+       password_hash VARCHAR(255) NOT NULL,
+       created_at TIMESTAMP NOT NULL,
+       CHECK (username <> '')
+);
+
+CREATE UNIQUE INDEX user_username_idx ON "user" (lower(username));
+
+-- Existing plants have no owner, they are given to the first user
+ALTER TABLE plant
+      ADD COLUMN owner_id INTEGER REFERENCES "user"(id) ON DELETE CASCADE;
+
+CREATE INDEX plant_owner_id_idx ON plant (owner_id);
@@ -0,0 +1,15 @@
+-- Bearer tokens of the API, issued to a user or, without user, to a service
+-- such as the web server. Only the SHA-256 hash of the tokens is stored.
+CREATE TABLE api_token (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       user_id INTEGER REFERENCES "user"(id) ON DELETE CASCADE,
+       name VARCHAR(64) NOT NULL,
+       token_hash CHAR(64) NOT NULL UNIQUE,
+       scope VARCHAR(16) NOT NULL,
+       created_at TIMESTAMP NOT NULL,
+       revoked_at TIMESTAMP,
+       CHECK (name <> ''),
+       CHECK (scope IN ('read', 'write'))
+);
+
+CREATE INDEX api_token_user_id_idx ON api_token (user_id);
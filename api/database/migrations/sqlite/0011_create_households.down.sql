@@ -0,0 +1,20 @@
+ALTER TABLE plant
+      ADD COLUMN owner_id INTEGER REFERENCES "user"(id) ON DELETE CASCADE;
+
+-- Plants go back to the first owner of their household
+UPDATE plant SET owner_id = (
+       SELECT m.user_id
+       FROM household_member m
+       WHERE m.household_id = plant.household_id AND m.role = 'owner'
+       ORDER BY m.joined_at, m.user_id
+       LIMIT 1
+);
+
+CREATE INDEX plant_owner_id_idx ON plant (owner_id);
+
+DROP INDEX plant_household_id_idx;
+ALTER TABLE plant DROP COLUMN household_id;
+
+DROP TABLE household_invitation;
+DROP TABLE household_member;
+DROP TABLE household;
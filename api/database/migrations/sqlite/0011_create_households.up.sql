@@ -0,0 +1,54 @@
+-- Households own the plants and are shared by their members, each member
+-- having a role.
+CREATE TABLE household (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       name VARCHAR(255) NOT NULL,
+       created_at TIMESTAMP NOT NULL,
+       CHECK (name <> '')
+);
+
+CREATE TABLE household_member (
+       household_id INTEGER NOT NULL REFERENCES household(id) ON DELETE CASCADE,
+       user_id INTEGER NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+       role VARCHAR(16) NOT NULL,
+       joined_at TIMESTAMP NOT NULL,
+       PRIMARY KEY (household_id, user_id),
+       CHECK (role IN ('owner', 'caretaker', 'viewer'))
+);
+
+CREATE INDEX household_member_user_id_idx ON household_member (user_id);
+
+-- Invitations to join a household, used once. Only the SHA-256 hash of the
+-- secret of their links is stored.
+CREATE TABLE household_invitation (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       household_id INTEGER NOT NULL REFERENCES household(id) ON DELETE CASCADE,
+       token_hash CHAR(64) NOT NULL UNIQUE,
+       role VARCHAR(16) NOT NULL,
+       created_at TIMESTAMP NOT NULL,
+       expires_at TIMESTAMP NOT NULL,
+       accepted_by INTEGER REFERENCES "user"(id) ON DELETE SET NULL,
+       accepted_at TIMESTAMP,
+       CHECK (role IN ('owner', 'caretaker', 'viewer'))
+);
+
+CREATE INDEX household_invitation_household_id_idx
+       ON household_invitation (household_id);
+
+-- Each user gets a household of its own, of same identifier, which takes over
+-- the plants of the user
+INSERT INTO household (id, name, created_at)
+SELECT id, username, created_at FROM "user";
+
+INSERT INTO household_member (household_id, user_id, role, joined_at)
+SELECT id, id, 'owner', created_at FROM "user";
+
+ALTER TABLE plant
+      ADD COLUMN household_id INTEGER REFERENCES household(id);
+
+UPDATE plant SET household_id = owner_id;
+
+DROP INDEX plant_owner_id_idx;
+ALTER TABLE plant DROP COLUMN owner_id;
+
+CREATE INDEX plant_household_id_idx ON plant (household_id);
@@ -0,0 +1,9 @@
+-- The moves of the plants become notes
+UPDATE plant_log SET event_type = 0 WHERE event_type = 10;
+DELETE FROM care_schedule WHERE event_type = 10;
+DELETE FROM event_type WHERE id = 10;
+
+DROP INDEX plant_location_id_idx;
+ALTER TABLE plant DROP COLUMN location_id;
+
+DROP TABLE location;
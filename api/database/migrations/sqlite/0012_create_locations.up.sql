@@ -0,0 +1,23 @@
+-- Locations of the plants of a household, in a hierarchy of at most three
+-- levels: sites contain rooms and beds, which contain shelves. Only sites have
+-- no parent location.
+CREATE TABLE location (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       household_id INTEGER NOT NULL REFERENCES household(id) ON DELETE CASCADE,
+       parent_id INTEGER REFERENCES location(id),
+       name VARCHAR(255) NOT NULL,
+       kind VARCHAR(16) NOT NULL,
+       CHECK (name <> ''),
+       CHECK (kind IN ('site', 'room', 'bed', 'shelf')),
+       CHECK ((kind = 'site') = (parent_id IS NULL))
+);
+
+CREATE INDEX location_household_id_idx ON location (household_id);
+CREATE INDEX location_parent_id_idx ON location (parent_id);
+
+ALTER TABLE plant ADD COLUMN location_id INTEGER REFERENCES location(id);
+
+CREATE INDEX plant_location_id_idx ON plant (location_id);
+
+-- Value matches plants.EventMove, the moves of the plants are logged
+INSERT INTO event_type (id, name) VALUES (10, 'move');
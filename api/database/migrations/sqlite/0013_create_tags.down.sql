@@ -0,0 +1,2 @@
+DROP TABLE plant_tag;
+DROP TABLE tag;
@@ -0,0 +1,44 @@
+DROP TRIGGER plant_fts_update;
+DROP TRIGGER plant_fts_delete;
+DROP TRIGGER plant_fts_insert;
+DROP TABLE plant_fts;
+
+-- The plants get back the genus and the species of their taxon
+ALTER TABLE plant ADD COLUMN generic_name VARCHAR(255);
+ALTER TABLE plant ADD COLUMN specific_name VARCHAR(255);
+UPDATE plant SET
+       generic_name = (SELECT genus FROM taxon WHERE id = plant.taxon_id),
+       specific_name = (SELECT species FROM taxon WHERE id = plant.taxon_id);
+
+CREATE VIRTUAL TABLE plant_fts USING fts5(
+       common_name,
+       generic_name,
+       specific_name,
+       content='plant',
+       content_rowid='id',
+       tokenize='unicode61 remove_diacritics 2'
+);
+INSERT INTO plant_fts (plant_fts) VALUES ('rebuild');
+
+CREATE TRIGGER plant_fts_insert AFTER INSERT ON plant BEGIN
+       INSERT INTO plant_fts (rowid, common_name, generic_name, specific_name)
+       VALUES (new.id, new.common_name, new.generic_name, new.specific_name);
+END;
+CREATE TRIGGER plant_fts_delete AFTER DELETE ON plant BEGIN
+       INSERT INTO plant_fts
+       (plant_fts, rowid, common_name, generic_name, specific_name)
+       VALUES
+       ('delete', old.id, old.common_name, old.generic_name, old.specific_name);
+END;
+CREATE TRIGGER plant_fts_update AFTER UPDATE ON plant BEGIN
+       INSERT INTO plant_fts
+       (plant_fts, rowid, common_name, generic_name, specific_name)
+       VALUES
+       ('delete', old.id, old.common_name, old.generic_name, old.specific_name);
+       INSERT INTO plant_fts (rowid, common_name, generic_name, specific_name)
+       VALUES (new.id, new.common_name, new.generic_name, new.specific_name);
+END;
+
+DROP INDEX plant_taxon_id_idx;
+ALTER TABLE plant DROP COLUMN taxon_id;
+DROP TABLE taxon;
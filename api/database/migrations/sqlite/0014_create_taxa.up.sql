@@ -0,0 +1,141 @@
+-- Botanical taxa of the plants, named once instead of on every plant. Missing
+-- parts of the names are empty rather than NULL so that taxa are unique.
+CREATE TABLE taxon (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       family VARCHAR(255) NOT NULL DEFAULT '',
+       genus VARCHAR(255) NOT NULL,
+       species VARCHAR(255) NOT NULL DEFAULT '',
+       infraspecific_rank VARCHAR(8) NOT NULL DEFAULT '',
+       infraspecific_name VARCHAR(255) NOT NULL DEFAULT '',
+       cultivar VARCHAR(255) NOT NULL DEFAULT '',
+       CHECK (genus <> ''),
+       CHECK (infraspecific_rank IN ('', 'subsp.', 'var.', 'f.')),
+       CHECK ((infraspecific_rank = '') = (infraspecific_name = '')),
+       CHECK (infraspecific_rank = '' OR species <> ''),
+       UNIQUE (genus, species, infraspecific_rank, infraspecific_name, cultivar)
+);
+
+-- A species cannot make a taxon without its genus: the plants named so keep it
+-- at the end of their common name rather than losing it
+INSERT INTO migration_note (message)
+SELECT count(*) || ' plant(s) without genus kept their species in their common name'
+FROM plant
+WHERE trim(coalesce(generic_name, '')) = ''
+      AND trim(coalesce(specific_name, '')) <> ''
+HAVING count(*) > 0;
+
+UPDATE plant
+SET common_name = substr(
+       common_name || ' (' || trim(specific_name) || ')',
+       1,
+       255
+)
+WHERE trim(coalesce(generic_name, '')) = ''
+      AND trim(coalesce(specific_name, '')) <> '';
+
+-- The names of the plants become taxa, spelled as plants.NormalizeTaxon does:
+-- the genus capitalized, the species in lower case, and the hybrid marker typed
+-- as "x " written as the multiplication sign, without space
+UPDATE plant
+SET generic_name = trim(generic_name),
+    specific_name = lower(trim(coalesce(specific_name, '')))
+WHERE trim(coalesce(generic_name, '')) <> '';
+
+UPDATE plant SET generic_name = '×' || substr(generic_name, 3)
+WHERE lower(substr(generic_name, 1, 2)) = 'x ';
+UPDATE plant SET generic_name = '×' || ltrim(substr(generic_name, 2))
+WHERE generic_name LIKE '×%';
+UPDATE plant SET specific_name = '×' || substr(specific_name, 3)
+WHERE substr(specific_name, 1, 2) = 'x ';
+UPDATE plant SET specific_name = '×' || ltrim(substr(specific_name, 2))
+WHERE specific_name LIKE '×%';
+
+UPDATE plant
+SET generic_name = CASE
+       WHEN generic_name LIKE '×%' THEN
+              '×' || upper(substr(generic_name, 2, 1)) ||
+              lower(substr(generic_name, 3))
+       ELSE
+              upper(substr(generic_name, 1, 1)) || lower(substr(generic_name, 2))
+       END
+WHERE generic_name <> '';
+
+INSERT INTO taxon (genus, species)
+SELECT DISTINCT generic_name, specific_name
+FROM plant
+WHERE trim(coalesce(generic_name, '')) <> '';
+
+-- Names that do not follow the rules of botanical nomenclature, see
+-- checkNomenclature, are kept as is, to be fixed by the users
+INSERT INTO migration_note (message)
+SELECT count(*) || ' taxon name(s) are not valid, see the "taxa check" command'
+FROM (
+       SELECT ltrim(genus, '×') AS genus, ltrim(species, '×') AS species
+       FROM taxon
+)
+WHERE substr(genus, 1, 1) NOT GLOB '[A-Z]'
+      OR length(genus) < 2
+      OR substr(genus, 2) GLOB '*[^a-zäëïöü]*'
+      OR species <> '' AND (
+             length(species) < 2
+             OR species GLOB '*[^a-zäëïöü-]*'
+             OR species GLOB '-*'
+             OR species GLOB '*-'
+             OR species GLOB '*--*'
+      )
+HAVING count(*) > 0;
+
+ALTER TABLE plant ADD COLUMN taxon_id INTEGER REFERENCES taxon(id);
+
+UPDATE plant SET taxon_id = (
+       SELECT t.id
+       FROM taxon t
+       WHERE t.genus = plant.generic_name
+             AND t.species = plant.specific_name
+             AND t.infraspecific_rank = '' AND t.cultivar = ''
+)
+WHERE trim(coalesce(generic_name, '')) <> '';
+
+CREATE INDEX plant_taxon_id_idx ON plant (taxon_id);
+
+-- The full text index of the plants keeps its own copy of the names, since they
+-- are no longer all in the plant table
+DROP TRIGGER plant_fts_update;
+DROP TRIGGER plant_fts_delete;
+DROP TRIGGER plant_fts_insert;
+DROP TABLE plant_fts;
+
+ALTER TABLE plant DROP COLUMN generic_name;
+ALTER TABLE plant DROP COLUMN specific_name;
+
+CREATE VIRTUAL TABLE plant_fts USING fts5(
+       common_name,
+       genus,
+       species,
+       tokenize='unicode61 remove_diacritics 2'
+);
+INSERT INTO plant_fts (rowid, common_name, genus, species)
+SELECT p.id, p.common_name, t.genus, t.species
+FROM plant p
+LEFT JOIN taxon t ON t.id = p.taxon_id;
+
+CREATE TRIGGER plant_fts_insert AFTER INSERT ON plant BEGIN
+       INSERT INTO plant_fts (rowid, common_name, genus, species)
+       VALUES (
+              new.id,
+              new.common_name,
+              (SELECT genus FROM taxon WHERE id = new.taxon_id),
+              (SELECT species FROM taxon WHERE id = new.taxon_id)
+       );
+END;
+CREATE TRIGGER plant_fts_delete AFTER DELETE ON plant BEGIN
+       DELETE FROM plant_fts WHERE rowid = old.id;
+END;
+CREATE TRIGGER plant_fts_update AFTER UPDATE OF common_name, taxon_id ON plant
+BEGIN
+       UPDATE plant_fts
+       SET common_name = new.common_name,
+           genus = (SELECT genus FROM taxon WHERE id = new.taxon_id),
+           species = (SELECT species FROM taxon WHERE id = new.taxon_id)
+       WHERE rowid = new.id;
+END;
@@ -0,0 +1,2 @@
+DROP INDEX plant_common_name_idx;
+CREATE INDEX plant_common_name_idx ON plant (common_name, id);
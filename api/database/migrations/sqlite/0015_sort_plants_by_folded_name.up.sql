@@ -0,0 +1,3 @@
+-- Plants are sorted by name ignoring case
+DROP INDEX plant_common_name_idx;
+CREATE INDEX plant_common_name_idx ON plant (lower(common_name), id);
@@ -6,51 +6,42 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mgmu/hortus/internal/plants"
-	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Schema holding the tables of Hortus.
+const postgresSchema = "hortus_schema"
+
 type PostgresDatabase struct {
 	pool *pgxpool.Pool
+	// Maximum duration of a query, no limit if zero. Migrations are not
+	// limited.
+	QueryTimeout time.Duration
 }
 
 // Connect attempts to connect to the Postgres database and to set the
-// appropriate schema for future queries.
-func (db *PostgresDatabase) Connect() error {
+// appropriate schema for future queries. The schema itself is created by the
+// migrations, see MigrateUp.
+func (db *PostgresDatabase) Connect(ctx context.Context) error {
 	dburl := os.Getenv("HORTUS_DB_URL")
 	if dburl == "" {
 		return errors.New("database: Database URL not set")
 	}
-	pool, err := pgxpool.New(context.Background(), dburl)
-	if err != nil {
-		return err
-	}
-	db.pool = pool
-
-	if db.pool == nil {
-		log.Fatal("db.pool is nil")
-	}
-
-	query := `
-SELECT EXISTS (
-SELECT FROM pg_tables
-WHERE schemaname = 'hortus_schema'
-AND (tablename = 'plant' OR tablename = 'plant_log')
-);`
-	var exist bool
-	err = db.pool.QueryRow(context.Background(), query).Scan(&exist)
+	config, err := pgxpool.ParseConfig(dburl)
 	if err != nil {
-		return err
-	}
-	if !exist {
-		return errors.New("database: Schema and tables not found")
+		return pgError(err)
 	}
-
-	query = `SET search_path TO hortus_schema;`
-	_, err = db.pool.Exec(context.Background(), query)
+	// Set for every connection of the pool, not only the first one
+	config.ConnConfig.RuntimeParams["search_path"] = postgresSchema
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
-		return err
+		return pgError(err)
 	}
+	db.pool = pool
 	return nil
 }
 
@@ -61,65 +52,209 @@ func (db *PostgresDatabase) Close() error {
 	return nil
 }
 
-// GetPlantsShortDescription queries the database for the identifier and common
-// names of all plants in the 'plants' table and returns them in a slice.
-func (db *PostgresDatabase) GetPlantsShortDescription() ([]plants.PlantShortDesc, error) {
-	query := "SELECT id, common_name FROM plant;"
-	rows, _ := db.pool.Query(context.Background(), query)
+// GetPlantsShortDescription queries the database for the identifier, common
+// name and creation date of the plants in the 'plants' table selected by q and
+// returns them in a slice.
+func (db *PostgresDatabase) GetPlantsShortDescription(
+	ctx context.Context,
+	q PlantQuery,
+) ([]plants.PlantShortDesc, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query, args := plantListQuery(
+		q,
+		func(n int) string {
+			return "$" + strconv.Itoa(n)
+		},
+		"ARRAY("+plantTags("plant.id")+")",
+		// The C collation compares bytes, like SQLite and MemoryDatabase,
+		// rather than following the collation of the database
+		func(expr string) string {
+			return "lower(" + expr + `) COLLATE "C"`
+		},
+	)
+	rows, _ := db.pool.Query(ctx, query, args...)
 	plants, err := pgx.CollectRows(
 		rows,
 		pgx.RowToStructByPos[plants.PlantShortDesc],
 	)
 	if err != nil {
-		return nil, err
+		return nil, pgError(err)
 	}
 	return plants, nil
 }
 
 // AddNewPlant attempts to insert a new entry in the 'plants' table with the
-// provided common, generic and specific names of the plant. On success, returns
-// the identifier of the inserted entry and a nil error.
-func (db *PostgresDatabase) AddNewPlant(comm, gen, spe string) (int, error) {
+// provided common name of the plant, of the taxon of identifier taxonId and
+// owned by the household of identifier householdId, or of no taxon and by no
+// one if they are 0. On success, returns the identifier of the inserted entry
+// and a nil error.
+func (db *PostgresDatabase) AddNewPlant(
+	ctx context.Context,
+	householdId int,
+	comm string,
+	taxonId int,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	row := db.pool.QueryRow(
-		context.Background(),
+		ctx,
 		`
-INSERT INTO plant (common_name, generic_name, specific_name)
-VALUES ($1, $2, $3)
+INSERT INTO plant (common_name, taxon_id, household_id)
+VALUES ($1, NULLIF($2, 0), NULLIF($3, 0))
 RETURNING id;`,
 		comm,
-		gen,
-		spe,
+		taxonId,
+		householdId,
 	)
 	var id int
 	err := row.Scan(&id)
 	if err != nil {
-		return 0, err
+		return 0, pgError(err)
 	}
 	return id, nil
 }
 
-// GetPlantNames queries the database for the common, generic and specific names
-// of the plant of given id.
-func (db *PostgresDatabase) GetPlantNames(id int) (string, string, string, error) {
+// GetPlantRole queries the database for the role of the user of identifier
+// userId in the household owning the plant of identifier plantId. The plant is
+// not found if the user is not a member of its household.
+func (db *PostgresDatabase) GetPlantRole(
+	ctx context.Context,
+	userId, plantId int,
+) (plants.Role, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var role plants.Role
+	err := db.pool.QueryRow(
+		ctx,
+		`
+SELECT m.role
+FROM plant p
+JOIN household_member m ON m.household_id = p.household_id
+WHERE p.id=$1 AND m.user_id=$2;`,
+		plantId,
+		userId,
+	).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errPlantNotFound
+	}
+	if err != nil {
+		return "", pgError(err)
+	}
+	return role, nil
+}
+
+// GetPlantNames queries the database for the common name and the taxon of the
+// plant of given id, the zero Taxon if it has none.
+func (db *PostgresDatabase) GetPlantNames(
+	ctx context.Context,
+	id int,
+) (string, plants.Taxon, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	row := db.pool.QueryRow(
-		context.Background(),
-		"SELECT * FROM plant WHERE id=$1;",
+		ctx,
+		`
+SELECT p.common_name, `+taxonColumns+`
+FROM plant p
+LEFT JOIN taxon t ON t.id = p.taxon_id
+WHERE p.id=$1;`,
+		id,
+	)
+	var comm string
+	var t plants.Taxon
+	err := row.Scan(
+		&comm,
+		&t.Id,
+		&t.Family,
+		&t.Genus,
+		&t.Species,
+		&t.Rank,
+		&t.Infraspecies,
+		&t.Cultivar,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", plants.Taxon{}, errPlantNotFound
+	}
+	if err != nil {
+		return "", plants.Taxon{}, pgError(err)
+	}
+	return comm, t, nil
+}
+
+// UpdatePlant replaces the common name and the taxon of the plant of given id,
+// which has no taxon if taxonId is 0.
+func (db *PostgresDatabase) UpdatePlant(
+	ctx context.Context,
+	id int,
+	comm string,
+	taxonId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		"UPDATE plant SET common_name=$2, taxon_id=NULLIF($3, 0) WHERE id=$1;",
 		id,
+		comm,
+		taxonId,
 	)
-	var comm, gen, spe string
-	err := row.Scan(&id, &comm, &gen, &spe)
 	if err != nil {
-		return "", "", "", err
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errPlantNotFound
+	}
+	return nil
+}
+
+// DeletePlant deletes the plant of given id along with all its logs, in a
+// single transaction.
+func (db *PostgresDatabase) DeletePlant(ctx context.Context, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM plant_log WHERE plant_id=$1;", id)
+	if err != nil {
+		return pgError(err)
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM plant WHERE id=$1;", id)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errPlantNotFound
 	}
-	return comm, gen, spe, nil
+	return pgError(tx.Commit(ctx))
 }
 
 // GetPlantsLog queries the database for all the logs of the plant of given
-// identifier and returns them in a slice.
-func (db *PostgresDatabase) GetPlantLogs(id int) ([]plants.PlantLog, error) {
+// identifier and returns them in a slice, in chronological order.
+func (db *PostgresDatabase) GetPlantLogs(
+	ctx context.Context,
+	id int,
+) ([]plants.PlantLog, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	rows, _ := db.pool.Query(
-		context.Background(),
-		"SELECT * FROM plant_log WHERE plant_id=$1;",
+		ctx,
+		`
+SELECT id, plant_id, description, event_type, occurred_at, recorded_at
+FROM plant_log
+WHERE plant_id=$1
+ORDER BY occurred_at, id;`,
 		id,
 	)
 	plantLogs, err := pgx.CollectRows(
@@ -127,29 +262,2137 @@ func (db *PostgresDatabase) GetPlantLogs(id int) ([]plants.PlantLog, error) {
 		pgx.RowToStructByPos[plants.PlantLog],
 	)
 	if err != nil {
-		return nil, err
+		return nil, pgError(err)
 	}
 	return plantLogs, nil
 }
 
 // AddNewPlantLog attempts to insert a new entry in the 'plants_log` table for
-// the plant of given identifier with given description and event type.
-func (db *PostgresDatabase) AddNewPlantLog(id int, desc string, event int) error {
+// the plant of given identifier with given description, event type and time of
+// occurrence. The entry is recorded at the current time. On success, returns
+// the identifier of the entry.
+func (db *PostgresDatabase) AddNewPlantLog(
+	ctx context.Context,
+	id int,
+	desc string,
+	event plants.EventType,
+	occurredAt time.Time,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	// Insert new log entry
 	row := db.pool.QueryRow(
-		context.Background(),
+		ctx,
 		`
-INSERT INTO plant_log (plant_id, description, event_type)
-VALUES ($1, $2, $3)
+INSERT INTO plant_log (plant_id, description, event_type, occurred_at)
+VALUES ($1, $2, $3, $4)
 RETURNING id;`,
 		id,
 		desc,
-		event,
+		int(event),
+		occurredAt,
 	)
 	var logId int
 	err := row.Scan(&logId)
 	if err != nil {
-		return err
+		return 0, pgError(err)
+	}
+	return logId, nil
+}
+
+// GetPlantLog queries the database for the log entry of identifier logId, if it
+// belongs to the plant of identifier plantId.
+func (db *PostgresDatabase) GetPlantLog(
+	ctx context.Context,
+	plantId, logId int,
+) (plants.PlantLog, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT id, plant_id, description, event_type, occurred_at, recorded_at
+FROM plant_log
+WHERE id=$1 AND plant_id=$2;`,
+		logId,
+		plantId,
+	)
+	plantLog, err := pgx.CollectExactlyOneRow(
+		rows,
+		pgx.RowToStructByPos[plants.PlantLog],
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.PlantLog{}, errLogNotFound
+	}
+	if err != nil {
+		return plants.PlantLog{}, pgError(err)
+	}
+	return plantLog, nil
+}
+
+// UpdatePlantLog replaces the description, event type and time of occurrence of
+// the log entry of identifier logId, if it belongs to the plant of identifier
+// plantId.
+func (db *PostgresDatabase) UpdatePlantLog(
+	ctx context.Context,
+	plantId, logId int,
+	desc string,
+	event plants.EventType,
+	occurredAt time.Time,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		`
+UPDATE plant_log
+SET description=$3, event_type=$4, occurred_at=$5
+WHERE id=$1 AND plant_id=$2;`,
+		logId,
+		plantId,
+		desc,
+		int(event),
+		occurredAt,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errLogNotFound
+	}
+	return nil
+}
+
+// DeletePlantLog deletes the log entry of identifier logId, if it belongs to the
+// plant of identifier plantId.
+func (db *PostgresDatabase) DeletePlantLog(
+	ctx context.Context,
+	plantId, logId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		"DELETE FROM plant_log WHERE id=$1 AND plant_id=$2;",
+		logId,
+		plantId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errLogNotFound
+	}
+	return nil
+}
+
+// Search queries the database for the plants whose names and the log entries
+// whose descriptions contain words starting with all the words of query,
+// ignoring accents and using French stemming. Only the plants of the households
+// of the user of given identifier are searched, unless it is 0. Returns at most
+// limit results, the best ranked first.
+func (db *PostgresDatabase) Search(
+	ctx context.Context,
+	userId int,
+	query string,
+	limit int,
+) ([]plants.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []plants.SearchResult{}, nil
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	// The expression of the log entries matches the one of their full text
+	// index. The plants are searched by their search vector, made of their
+	// common name and their taxon.
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+WITH q AS (SELECT to_tsquery('hortus_french', $1) AS query)
+SELECT 'plant', p.id, 0, p.common_name,
+       ts_headline(
+              'hortus_french',
+              p.common_name || ' ' ||
+              coalesce(t.genus, '') || ' ' ||
+              coalesce(t.species, ''),
+              q.query,
+              'StartSel="`+snippetStart+`", StopSel="`+snippetStop+`", HighlightAll=true'
+       ),
+       ts_rank(p.search_vector, q.query) AS rank
+FROM plant p LEFT JOIN taxon t ON t.id = p.taxon_id, q
+WHERE p.search_vector @@ q.query
+      AND ($3 = 0 OR p.household_id IN (
+            SELECT household_id FROM household_member WHERE user_id = $3
+      ))
+UNION ALL
+SELECT 'log', p.id, l.id, p.common_name,
+       ts_headline(
+              'hortus_french',
+              l.description,
+              q.query,
+              'StartSel="`+snippetStart+`", StopSel="`+snippetStop+`", MaxWords=20, MinWords=8'
+       ),
+       ts_rank(to_tsvector('hortus_french', l.description), q.query)
+FROM plant_log l JOIN plant p ON p.id = l.plant_id, q
+WHERE to_tsvector('hortus_french', l.description) @@ q.query
+      AND ($3 = 0 OR p.household_id IN (
+            SELECT household_id FROM household_member WHERE user_id = $3
+      ))
+ORDER BY rank DESC
+LIMIT $2;`,
+		tsQuery(terms),
+		limit,
+		userId,
+	)
+	results := []plants.SearchResult{}
+	var r plants.SearchResult
+	var snippet string
+	scans := []any{&r.Kind, &r.PlantId, &r.LogId, &r.CommonName, &snippet, &r.Rank}
+	_, err := pgx.ForEachRow(rows, scans, func() error {
+		r.Snippet = parseSnippet(snippet)
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return results, nil
+}
+
+// GetCareSchedules queries the database for the care schedules of the plant of
+// given identifier.
+func (db *PostgresDatabase) GetCareSchedules(
+	ctx context.Context,
+	plantId int,
+) ([]plants.CareSchedule, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT id, plant_id, event_type, interval_days, season_start, season_end,
+       created_at
+FROM care_schedule
+WHERE plant_id=$1
+ORDER BY id;`,
+		plantId,
+	)
+	schedules := []plants.CareSchedule{}
+	var s plants.CareSchedule
+	var start, end int
+	scans := []any{
+		&s.Id,
+		&s.PlantId,
+		&s.EventType,
+		&s.IntervalDays,
+		&start,
+		&end,
+		&s.CreatedAt,
+	}
+	_, err := pgx.ForEachRow(rows, scans, func() error {
+		s.SeasonStart, s.SeasonEnd = time.Month(start), time.Month(end)
+		schedules = append(schedules, s)
+		return nil
+	})
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return schedules, nil
+}
+
+// GetCareSchedule queries the database for the care schedule of identifier
+// scheduleId, if it belongs to the plant of identifier plantId.
+func (db *PostgresDatabase) GetCareSchedule(
+	ctx context.Context,
+	plantId, scheduleId int,
+) (plants.CareSchedule, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var s plants.CareSchedule
+	var start, end int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+SELECT id, plant_id, event_type, interval_days, season_start, season_end,
+       created_at
+FROM care_schedule
+WHERE id=$1 AND plant_id=$2;`,
+		scheduleId,
+		plantId,
+	).Scan(
+		&s.Id,
+		&s.PlantId,
+		&s.EventType,
+		&s.IntervalDays,
+		&start,
+		&end,
+		&s.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.CareSchedule{}, errScheduleNotFound
+	}
+	if err != nil {
+		return plants.CareSchedule{}, pgError(err)
+	}
+	s.SeasonStart, s.SeasonEnd = time.Month(start), time.Month(end)
+	return s, nil
+}
+
+// AddCareSchedule inserts the care schedule s of the plant of identifier
+// s.PlantId, created at the current time, and returns its identifier. The
+// identifier and creation time of s are ignored.
+func (db *PostgresDatabase) AddCareSchedule(
+	ctx context.Context,
+	s plants.CareSchedule,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+INSERT INTO care_schedule
+(plant_id, event_type, interval_days, season_start, season_end)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;`,
+		s.PlantId,
+		int(s.EventType),
+		s.IntervalDays,
+		int(s.SeasonStart),
+		int(s.SeasonEnd),
+	).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, nil
+}
+
+// UpdateCareSchedule replaces the event type, interval and season of the care
+// schedule of identifier s.Id, if it belongs to the plant of identifier
+// s.PlantId.
+func (db *PostgresDatabase) UpdateCareSchedule(
+	ctx context.Context,
+	s plants.CareSchedule,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		`
+UPDATE care_schedule
+SET event_type=$3, interval_days=$4, season_start=$5, season_end=$6
+WHERE id=$1 AND plant_id=$2;`,
+		s.Id,
+		s.PlantId,
+		int(s.EventType),
+		s.IntervalDays,
+		int(s.SeasonStart),
+		int(s.SeasonEnd),
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errScheduleNotFound
 	}
 	return nil
 }
+
+// DeleteCareSchedule deletes the care schedule of identifier scheduleId, if it
+// belongs to the plant of identifier plantId.
+func (db *PostgresDatabase) DeleteCareSchedule(
+	ctx context.Context,
+	plantId, scheduleId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		"DELETE FROM care_schedule WHERE id=$1 AND plant_id=$2;",
+		scheduleId,
+		plantId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errScheduleNotFound
+	}
+	return nil
+}
+
+// GetCareTasks queries the database for the care schedules of the plant of
+// given identifier, or of all the plants of the households of the user if it
+// is 0, and the latest log entry of their event type, and returns their next
+// occurrence as of now. A user identifier of 0 selects the plants of all the
+// households.
+func (db *PostgresDatabase) GetCareTasks(
+	ctx context.Context,
+	userId, plantId int,
+	now time.Time,
+) ([]plants.CareTask, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		careTasksQuery("$1", "$2"),
+		userId,
+		plantId,
+	)
+	tasks := []plants.CareTask{}
+	var s plants.CareSchedule
+	var start, end int
+	var commonName string
+	var lastDone *time.Time
+	var householdId int
+	scans := []any{
+		&s.Id,
+		&s.PlantId,
+		&s.EventType,
+		&s.IntervalDays,
+		&start,
+		&end,
+		&s.CreatedAt,
+		&commonName,
+		&lastDone,
+		&householdId,
+	}
+	_, err := pgx.ForEachRow(rows, scans, func() error {
+		s.SeasonStart, s.SeasonEnd = time.Month(start), time.Month(end)
+		var last time.Time
+		if lastDone != nil {
+			last = *lastDone
+		}
+		task := s.Task(commonName, last, now)
+		task.HouseholdId = householdId
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return tasks, nil
+}
+
+// AddPhoto inserts the metadata of the photo p of the plant of identifier
+// p.PlantId, created at the current time, and returns its identifier. The
+// identifier and creation time of p are ignored.
+func (db *PostgresDatabase) AddPhoto(
+	ctx context.Context,
+	p plants.Photo,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+INSERT INTO photo
+(plant_id, log_id, blob_key, thumbnail_key, content_type, size, caption,
+ taken_at)
+VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8)
+RETURNING id;`,
+		p.PlantId,
+		p.LogId,
+		p.Key,
+		p.ThumbnailKey,
+		p.ContentType,
+		p.Size,
+		p.Caption,
+		nullTime(p.TakenAt),
+	).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, nil
+}
+
+// GetPhotos queries the database for the metadata of the photos of the plant of
+// given identifier, in the order they were added.
+func (db *PostgresDatabase) GetPhotos(
+	ctx context.Context,
+	plantId int,
+) ([]plants.Photo, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT id, plant_id, coalesce(log_id, 0), blob_key, thumbnail_key,
+       content_type, size, caption, taken_at, created_at
+FROM photo
+WHERE plant_id=$1
+ORDER BY created_at, id;`,
+		plantId,
+	)
+	photos, err := pgx.CollectRows(rows, rowToPhoto)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return photos, nil
+}
+
+// GetPhoto queries the database for the metadata of the photo of identifier
+// photoId, if it belongs to the plant of identifier plantId.
+func (db *PostgresDatabase) GetPhoto(
+	ctx context.Context,
+	plantId, photoId int,
+) (plants.Photo, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT id, plant_id, coalesce(log_id, 0), blob_key, thumbnail_key,
+       content_type, size, caption, taken_at, created_at
+FROM photo
+WHERE id=$1 AND plant_id=$2;`,
+		photoId,
+		plantId,
+	)
+	photo, err := pgx.CollectExactlyOneRow(rows, rowToPhoto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.Photo{}, errPhotoNotFound
+	}
+	if err != nil {
+		return plants.Photo{}, pgError(err)
+	}
+	return photo, nil
+}
+
+// Scans a row of the 'photo' table, whose capture time may be NULL.
+func rowToPhoto(row pgx.CollectableRow) (plants.Photo, error) {
+	var p plants.Photo
+	var takenAt *time.Time
+	err := row.Scan(
+		&p.Id,
+		&p.PlantId,
+		&p.LogId,
+		&p.Key,
+		&p.ThumbnailKey,
+		&p.ContentType,
+		&p.Size,
+		&p.Caption,
+		&takenAt,
+		&p.CreatedAt,
+	)
+	if takenAt != nil {
+		p.TakenAt = *takenAt
+	}
+	return p, err
+}
+
+// DeletePhoto deletes the metadata of the photo of identifier photoId, if it
+// belongs to the plant of identifier plantId.
+func (db *PostgresDatabase) DeletePhoto(
+	ctx context.Context,
+	plantId, photoId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		"DELETE FROM photo WHERE id=$1 AND plant_id=$2;",
+		photoId,
+		plantId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errPhotoNotFound
+	}
+	return nil
+}
+
+// AddUser inserts a user of given username and bcrypt password hash, and
+// returns its identifier. The user is the owner of a new household named after
+// it. The household of the first user is given the plants that have no
+// household, created before there were users. Returns an error if the username
+// is already taken, ignoring case.
+func (db *PostgresDatabase) AddUser(
+	ctx context.Context,
+	username, passwordHash string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(
+		ctx,
+		`
+INSERT INTO "user" (username, password_hash)
+VALUES ($1, $2)
+RETURNING id;`,
+		username,
+		passwordHash,
+	).Scan(&id)
+	err = pgError(err)
+	if errors.Is(err, ErrConflict) {
+		return 0, errUsernameTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+	householdId, err := addHouseholdTx(ctx, tx, id, username)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+UPDATE plant SET household_id = $1
+WHERE household_id IS NULL AND (SELECT count(*) FROM "user") = 1;`,
+		householdId,
+	)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, pgError(tx.Commit(ctx))
+}
+
+// GetUser queries the database for the user of given identifier.
+func (db *PostgresDatabase) GetUser(ctx context.Context, id int) (plants.User, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var u plants.User
+	err := db.pool.QueryRow(
+		ctx,
+		`SELECT id, username, created_at FROM "user" WHERE id=$1;`,
+		id,
+	).Scan(&u.Id, &u.Username, &u.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.User{}, errUserNotFound
+	}
+	if err != nil {
+		return plants.User{}, pgError(err)
+	}
+	return u, nil
+}
+
+// GetUserByName queries the database for the user of given username, ignoring
+// case, and returns it with the bcrypt hash of its password.
+func (db *PostgresDatabase) GetUserByName(
+	ctx context.Context,
+	username string,
+) (plants.User, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var u plants.User
+	var hash string
+	err := db.pool.QueryRow(
+		ctx,
+		`
+SELECT id, username, created_at, password_hash
+FROM "user"
+WHERE lower(username) = lower($1);`,
+		username,
+	).Scan(&u.Id, &u.Username, &u.CreatedAt, &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.User{}, "", errUserNotFound
+	}
+	if err != nil {
+		return plants.User{}, "", pgError(err)
+	}
+	return u, hash, nil
+}
+
+// AddToken inserts the token t, identified by the SHA-256 hash of its secret,
+// and returns its identifier. Returns an error if a token of same hash exists.
+func (db *PostgresDatabase) AddToken(
+	ctx context.Context,
+	t plants.Token,
+	hash string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+INSERT INTO api_token (user_id, name, token_hash, scope)
+VALUES (NULLIF($1, 0), $2, $3, $4)
+RETURNING id;`,
+		t.UserId,
+		t.Name,
+		hash,
+		t.Scope,
+	).Scan(&id)
+	err = pgError(err)
+	if errors.Is(err, ErrConflict) {
+		return 0, errTokenExists
+	}
+	return id, err
+}
+
+// GetTokenByHash queries the database for the token whose secret has the given
+// SHA-256 hash. Revoked tokens are not found.
+func (db *PostgresDatabase) GetTokenByHash(
+	ctx context.Context,
+	hash string,
+) (plants.Token, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT id, coalesce(user_id, 0), name, scope, created_at, revoked_at
+FROM api_token
+WHERE token_hash=$1 AND revoked_at IS NULL;`,
+		hash,
+	)
+	t, err := pgx.CollectExactlyOneRow(rows, rowToToken)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.Token{}, errTokenNotFound
+	}
+	if err != nil {
+		return plants.Token{}, pgError(err)
+	}
+	return t, nil
+}
+
+// GetTokens queries the database for the tokens of the user of given
+// identifier, or for all the tokens if userId is 0, including the revoked ones.
+func (db *PostgresDatabase) GetTokens(
+	ctx context.Context,
+	userId int,
+) ([]plants.Token, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT id, coalesce(user_id, 0), name, scope, created_at, revoked_at
+FROM api_token
+WHERE $1=0 OR user_id=$1
+ORDER BY created_at, id;`,
+		userId,
+	)
+	tokens, err := pgx.CollectRows(rows, rowToToken)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return tokens, nil
+}
+
+// Scans a row of the 'api_token' table, whose revocation time may be NULL.
+func rowToToken(row pgx.CollectableRow) (plants.Token, error) {
+	var t plants.Token
+	var revokedAt *time.Time
+	err := row.Scan(
+		&t.Id,
+		&t.UserId,
+		&t.Name,
+		&t.Scope,
+		&t.CreatedAt,
+		&revokedAt,
+	)
+	if revokedAt != nil {
+		t.RevokedAt = *revokedAt
+	}
+	return t, err
+}
+
+// RevokeToken revokes the token of given identifier, if it belongs to the user
+// of identifier userId or if userId is 0. Revoking a revoked token is an error.
+func (db *PostgresDatabase) RevokeToken(ctx context.Context, userId, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		`
+UPDATE api_token SET revoked_at = now()
+WHERE id=$1 AND ($2=0 OR user_id=$2) AND revoked_at IS NULL;`,
+		id,
+		userId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errTokenNotFound
+	}
+	return nil
+}
+
+// AddHousehold inserts a household of given name, of which the user of
+// identifier userId is the owner, and returns its identifier.
+func (db *PostgresDatabase) AddHousehold(
+	ctx context.Context,
+	userId int,
+	name string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := addHouseholdTx(ctx, tx, userId, name)
+	if err != nil {
+		return 0, err
+	}
+	return id, pgError(tx.Commit(ctx))
+}
+
+// Inserts a household of given name in tx, of which the user of identifier
+// userId is the owner, and returns its identifier.
+func addHouseholdTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	userId int,
+	name string,
+) (int, error) {
+	var id int
+	err := tx.QueryRow(
+		ctx,
+		"INSERT INTO household (name) VALUES ($1) RETURNING id;",
+		name,
+	).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+INSERT INTO household_member (household_id, user_id, role)
+VALUES ($1, $2, $3);`,
+		id,
+		userId,
+		plants.RoleOwner,
+	)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, nil
+}
+
+// GetHouseholds queries the database for the households of which the user of
+// given identifier is a member, with the role of the user.
+func (db *PostgresDatabase) GetHouseholds(
+	ctx context.Context,
+	userId int,
+) ([]plants.Household, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT h.id, h.name, m.role, h.created_at
+FROM household h
+JOIN household_member m ON m.household_id = h.id
+WHERE m.user_id=$1
+ORDER BY h.created_at, h.id;`,
+		userId,
+	)
+	households, err := pgx.CollectRows(
+		rows,
+		func(row pgx.CollectableRow) (plants.Household, error) {
+			var h plants.Household
+			err := row.Scan(&h.Id, &h.Name, &h.Role, &h.CreatedAt)
+			return h, err
+		},
+	)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return households, nil
+}
+
+// GetHousehold queries the database for the household of given identifier and
+// its members, in the order they joined.
+func (db *PostgresDatabase) GetHousehold(
+	ctx context.Context,
+	id int,
+) (plants.Household, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var h plants.Household
+	err := db.pool.QueryRow(
+		ctx,
+		"SELECT id, name, created_at FROM household WHERE id=$1;",
+		id,
+	).Scan(&h.Id, &h.Name, &h.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.Household{}, errHouseholdNotFound
+	}
+	if err != nil {
+		return plants.Household{}, pgError(err)
+	}
+
+	rows, _ := db.pool.Query(
+		ctx,
+		`
+SELECT m.user_id, u.username, m.role, m.joined_at
+FROM household_member m
+JOIN "user" u ON u.id = m.user_id
+WHERE m.household_id=$1
+ORDER BY m.joined_at, m.user_id;`,
+		id,
+	)
+	h.Members, err = pgx.CollectRows(
+		rows,
+		func(row pgx.CollectableRow) (plants.Member, error) {
+			var m plants.Member
+			err := row.Scan(&m.UserId, &m.Username, &m.Role, &m.JoinedAt)
+			return m, err
+		},
+	)
+	if err != nil {
+		return plants.Household{}, pgError(err)
+	}
+	return h, nil
+}
+
+// UpdateHousehold renames the household of given identifier.
+func (db *PostgresDatabase) UpdateHousehold(
+	ctx context.Context,
+	id int,
+	name string,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		"UPDATE household SET name=$1 WHERE id=$2;",
+		name,
+		id,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errHouseholdNotFound
+	}
+	return nil
+}
+
+// DeleteHousehold deletes the household of given identifier, with its members
+// and invitations. Returns an error if the household still has plants.
+func (db *PostgresDatabase) DeleteHousehold(ctx context.Context, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var hasPlants bool
+	err = tx.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM plant WHERE household_id=$1);",
+		id,
+	).Scan(&hasPlants)
+	if err != nil {
+		return pgError(err)
+	}
+	if hasPlants {
+		return errHouseholdNotEmpty
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM household WHERE id=$1;", id)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errHouseholdNotFound
+	}
+	return pgError(tx.Commit(ctx))
+}
+
+// GetMemberRole queries the database for the role of the user of identifier
+// userId in the household of identifier householdId.
+func (db *PostgresDatabase) GetMemberRole(
+	ctx context.Context,
+	householdId, userId int,
+) (plants.Role, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var role plants.Role
+	err := db.pool.QueryRow(
+		ctx,
+		`
+SELECT role FROM household_member
+WHERE household_id=$1 AND user_id=$2;`,
+		householdId,
+		userId,
+	).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errMemberNotFound
+	}
+	if err != nil {
+		return "", pgError(err)
+	}
+	return role, nil
+}
+
+// SetMemberRole changes the role of the user of identifier userId in the
+// household of identifier householdId. Returns an error if the household would
+// be left without owner.
+func (db *PostgresDatabase) SetMemberRole(
+	ctx context.Context,
+	householdId, userId int,
+	role plants.Role,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		`
+UPDATE household_member SET role=$1
+WHERE household_id=$2 AND user_id=$3;`,
+		role,
+		householdId,
+		userId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errMemberNotFound
+	}
+	err = checkOwnersTx(ctx, tx, householdId)
+	if err != nil {
+		return err
+	}
+	return pgError(tx.Commit(ctx))
+}
+
+// DeleteMember removes the user of identifier userId from the household of
+// identifier householdId. Returns an error if the household would be left
+// without owner.
+func (db *PostgresDatabase) DeleteMember(
+	ctx context.Context,
+	householdId, userId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		"DELETE FROM household_member WHERE household_id=$1 AND user_id=$2;",
+		householdId,
+		userId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errMemberNotFound
+	}
+	err = checkOwnersTx(ctx, tx, householdId)
+	if err != nil {
+		return err
+	}
+	return pgError(tx.Commit(ctx))
+}
+
+// Returns an error if the household of given identifier has no owner in tx. The
+// rows of the members are locked until the end of tx, so that concurrent
+// changes of roles cannot leave the household without owner.
+func checkOwnersTx(ctx context.Context, tx pgx.Tx, householdId int) error {
+	rows, _ := tx.Query(
+		ctx,
+		`
+SELECT role FROM household_member
+WHERE household_id=$1
+FOR UPDATE;`,
+		householdId,
+	)
+	roles, err := pgx.CollectRows(rows, pgx.RowTo[plants.Role])
+	if err != nil {
+		return pgError(err)
+	}
+	if !slices.Contains(roles, plants.RoleOwner) {
+		return errLastOwner
+	}
+	return nil
+}
+
+// AddInvitation inserts the invitation inv, identified by the SHA-256 hash of
+// the secret of its link, and returns its identifier.
+func (db *PostgresDatabase) AddInvitation(
+	ctx context.Context,
+	inv plants.Invitation,
+	hash string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+INSERT INTO household_invitation (household_id, token_hash, role, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id;`,
+		inv.HouseholdId,
+		hash,
+		inv.Role,
+		inv.ExpiresAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, nil
+}
+
+// GetInvitations queries the database for the invitations to the household of
+// given identifier that are neither accepted nor expired at now.
+func (db *PostgresDatabase) GetInvitations(
+	ctx context.Context,
+	householdId int,
+	now time.Time,
+) ([]plants.Invitation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		invitationsQuery("i.household_id=$1 AND i.expires_at > $2"),
+		householdId,
+		now,
+	)
+	invitations, err := pgx.CollectRows(rows, rowToInvitation)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return invitations, nil
+}
+
+// GetInvitationByHash queries the database for the invitation whose link has a
+// secret of given SHA-256 hash, if it is neither accepted nor expired at now.
+func (db *PostgresDatabase) GetInvitationByHash(
+	ctx context.Context,
+	hash string,
+	now time.Time,
+) (plants.Invitation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		invitationsQuery("i.token_hash=$1 AND i.expires_at > $2"),
+		hash,
+		now,
+	)
+	inv, err := pgx.CollectExactlyOneRow(rows, rowToInvitation)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.Invitation{}, errInvitationNotFound
+	}
+	if err != nil {
+		return plants.Invitation{}, pgError(err)
+	}
+	return inv, nil
+}
+
+// Scans a row of an invitations query, see invitationsQuery.
+func rowToInvitation(row pgx.CollectableRow) (plants.Invitation, error) {
+	var inv plants.Invitation
+	err := row.Scan(
+		&inv.Id,
+		&inv.HouseholdId,
+		&inv.HouseholdName,
+		&inv.Role,
+		&inv.CreatedAt,
+		&inv.ExpiresAt,
+	)
+	return inv, err
+}
+
+// AcceptInvitation adds the user of identifier userId to the household of the
+// invitation of identifier id, with the role of the invitation, if it is
+// neither accepted nor expired at now. The invitation cannot be used again.
+func (db *PostgresDatabase) AcceptInvitation(
+	ctx context.Context,
+	id, userId int,
+	now time.Time,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var householdId int
+	var role plants.Role
+	err = tx.QueryRow(
+		ctx,
+		`
+UPDATE household_invitation SET accepted_by=$2, accepted_at=$3
+WHERE id=$1 AND accepted_at IS NULL AND expires_at > $3
+RETURNING household_id, role;`,
+		id,
+		userId,
+		now,
+	).Scan(&householdId, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errInvitationNotFound
+	}
+	if err != nil {
+		return pgError(err)
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+INSERT INTO household_member (household_id, user_id, role)
+VALUES ($1, $2, $3);`,
+		householdId,
+		userId,
+		role,
+	)
+	err = pgError(err)
+	if errors.Is(err, ErrConflict) {
+		return errAlreadyMember
+	}
+	if err != nil {
+		return err
+	}
+	return pgError(tx.Commit(ctx))
+}
+
+// DeleteInvitation deletes the invitation of identifier id to the household of
+// identifier householdId, if it is not accepted yet.
+func (db *PostgresDatabase) DeleteInvitation(
+	ctx context.Context,
+	householdId, id int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		`
+DELETE FROM household_invitation
+WHERE id=$1 AND household_id=$2 AND accepted_at IS NULL;`,
+		id,
+		householdId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errInvitationNotFound
+	}
+	return nil
+}
+
+// AddLocation inserts the location l, in the parent location of identifier
+// l.ParentId unless it is 0, and returns its identifier.
+func (db *PostgresDatabase) AddLocation(
+	ctx context.Context,
+	l plants.Location,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+INSERT INTO location (household_id, parent_id, name, kind)
+VALUES ($1, NULLIF($2, 0), $3, $4)
+RETURNING id;`,
+		l.HouseholdId,
+		l.ParentId,
+		l.Name,
+		l.Kind,
+	).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, nil
+}
+
+// GetLocations queries the database for the locations of the households of
+// the user of identifier userId, or for all the locations if it is 0.
+func (db *PostgresDatabase) GetLocations(
+	ctx context.Context,
+	userId int,
+) ([]plants.Location, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		locationsQuery(
+			"$1 = 0 OR l.household_id IN ("+memberHouseholds("$1")+")",
+		),
+		userId,
+	)
+	locations, err := pgx.CollectRows(rows, rowToLocation)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return locations, nil
+}
+
+// GetLocation queries the database for the location of given identifier.
+func (db *PostgresDatabase) GetLocation(
+	ctx context.Context,
+	id int,
+) (plants.Location, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(ctx, locationsQuery("l.id=$1"), id)
+	l, err := pgx.CollectExactlyOneRow(rows, rowToLocation)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.Location{}, errLocationNotFound
+	}
+	if err != nil {
+		return plants.Location{}, pgError(err)
+	}
+	return l, nil
+}
+
+// Scans a row of a locations query, see locationsQuery.
+func rowToLocation(row pgx.CollectableRow) (plants.Location, error) {
+	var l plants.Location
+	err := row.Scan(
+		&l.Id,
+		&l.HouseholdId,
+		&l.ParentId,
+		&l.Name,
+		&l.Kind,
+		&l.Path,
+	)
+	return l, err
+}
+
+// UpdateLocation replaces the name and the parent location of the location of
+// identifier l.Id. Its household and kind are left unchanged.
+func (db *PostgresDatabase) UpdateLocation(
+	ctx context.Context,
+	l plants.Location,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := db.pool.Exec(
+		ctx,
+		"UPDATE location SET name=$2, parent_id=NULLIF($3, 0) WHERE id=$1;",
+		l.Id,
+		l.Name,
+		l.ParentId,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errLocationNotFound
+	}
+	return nil
+}
+
+// DeleteLocation deletes the location of given identifier, which must contain
+// neither plants nor other locations.
+func (db *PostgresDatabase) DeleteLocation(ctx context.Context, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var used bool
+	err = tx.QueryRow(
+		ctx,
+		`
+SELECT EXISTS (SELECT 1 FROM plant WHERE location_id=$1)
+       OR EXISTS (SELECT 1 FROM location WHERE parent_id=$1);`,
+		id,
+	).Scan(&used)
+	if err != nil {
+		return pgError(err)
+	}
+	if used {
+		return errLocationNotEmpty
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM location WHERE id=$1;", id)
+	if err != nil {
+		return pgError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errLocationNotFound
+	}
+	return pgError(tx.Commit(ctx))
+}
+
+// GetPlantLocation queries the database for the identifier of the household of
+// the plant of given identifier, 0 if it has none, and for the location of the
+// plant, the zero Location if it has none.
+func (db *PostgresDatabase) GetPlantLocation(
+	ctx context.Context,
+	plantId int,
+) (int, plants.Location, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var householdId, locationId int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+SELECT COALESCE(household_id, 0), COALESCE(location_id, 0)
+FROM plant
+WHERE id=$1;`,
+		plantId,
+	).Scan(&householdId, &locationId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, plants.Location{}, errPlantNotFound
+	}
+	if err != nil {
+		return 0, plants.Location{}, pgError(err)
+	}
+	if locationId == 0 {
+		return householdId, plants.Location{}, nil
+	}
+
+	rows, _ := db.pool.Query(ctx, locationsQuery("l.id=$1"), locationId)
+	l, err := pgx.CollectExactlyOneRow(rows, rowToLocation)
+	if err != nil {
+		return 0, plants.Location{}, pgError(err)
+	}
+	return householdId, l, nil
+}
+
+// MovePlant moves the plant of identifier plantId to the location of
+// identifier locationId, or out of any location if it is 0, and logs the move
+// with an entry of description desc that occurred at occurredAt. The location
+// must belong to the household of the plant. Returns the identifier of the
+// entry.
+func (db *PostgresDatabase) MovePlant(
+	ctx context.Context,
+	plantId, locationId int,
+	desc string,
+	occurredAt time.Time,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var moved bool
+	err = tx.QueryRow(
+		ctx,
+		`
+UPDATE plant SET location_id=NULLIF($2, 0)
+WHERE id=$1
+RETURNING $2 = 0 OR EXISTS (
+       SELECT 1 FROM location l
+       WHERE l.id=$2 AND l.household_id = plant.household_id
+);`,
+		plantId,
+		locationId,
+	).Scan(&moved)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errPlantNotFound
+	}
+	if err != nil {
+		return 0, pgError(err)
+	}
+	if !moved {
+		return 0, errLocationNotFound
+	}
+
+	var logId int
+	err = tx.QueryRow(
+		ctx,
+		`
+INSERT INTO plant_log (plant_id, description, event_type, occurred_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id;`,
+		plantId,
+		desc,
+		int(plants.EventMove),
+		occurredAt,
+	).Scan(&logId)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return logId, pgError(tx.Commit(ctx))
+}
+
+// AddPlantTags adds the tags to the plant of identifier plantId, creating the
+// tags that do not exist yet. The tags the plant already has are left as is.
+func (db *PostgresDatabase) AddPlantTags(
+	ctx context.Context,
+	plantId int,
+	tags []string,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM plant WHERE id=$1);",
+		plantId,
+	).Scan(&exists)
+	if err != nil {
+		return pgError(err)
+	}
+	if !exists {
+		return errPlantNotFound
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+INSERT INTO tag (name) SELECT unnest($1::text[])
+ON CONFLICT (name) DO NOTHING;`,
+		tags,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+INSERT INTO plant_tag (plant_id, tag_id)
+SELECT $1, id FROM tag WHERE name = ANY($2)
+ON CONFLICT DO NOTHING;`,
+		plantId,
+		tags,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	return pgError(tx.Commit(ctx))
+}
+
+// RemovePlantTag removes the tag from the plant of identifier plantId. The tag
+// is deleted once no plant has it.
+func (db *PostgresDatabase) RemovePlantTag(
+	ctx context.Context,
+	plantId int,
+	tag string,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(
+		ctx,
+		`
+DELETE FROM plant_tag
+WHERE plant_id = $1 AND tag_id = (SELECT id FROM tag WHERE name = $2);`,
+		plantId,
+		tag,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return errTagNotFound
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+DELETE FROM tag
+WHERE name = $1 AND NOT EXISTS (SELECT 1 FROM plant_tag WHERE tag_id = tag.id);`,
+		tag,
+	)
+	if err != nil {
+		return pgError(err)
+	}
+	return pgError(tx.Commit(ctx))
+}
+
+// GetPlantTags queries the database for the tags of the plant of given
+// identifier, in alphabetical order.
+func (db *PostgresDatabase) GetPlantTags(
+	ctx context.Context,
+	plantId int,
+) ([]string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(ctx, plantTags("$1")+";", plantId)
+	tags, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return tags, nil
+}
+
+// GetTagCounts queries the database for the tags of the plants of the
+// households of the user of identifier userId, or of all the plants if it is 0,
+// with the number of plants having each of them, in alphabetical order.
+func (db *PostgresDatabase) GetTagCounts(
+	ctx context.Context,
+	userId int,
+) ([]plants.TagCount, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(ctx, tagCountsQuery("$1"), userId)
+	counts, err := pgx.CollectRows(
+		rows,
+		pgx.RowToStructByPos[plants.TagCount],
+	)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return counts, nil
+}
+
+// AddTaxon inserts the taxon t, unless a taxon of the same name exists, and
+// returns its identifier. The family of an existing taxon is only set if it
+// was empty.
+func (db *PostgresDatabase) AddTaxon(
+	ctx context.Context,
+	t plants.Taxon,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.pool.QueryRow(
+		ctx,
+		`
+INSERT INTO taxon (family, genus, species, infraspecific_rank,
+                   infraspecific_name, cultivar)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (genus, species, infraspecific_rank, infraspecific_name, cultivar)
+DO UPDATE SET family = CASE
+       WHEN taxon.family = '' THEN excluded.family
+       ELSE taxon.family
+END
+RETURNING id;`,
+		t.Family,
+		t.Genus,
+		t.Species,
+		t.Rank,
+		t.Infraspecies,
+		t.Cultivar,
+	).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, nil
+}
+
+// GetTaxon queries the database for the taxon of given identifier.
+func (db *PostgresDatabase) GetTaxon(
+	ctx context.Context,
+	id int,
+) (plants.Taxon, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		"SELECT "+taxonColumns+" FROM taxon t WHERE t.id=$1;",
+		id,
+	)
+	t, err := pgx.CollectExactlyOneRow(rows, rowToTaxon)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return plants.Taxon{}, errTaxonNotFound
+	}
+	if err != nil {
+		return plants.Taxon{}, pgError(err)
+	}
+	return t, nil
+}
+
+// GetTaxa queries the database for the taxa selected by q, in alphabetical
+// order of their names.
+func (db *PostgresDatabase) GetTaxa(
+	ctx context.Context,
+	q TaxonQuery,
+) ([]plants.Taxon, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query, args := taxaQuery(q, func(n int) string {
+		return "$" + strconv.Itoa(n)
+	})
+	rows, _ := db.pool.Query(ctx, query, args...)
+	taxa, err := pgx.CollectRows(rows, rowToTaxon)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return taxa, nil
+}
+
+// AddTaxa inserts the taxa, in a single transaction, and returns the number of
+// taxa inserted. Like AddTaxon, the taxa whose name is already in the database
+// are not inserted again, and only their empty family is set.
+func (db *PostgresDatabase) AddTaxa(
+	ctx context.Context,
+	taxa []plants.Taxon,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	// One array by column, unnested into the rows of the taxa
+	columns := make([][]string, 6)
+	for _, t := range taxa {
+		for i, v := range []string{
+			t.Family,
+			t.Genus,
+			t.Species,
+			string(t.Rank),
+			t.Infraspecies,
+			t.Cultivar,
+		} {
+			columns[i] = append(columns[i], v)
+		}
+	}
+	args := make([]any, len(columns))
+	for i, c := range columns {
+		args[i] = c
+	}
+	const rows = `
+unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[])
+AS n(family, genus, species, infraspecific_rank, infraspecific_name, cultivar)`
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(
+		ctx,
+		`
+INSERT INTO taxon (family, genus, species, infraspecific_rank,
+                   infraspecific_name, cultivar)
+SELECT * FROM`+rows+`
+ON CONFLICT DO NOTHING;`,
+		args...,
+	)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+UPDATE taxon t SET family = n.family
+FROM`+rows+`
+WHERE t.family = '' AND n.family <> '' AND t.genus = n.genus
+      AND t.species = n.species
+      AND t.infraspecific_rank = n.infraspecific_rank
+      AND t.infraspecific_name = n.infraspecific_name
+      AND t.cultivar = n.cultivar;`,
+		args...,
+	)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return int(cmd.RowsAffected()), nil
+}
+
+// GetTaxonNames queries the database for the genera of the taxa, or the species
+// of the taxa of genus if it is not empty, starting with prefix, after their
+// hybrid marker if any, and ignoring case, in alphabetical order. At most limit
+// names are returned, all of them if it is not positive.
+func (db *PostgresDatabase) GetTaxonNames(
+	ctx context.Context,
+	genus, prefix string,
+	limit int,
+) ([]string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query, args := taxonNamesQuery(genus, prefix, limit, func(n int) string {
+		return "$" + strconv.Itoa(n)
+	})
+	rows, _ := db.pool.Query(ctx, query, args...)
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return names, nil
+}
+
+// Scans a row selecting taxonColumns.
+func rowToTaxon(row pgx.CollectableRow) (plants.Taxon, error) {
+	var t plants.Taxon
+	err := row.Scan(
+		&t.Id,
+		&t.Family,
+		&t.Genus,
+		&t.Species,
+		&t.Rank,
+		&t.Infraspecies,
+		&t.Cultivar,
+	)
+	return t, err
+}
+
+// GetPlants queries the database for the plants of the households of the user
+// of identifier userId, or for all the plants if it is 0, with their taxon,
+// household, location and tags, but without their log entries, ordered by
+// identifier.
+func (db *PostgresDatabase) GetPlants(
+	ctx context.Context,
+	userId int,
+) ([]plants.Plant, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(
+		ctx,
+		allPlantsQuery("$1", "ARRAY("+plantTags("p.id")+")"),
+		userId,
+	)
+	ps, err := pgx.CollectRows(
+		rows,
+		func(row pgx.CollectableRow) (plants.Plant, error) {
+			var p plants.Plant
+			var t plants.Taxon
+			err := row.Scan(
+				&p.Id,
+				&p.CommonName,
+				&t.Id,
+				&t.Family,
+				&t.Genus,
+				&t.Species,
+				&t.Rank,
+				&t.Infraspecies,
+				&t.Cultivar,
+				&p.HouseholdId,
+				&p.LocationId,
+				&p.LocationPath,
+				&p.Tags,
+			)
+			if t.Id != 0 {
+				p.Taxon = &t
+				p.GenericName, p.SpecificName = t.Genus, t.Species
+			}
+			if len(p.Tags) == 0 {
+				p.Tags = nil
+			}
+			return p, err
+		},
+	)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return ps, nil
+}
+
+// GetLogs queries the database for the log entries of the plants of the
+// households of the user of identifier userId, or of all the plants if it is 0,
+// ordered by plant, then in chronological order.
+func (db *PostgresDatabase) GetLogs(
+	ctx context.Context,
+	userId int,
+) ([]plants.PlantLog, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, _ := db.pool.Query(ctx, allLogsQuery("$1"), userId)
+	logs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[plants.PlantLog])
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return logs, nil
+}
+
+// AddPlants inserts the plants ps, owned by the household of identifier
+// householdId, or by no one if it is 0, in a single transaction, and returns
+// their identifiers. Each plant has its common name, its location if
+// LocationId is not 0, its tags, and its taxon if Taxon is not nil, which is
+// added like AddTaxon does. Either all the plants are inserted, or none.
+func (db *PostgresDatabase) AddPlants(
+	ctx context.Context,
+	householdId int,
+	ps []plants.Plant,
+) ([]int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]int, 0, len(ps))
+	for _, p := range ps {
+		id, err := addPlantTx(ctx, tx, householdId, p)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return ids, nil
+}
+
+// Inserts the plant p, owned by the household of identifier householdId, in tx
+// with its taxon and tags, see AddPlants, and returns its identifier.
+func addPlantTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	householdId int,
+	p plants.Plant,
+) (int, error) {
+	taxonId := 0
+	if t := p.Taxon; t != nil {
+		err := tx.QueryRow(
+			ctx,
+			`
+INSERT INTO taxon (family, genus, species, infraspecific_rank,
+                   infraspecific_name, cultivar)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (genus, species, infraspecific_rank, infraspecific_name, cultivar)
+DO UPDATE SET family = CASE
+       WHEN taxon.family = '' THEN excluded.family
+       ELSE taxon.family
+END
+RETURNING id;`,
+			t.Family,
+			t.Genus,
+			t.Species,
+			t.Rank,
+			t.Infraspecies,
+			t.Cultivar,
+		).Scan(&taxonId)
+		if err != nil {
+			return 0, pgError(err)
+		}
+	}
+
+	var id int
+	err := tx.QueryRow(
+		ctx,
+		`
+INSERT INTO plant (common_name, taxon_id, household_id, location_id)
+VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0))
+RETURNING id;`,
+		p.CommonName,
+		taxonId,
+		householdId,
+		p.LocationId,
+	).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	if len(p.Tags) == 0 {
+		return id, nil
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+INSERT INTO tag (name) SELECT unnest($1::text[])
+ON CONFLICT (name) DO NOTHING;`,
+		p.Tags,
+	)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	_, err = tx.Exec(
+		ctx,
+		`
+INSERT INTO plant_tag (plant_id, tag_id)
+SELECT $1, id FROM tag WHERE name = ANY($2)
+ON CONFLICT DO NOTHING;`,
+		id,
+		p.Tags,
+	)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	return id, nil
+}
+
+// MigrateUp creates the Hortus schema if needed and applies the pending
+// migrations.
+func (db *PostgresDatabase) MigrateUp(ctx context.Context) (int, error) {
+	return migrateUp(ctx, db, "postgres")
+}
+
+// MigrateDown reverts the last applied migration.
+func (db *PostgresDatabase) MigrateDown(ctx context.Context) (bool, error) {
+	return migrateDown(ctx, db, "postgres")
+}
+
+// MigrationStatus returns the status of all the known migrations.
+func (db *PostgresDatabase) MigrationStatus(
+	ctx context.Context,
+) ([]MigrationStatus, error) {
+	return migrationStatus(ctx, db, "postgres")
+}
+
+func (db *PostgresDatabase) createMigrationsTable(ctx context.Context) error {
+	_, err := db.pool.Exec(
+		ctx,
+		`
+CREATE SCHEMA IF NOT EXISTS `+postgresSchema+`;
+CREATE TABLE IF NOT EXISTS `+postgresSchema+`.schema_migrations (
+       version INTEGER PRIMARY KEY,
+       name VARCHAR(255) NOT NULL,
+       applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);`,
+	)
+	return err
+}
+
+func (db *PostgresDatabase) appliedMigrations(
+	ctx context.Context,
+) (map[int]time.Time, error) {
+	rows, _ := db.pool.Query(
+		ctx,
+		"SELECT version, applied_at FROM schema_migrations;",
+	)
+	applied := make(map[int]time.Time)
+	var version int
+	var at time.Time
+	_, err := pgx.ForEachRow(rows, []any{&version, &at}, func() error {
+		applied[version] = at
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (db *PostgresDatabase) runMigration(
+	ctx context.Context,
+	m migration,
+	up bool,
+) ([]string, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(
+		ctx,
+		`
+CREATE TEMP TABLE migration_note (message TEXT NOT NULL) ON COMMIT DROP;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if up {
+		_, err = tx.Exec(ctx, m.up)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(
+			ctx,
+			"INSERT INTO schema_migrations (version, name) VALUES ($1, $2);",
+			m.version,
+			m.name,
+		)
+	} else {
+		_, err = tx.Exec(ctx, m.down)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(
+			ctx,
+			"DELETE FROM schema_migrations WHERE version=$1;",
+			m.version,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, _ := tx.Query(ctx, "SELECT message FROM migration_note;")
+	notes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	return notes, tx.Commit(ctx)
+}
+
+// Builds a tsquery matching the texts containing all the terms, as prefixes of
+// their words. Terms only contain letters and numbers.
+func tsQuery(terms []string) string {
+	prefixes := make([]string, len(terms))
+	for i, t := range terms {
+		prefixes[i] = t + ":*"
+	}
+	return strings.Join(prefixes, " & ")
+}
+
+// Returns a copy of ctx cancelled after the query timeout of db.
+func (db *PostgresDatabase) withTimeout(
+	ctx context.Context,
+) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, db.QueryTimeout)
+}
@@ -0,0 +1,420 @@
+package database
+
+import (
+	"fmt"
+	"github.com/mgmu/hortus/internal/plants"
+	"strings"
+	"time"
+)
+
+// PlantSort is the order of a list of plants. Names are compared ignoring case,
+// and plants are always ordered by identifier when the sort key is equal.
+type PlantSort int
+
+const (
+	SortById PlantSort = iota
+	SortByName
+	SortByCreation
+)
+
+// PlantQuery selects a page of the list of plants.
+type PlantQuery struct {
+	Sort PlantSort
+	// Descending order if true.
+	Desc bool
+	// Maximum number of plants in the page, no limit if not positive.
+	Limit int
+	// Position of the last plant of the previous page, nil for the first page.
+	After *PlantCursor
+	// Only the plants of a taxon with this genus and species, ignoring case,
+	// are selected. An empty string selects all the plants.
+	GenericName  string
+	SpecificName string
+	// Only the plants of the households of the user of this identifier are
+	// selected, all the plants if it is 0.
+	UserId int
+	// Only the plants in the location of this identifier, or in one of the
+	// locations it contains, are selected. All the plants if it is 0.
+	LocationId int
+	// Only the plants having all these tags, or at least one of them if AnyTag
+	// is true, are selected. All the plants if there are none. Tags must not
+	// be repeated.
+	Tags   []string
+	AnyTag bool
+}
+
+// PlantCursor is the position of a plant in a list of plants. Only the fields
+// of the sort key of the list and the identifier are used.
+type PlantCursor struct {
+	Id        int
+	Name      string
+	CreatedAt time.Time
+}
+
+// Returns the expression sorting a list of plants. fold returns the expression
+// folding a string to lower case, see plantListQuery.
+func (s PlantSort) column(fold func(expr string) string) string {
+	switch s {
+	case SortByName:
+		return fold("common_name")
+	case SortByCreation:
+		return "created_at"
+	default:
+		return "id"
+	}
+}
+
+// Returns the value of the column sorting a list of plants for c.
+func (s PlantSort) value(c *PlantCursor) any {
+	switch s {
+	case SortByName:
+		return c.Name
+	case SortByCreation:
+		return c.CreatedAt
+	default:
+		return c.Id
+	}
+}
+
+// Builds the query selecting the short description of the plants of the page q
+// and its arguments. placeholder returns the placeholder of the n-th argument,
+// starting at 1, in the SQL dialect of the database. tags is the expression
+// selecting the tags of the plant of each row, see plantTags, in this dialect.
+// fold returns the expression folding the string expr to lower case, compared
+// byte by byte, in this dialect: plants are sorted by name ignoring case, the
+// cursor of the page being folded alike.
+func plantListQuery(
+	q PlantQuery,
+	placeholder func(n int) string,
+	tags string,
+	fold func(expr string) string,
+) (string, []any) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	if q.UserId != 0 {
+		where = append(
+			where,
+			"household_id IN ("+memberHouseholds(arg(q.UserId))+")",
+		)
+	}
+	if q.LocationId != 0 {
+		where = append(
+			where,
+			"location_id IN ("+locationTree(arg(q.LocationId))+")",
+		)
+	}
+	if len(q.Tags) > 0 {
+		names := make([]string, len(q.Tags))
+		for i, tag := range q.Tags {
+			names[i] = arg(tag)
+		}
+		tagged := "SELECT pt.plant_id FROM plant_tag pt" +
+			" JOIN tag t ON t.id = pt.tag_id" +
+			" WHERE t.name IN (" + strings.Join(names, ", ") + ")"
+		if !q.AnyTag {
+			tagged += " GROUP BY pt.plant_id HAVING COUNT(*) = " +
+				arg(len(q.Tags))
+		}
+		where = append(where, "id IN ("+tagged+")")
+	}
+	if q.GenericName != "" {
+		where = append(
+			where,
+			"taxon_id IN (SELECT id FROM taxon WHERE lower(genus) = lower("+
+				arg(q.GenericName)+"))",
+		)
+	}
+	if q.SpecificName != "" {
+		where = append(
+			where,
+			"taxon_id IN (SELECT id FROM taxon WHERE lower(species) = lower("+
+				arg(q.SpecificName)+"))",
+		)
+	}
+
+	col := q.Sort.column(fold)
+	cmp, dir := ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	if q.After != nil {
+		if q.Sort == SortById {
+			where = append(where, "id "+cmp+" "+arg(q.After.Id))
+		} else {
+			key := arg(q.Sort.value(q.After))
+			if q.Sort == SortByName {
+				key = fold(key)
+			}
+			where = append(where, fmt.Sprintf(
+				"(%s, id) %s (%s, %s)",
+				col,
+				cmp,
+				key,
+				arg(q.After.Id),
+			))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT id, common_name, created_at, COALESCE(location_id, 0)")
+	b.WriteString(", " + tags + " FROM plant")
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+	if q.Sort == SortById {
+		b.WriteString(" ORDER BY id " + dir)
+	} else {
+		fmt.Fprintf(&b, " ORDER BY %s %s, id %s", col, dir, dir)
+	}
+	if q.Limit > 0 {
+		b.WriteString(" LIMIT " + arg(q.Limit))
+	}
+	b.WriteString(";")
+	return b.String(), args
+}
+
+// Builds the query selecting the care schedules of a plant, or of all the plants
+// if its identifier is 0, with the common name of the plant, the time of the
+// latest log entry of the event type of the schedule, NULL if there is none,
+// and the household of the plant, 0 if none.
+// Only the plants of the households of the user are selected, unless its
+// identifier is 0. user and param are the placeholders of the identifiers of the
+// user and of the plant in the SQL dialect of the database.
+func careTasksQuery(user, param string) string {
+	return `
+SELECT s.id, s.plant_id, s.event_type, s.interval_days, s.season_start,
+       s.season_end, s.created_at, p.common_name, l.occurred_at,
+       COALESCE(p.household_id, 0)
+FROM care_schedule s
+JOIN plant p ON p.id = s.plant_id
+LEFT JOIN plant_log l ON l.id = (
+     SELECT id FROM plant_log
+     WHERE plant_id = s.plant_id AND event_type = s.event_type
+     ORDER BY occurred_at DESC, id DESC
+     LIMIT 1
+)
+WHERE (` + param + ` = 0 OR s.plant_id = ` + param + `)
+      AND (` + user + ` = 0 OR p.household_id IN (` +
+		memberHouseholds(user) + `))
+ORDER BY s.plant_id, s.id;`
+}
+
+// Builds the subquery selecting the identifiers of the households of which the
+// user is a member. user is the placeholder of the identifier of the user in
+// the SQL dialect of the database.
+func memberHouseholds(user string) string {
+	return "SELECT household_id FROM household_member WHERE user_id = " + user
+}
+
+// Builds the query selecting the invitations that are not accepted yet and
+// match the condition where, with the name of their household. The columns of
+// the invitations are prefixed by "i".
+func invitationsQuery(where string) string {
+	return `
+SELECT i.id, i.household_id, h.name, i.role, i.created_at, i.expires_at
+FROM household_invitation i
+JOIN household h ON h.id = i.household_id
+WHERE i.accepted_at IS NULL AND ` + where + `
+ORDER BY i.created_at, i.id;`
+}
+
+// Builds the subquery selecting the identifiers of a location and of the
+// locations it contains, at most two levels down. location is the placeholder
+// of the identifier of the location in the SQL dialect of the database.
+func locationTree(location string) string {
+	return `
+SELECT id FROM location
+WHERE id = ` + location + ` OR parent_id = ` + location + `
+      OR parent_id IN (SELECT id FROM location WHERE parent_id = ` +
+		location + `)`
+}
+
+// Builds the query selecting the locations matching the condition where, with
+// their path. The columns of the locations are prefixed by "l". Locations are
+// ordered by household, then by path.
+func locationsQuery(where string) string {
+	return `
+SELECT l.id, l.household_id, COALESCE(l.parent_id, 0), l.name, l.kind,
+       COALESCE(g.name || ' / ', '') || COALESCE(p.name || ' / ', '') || l.name
+FROM location l
+LEFT JOIN location p ON p.id = l.parent_id
+LEFT JOIN location g ON g.id = p.parent_id
+WHERE ` + where + `
+ORDER BY l.household_id, 6, l.id;`
+}
+
+// Builds the subquery selecting the names of the tags of a plant, in
+// alphabetical order. plant is the placeholder or the column of the identifier
+// of the plant.
+func plantTags(plant string) string {
+	return `
+SELECT t.name
+FROM plant_tag pt
+JOIN tag t ON t.id = pt.tag_id
+WHERE pt.plant_id = ` + plant + `
+ORDER BY t.name`
+}
+
+// Builds the query selecting the tags of the plants of the households of the
+// user, or of all the plants if its identifier is 0, with the number of plants
+// having each of them, in alphabetical order. user is the placeholder of the
+// identifier of the user in the SQL dialect of the database.
+func tagCountsQuery(user string) string {
+	return `
+SELECT t.name, COUNT(*)
+FROM tag t
+JOIN plant_tag pt ON pt.tag_id = t.id
+JOIN plant p ON p.id = pt.plant_id
+WHERE ` + user + ` = 0 OR p.household_id IN (` + memberHouseholds(user) + `)
+GROUP BY t.name
+ORDER BY t.name;`
+}
+
+// Builds the query selecting the plants of the households of the user, or all
+// the plants if its identifier is 0, with their taxon, household, location and
+// its path, and tags, ordered by identifier. user is the placeholder of the
+// identifier of the user in the SQL dialect of the database, and tags the
+// expression selecting the tags of the plant "p", see plantTags, in this
+// dialect.
+func allPlantsQuery(user, tags string) string {
+	return `
+SELECT p.id, p.common_name, ` + taxonColumns + `,
+       COALESCE(p.household_id, 0), COALESCE(p.location_id, 0),
+       COALESCE(COALESCE(lg.name || ' / ', '') ||
+                COALESCE(lp.name || ' / ', '') || l.name, ''),
+       ` + tags + `
+FROM plant p
+LEFT JOIN taxon t ON t.id = p.taxon_id
+LEFT JOIN location l ON l.id = p.location_id
+LEFT JOIN location lp ON lp.id = l.parent_id
+LEFT JOIN location lg ON lg.id = lp.parent_id
+WHERE ` + user + ` = 0 OR p.household_id IN (` + memberHouseholds(user) + `)
+ORDER BY p.id;`
+}
+
+// Builds the query selecting the log entries of the plants of the households of
+// the user, or of all the plants if its identifier is 0, ordered by plant, then
+// in chronological order. user is the placeholder of the identifier of the user
+// in the SQL dialect of the database.
+func allLogsQuery(user string) string {
+	return `
+SELECT l.id, l.plant_id, l.description, l.event_type, l.occurred_at,
+       l.recorded_at
+FROM plant_log l
+JOIN plant p ON p.id = l.plant_id
+WHERE ` + user + ` = 0 OR p.household_id IN (` + memberHouseholds(user) + `)
+ORDER BY l.plant_id, l.occurred_at, l.id;`
+}
+
+// TaxonQuery selects taxa. Only the taxa of this family, genus and species,
+// ignoring case, are selected, an empty string selecting all the taxa.
+type TaxonQuery struct {
+	Family  string
+	Genus   string
+	Species string
+	// Maximum number of taxa selected, no limit if not positive.
+	Limit int
+}
+
+// Columns of a taxon prefixed by "t", in the order of the fields of
+// plants.Taxon. Missing taxa of a LEFT JOIN give the zero Taxon.
+const taxonColumns = `COALESCE(t.id, 0), COALESCE(t.family, ''),
+       COALESCE(t.genus, ''), COALESCE(t.species, ''),
+       COALESCE(t.infraspecific_rank, ''), COALESCE(t.infraspecific_name, ''),
+       COALESCE(t.cultivar, '')`
+
+// Builds the query selecting the taxa of q, in alphabetical order of their
+// names, and its arguments. placeholder returns the placeholder of the n-th
+// argument, starting at 1, in the SQL dialect of the database.
+func taxaQuery(q TaxonQuery, placeholder func(n int) string) (string, []any) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	for _, c := range []struct{ column, value string }{
+		{"t.family", q.Family},
+		{"t.genus", q.Genus},
+		{"t.species", q.Species},
+	} {
+		if c.value != "" {
+			where = append(
+				where,
+				"lower("+c.column+") = lower("+arg(c.value)+")",
+			)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT " + taxonColumns + " FROM taxon t")
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+	b.WriteString(" ORDER BY t.genus, t.species, t.infraspecific_rank,")
+	b.WriteString(" t.infraspecific_name, t.cultivar")
+	if q.Limit > 0 {
+		b.WriteString(" LIMIT " + arg(q.Limit))
+	}
+	b.WriteString(";")
+	return b.String(), args
+}
+
+// Builds the query selecting the distinct genera of the taxa, or the distinct
+// species of the taxa of genus if it is not empty, starting with prefix, after
+// their hybrid marker if any, and ignoring case, in alphabetical order, and its
+// arguments. At most limit names are selected, all of them if it is not
+// positive. placeholder returns the placeholder of the n-th argument, starting
+// at 1, in the SQL dialect of the database.
+func taxonNamesQuery(
+	genus, prefix string,
+	limit int,
+	placeholder func(n int) string,
+) (string, []any) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	// Names of hybrids also start with the prefix after their marker
+	startsWith := func(column string) string {
+		return "(lower(" + column + ") LIKE " + arg(likePrefix(prefix)) +
+			` ESCAPE '\' OR lower(` + column + ") LIKE " +
+			arg(likePrefix(plants.HybridMarker+prefix)) + ` ESCAPE '\')`
+	}
+	column := "genus"
+	where := startsWith("genus")
+	if genus != "" {
+		column = "species"
+		where = "lower(genus) = lower(" + arg(genus) + ") AND species <> ''" +
+			" AND " + startsWith("species")
+	}
+	query := "SELECT DISTINCT " + column + " FROM taxon WHERE " + where +
+		" ORDER BY " + column
+	if limit > 0 {
+		query += " LIMIT " + arg(limit)
+	}
+	return query + ";", args
+}
+
+// Returns the LIKE pattern matching the strings starting with prefix, in lower
+// case, escaped by backslashes.
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return r.Replace(strings.ToLower(prefix)) + "%"
+}
+
+// Returns t as an argument of a query, NULL if it is the zero time.
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"github.com/mgmu/hortus/internal/plants"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Markers of the matching parts of the snippets built by the databases.
+const (
+	snippetStart = "<mark>"
+	snippetStop  = "</mark>"
+)
+
+// Splits a snippet whose matching parts are between snippetStart and
+// snippetStop into fragments.
+func parseSnippet(s string) []plants.SnippetFragment {
+	fragments := []plants.SnippetFragment{}
+	for s != "" {
+		before, after, found := strings.Cut(s, snippetStart)
+		if before != "" {
+			fragments = append(fragments, plants.SnippetFragment{Text: before})
+		}
+		if !found {
+			break
+		}
+		match, rest, _ := strings.Cut(after, snippetStop)
+		if match != "" {
+			fragments = append(
+				fragments,
+				plants.SnippetFragment{Text: match, Match: true},
+			)
+		}
+		s = rest
+	}
+	return fragments
+}
+
+// Returns the words of a search query, in lower case and without accents.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(foldText(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Builds an FTS5 query matching the texts containing all the terms, as
+// prefixes of their words.
+func fts5Query(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Returns s in lower case and without accents.
+func foldText(s string) string {
+	t := transform.Chain(
+		norm.NFD,
+		runes.Remove(runes.In(unicode.Mn)),
+		norm.NFC,
+	)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
@@ -0,0 +1,2563 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/mgmu/hortus/internal/plants"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Layout of the creation time of plants, the format of strftime. Unlike the
+// format used by the driver for time.Time values, its length is fixed, so
+// values can be compared as text.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000"
+
+// SQLiteDatabase is a Database that keeps its data in a single SQLite file,
+// for installations where running PostgreSQL is not worth it.
+type SQLiteDatabase struct {
+	db *sql.DB
+	// Maximum duration of a query, no limit if zero. Migrations are not
+	// limited.
+	QueryTimeout time.Duration
+}
+
+// Connect opens the SQLite file whose path is stored in the HORTUS_SQLITE_PATH
+// environment variable, creating it if needed. The tables are created by the
+// migrations, see MigrateUp.
+func (db *SQLiteDatabase) Connect(ctx context.Context) error {
+	path := os.Getenv("HORTUS_SQLITE_PATH")
+	if path == "" {
+		return errors.New("database: SQLite file path not set")
+	}
+
+	// Foreign keys are not enforced by SQLite unless asked to, for each
+	// connection.
+	conn, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		return sqliteError(err)
+	}
+	// SQLite only allows one writer at a time
+	conn.SetMaxOpenConns(1)
+	err = conn.PingContext(ctx)
+	if err != nil {
+		conn.Close()
+		return sqliteError(err)
+	}
+	db.db = conn
+	return nil
+}
+
+// Close closes the database file.
+func (db *SQLiteDatabase) Close() error {
+	return db.db.Close()
+}
+
+// GetPlantsShortDescription queries the database for the identifier, common
+// name and creation date of the plants in the 'plants' table selected by q and
+// returns them in a slice.
+func (db *SQLiteDatabase) GetPlantsShortDescription(
+	ctx context.Context,
+	q PlantQuery,
+) ([]plants.PlantShortDesc, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query, args := plantListQuery(
+		q,
+		func(n int) string {
+			return "?" + strconv.Itoa(n)
+		},
+		"COALESCE((SELECT group_concat(name, ',') FROM ("+
+			plantTags("plant.id")+")), '')",
+		func(expr string) string {
+			return "lower(" + expr + ")"
+		},
+	)
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC().Format(sqliteTimeLayout)
+		}
+	}
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	descs := []plants.PlantShortDesc{}
+	for rows.Next() {
+		var desc plants.PlantShortDesc
+		var tags string
+		err = rows.Scan(
+			&desc.Id,
+			&desc.CommonName,
+			&desc.CreatedAt,
+			&desc.LocationId,
+			&tags,
+		)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		// Tags cannot contain commas, see plants.ParseTag
+		if tags != "" {
+			desc.Tags = strings.Split(tags, ",")
+		}
+		descs = append(descs, desc)
+	}
+	return descs, sqliteError(rows.Err())
+}
+
+// AddNewPlant attempts to insert a new entry in the 'plants' table with the
+// provided common name of the plant, of the taxon of identifier taxonId and
+// owned by the household of identifier householdId, or of no taxon and by no
+// one if they are 0. On success, returns the identifier of the inserted entry
+// and a nil error.
+func (db *SQLiteDatabase) AddNewPlant(
+	ctx context.Context,
+	householdId int,
+	comm string,
+	taxonId int,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO plant (common_name, taxon_id, created_at, household_id)
+VALUES (?, NULLIF(?, 0), ?, NULLIF(?, 0))
+RETURNING id;`,
+		comm,
+		taxonId,
+		time.Now().UTC().Format(sqliteTimeLayout),
+		householdId,
+	)
+	var id int
+	err := row.Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, nil
+}
+
+// GetPlantRole queries the database for the role of the user of identifier
+// userId in the household owning the plant of identifier plantId. The plant is
+// not found if the user is not a member of its household.
+func (db *SQLiteDatabase) GetPlantRole(
+	ctx context.Context,
+	userId, plantId int,
+) (plants.Role, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var role plants.Role
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT m.role
+FROM plant p
+JOIN household_member m ON m.household_id = p.household_id
+WHERE p.id=? AND m.user_id=?;`,
+		plantId,
+		userId,
+	).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errPlantNotFound
+	}
+	if err != nil {
+		return "", sqliteError(err)
+	}
+	return role, nil
+}
+
+// GetPlantNames queries the database for the common name and the taxon of the
+// plant of given id, the zero Taxon if it has none.
+func (db *SQLiteDatabase) GetPlantNames(
+	ctx context.Context,
+	id int,
+) (string, plants.Taxon, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT p.common_name, `+taxonColumns+`
+FROM plant p
+LEFT JOIN taxon t ON t.id = p.taxon_id
+WHERE p.id=?;`,
+		id,
+	)
+	var comm string
+	var t plants.Taxon
+	err := row.Scan(
+		&comm,
+		&t.Id,
+		&t.Family,
+		&t.Genus,
+		&t.Species,
+		&t.Rank,
+		&t.Infraspecies,
+		&t.Cultivar,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", plants.Taxon{}, errPlantNotFound
+	}
+	if err != nil {
+		return "", plants.Taxon{}, sqliteError(err)
+	}
+	return comm, t, nil
+}
+
+// UpdatePlant replaces the common name and the taxon of the plant of given id,
+// which has no taxon if taxonId is 0.
+func (db *SQLiteDatabase) UpdatePlant(
+	ctx context.Context,
+	id int,
+	comm string,
+	taxonId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		"UPDATE plant SET common_name=?, taxon_id=NULLIF(?, 0) WHERE id=?;",
+		comm,
+		taxonId,
+		id,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errPlantNotFound)
+}
+
+// DeletePlant deletes the plant of given id along with all its logs, in a
+// single transaction.
+func (db *SQLiteDatabase) DeletePlant(ctx context.Context, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM plant_log WHERE plant_id=?;", id)
+	if err != nil {
+		return sqliteError(err)
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM plant WHERE id=?;", id)
+	if err != nil {
+		return sqliteError(err)
+	}
+	err = checkAffected(res, errPlantNotFound)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return sqliteError(tx.Commit())
+}
+
+// Returns notFound if res affected no row.
+func checkAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return sqliteError(err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// GetPlantsLog queries the database for all the logs of the plant of given
+// identifier and returns them in a slice, in chronological order.
+func (db *SQLiteDatabase) GetPlantLogs(
+	ctx context.Context,
+	id int,
+) ([]plants.PlantLog, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		`
+SELECT id, plant_id, description, event_type, occurred_at, recorded_at
+FROM plant_log
+WHERE plant_id=?
+ORDER BY occurred_at, id;`,
+		id,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	plantLogs := []plants.PlantLog{}
+	for rows.Next() {
+		var l plants.PlantLog
+		err = rows.Scan(
+			&l.Id,
+			&l.PlantId,
+			&l.Desc,
+			&l.EventType,
+			&l.OccurredAt,
+			&l.RecordedAt,
+		)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		plantLogs = append(plantLogs, l)
+	}
+	return plantLogs, sqliteError(rows.Err())
+}
+
+// AddNewPlantLog attempts to insert a new entry in the 'plants_log` table for
+// the plant of given identifier with given description, event type and time of
+// occurrence. The entry is recorded at the current time. On success, returns
+// the identifier of the entry.
+func (db *SQLiteDatabase) AddNewPlantLog(
+	ctx context.Context,
+	id int,
+	desc string,
+	event plants.EventType,
+	occurredAt time.Time,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	// Timestamps are stored as text in UTC so that they sort chronologically
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO plant_log
+(plant_id, description, event_type, occurred_at, recorded_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id;`,
+		id,
+		desc,
+		int(event),
+		occurredAt.UTC(),
+		time.Now().UTC(),
+	)
+	var logId int
+	err := row.Scan(&logId)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return logId, nil
+}
+
+// GetPlantLog queries the database for the log entry of identifier logId, if it
+// belongs to the plant of identifier plantId.
+func (db *SQLiteDatabase) GetPlantLog(
+	ctx context.Context,
+	plantId, logId int,
+) (plants.PlantLog, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT id, plant_id, description, event_type, occurred_at, recorded_at
+FROM plant_log
+WHERE id=? AND plant_id=?;`,
+		logId,
+		plantId,
+	)
+	var l plants.PlantLog
+	err := row.Scan(
+		&l.Id,
+		&l.PlantId,
+		&l.Desc,
+		&l.EventType,
+		&l.OccurredAt,
+		&l.RecordedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.PlantLog{}, errLogNotFound
+	}
+	if err != nil {
+		return plants.PlantLog{}, sqliteError(err)
+	}
+	return l, nil
+}
+
+// UpdatePlantLog replaces the description, event type and time of occurrence of
+// the log entry of identifier logId, if it belongs to the plant of identifier
+// plantId.
+func (db *SQLiteDatabase) UpdatePlantLog(
+	ctx context.Context,
+	plantId, logId int,
+	desc string,
+	event plants.EventType,
+	occurredAt time.Time,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		`
+UPDATE plant_log
+SET description=?, event_type=?, occurred_at=?
+WHERE id=? AND plant_id=?;`,
+		desc,
+		int(event),
+		occurredAt.UTC(),
+		logId,
+		plantId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errLogNotFound)
+}
+
+// DeletePlantLog deletes the log entry of identifier logId, if it belongs to the
+// plant of identifier plantId.
+func (db *SQLiteDatabase) DeletePlantLog(ctx context.Context, plantId, logId int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		"DELETE FROM plant_log WHERE id=? AND plant_id=?;",
+		logId,
+		plantId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errLogNotFound)
+}
+
+// Search queries the database for the plants whose names and the log entries
+// whose descriptions contain words starting with all the words of query,
+// ignoring accents. Only the plants of the households of the user of given
+// identifier are searched, unless it is 0. Returns at most limit results, the
+// best ranked first.
+func (db *SQLiteDatabase) Search(
+	ctx context.Context,
+	userId int,
+	query string,
+	limit int,
+) ([]plants.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []plants.SearchResult{}, nil
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	// bm25 is lower for better matches
+	rows, err := db.db.QueryContext(
+		ctx,
+		`
+SELECT 'plant', p.id, 0, p.common_name,
+       highlight(plant_fts, 0, ?2, ?3) || ' ' ||
+       coalesce(highlight(plant_fts, 1, ?2, ?3), '') || ' ' ||
+       coalesce(highlight(plant_fts, 2, ?2, ?3), ''),
+       -bm25(plant_fts)
+FROM plant_fts JOIN plant p ON p.id = plant_fts.rowid
+WHERE plant_fts MATCH ?1 AND (?5 = 0 OR p.household_id IN (
+      SELECT household_id FROM household_member WHERE user_id = ?5
+))
+UNION ALL
+SELECT 'log', p.id, l.id, p.common_name,
+       snippet(plant_log_fts, 0, ?2, ?3, '…', 16),
+       -bm25(plant_log_fts)
+FROM plant_log_fts
+JOIN plant_log l ON l.id = plant_log_fts.rowid
+JOIN plant p ON p.id = l.plant_id
+WHERE plant_log_fts MATCH ?1 AND (?5 = 0 OR p.household_id IN (
+      SELECT household_id FROM household_member WHERE user_id = ?5
+))
+ORDER BY 6 DESC
+LIMIT ?4;`,
+		fts5Query(terms),
+		snippetStart,
+		snippetStop,
+		limit,
+		userId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	results := []plants.SearchResult{}
+	for rows.Next() {
+		var r plants.SearchResult
+		var snippet string
+		err = rows.Scan(
+			&r.Kind,
+			&r.PlantId,
+			&r.LogId,
+			&r.CommonName,
+			&snippet,
+			&r.Rank,
+		)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		r.Snippet = parseSnippet(snippet)
+		results = append(results, r)
+	}
+	return results, sqliteError(rows.Err())
+}
+
+// GetCareSchedules queries the database for the care schedules of the plant of
+// given identifier.
+func (db *SQLiteDatabase) GetCareSchedules(
+	ctx context.Context,
+	plantId int,
+) ([]plants.CareSchedule, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		`
+SELECT id, plant_id, event_type, interval_days, season_start, season_end,
+       created_at
+FROM care_schedule
+WHERE plant_id=?
+ORDER BY id;`,
+		plantId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	schedules := []plants.CareSchedule{}
+	for rows.Next() {
+		s, err := scanCareSchedule(rows)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, sqliteError(rows.Err())
+}
+
+// GetCareSchedule queries the database for the care schedule of identifier
+// scheduleId, if it belongs to the plant of identifier plantId.
+func (db *SQLiteDatabase) GetCareSchedule(
+	ctx context.Context,
+	plantId, scheduleId int,
+) (plants.CareSchedule, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT id, plant_id, event_type, interval_days, season_start, season_end,
+       created_at
+FROM care_schedule
+WHERE id=? AND plant_id=?;`,
+		scheduleId,
+		plantId,
+	)
+	s, err := scanCareSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.CareSchedule{}, errScheduleNotFound
+	}
+	if err != nil {
+		return plants.CareSchedule{}, sqliteError(err)
+	}
+	return s, nil
+}
+
+// Scans a row of the 'care_schedule' table, possibly followed by other
+// columns scanned into dest.
+func scanCareSchedule(
+	row interface{ Scan(dest ...any) error },
+	dest ...any,
+) (plants.CareSchedule, error) {
+	var s plants.CareSchedule
+	var start, end int
+	err := row.Scan(append(
+		[]any{
+			&s.Id,
+			&s.PlantId,
+			&s.EventType,
+			&s.IntervalDays,
+			&start,
+			&end,
+			&s.CreatedAt,
+		},
+		dest...,
+	)...)
+	s.SeasonStart, s.SeasonEnd = time.Month(start), time.Month(end)
+	return s, err
+}
+
+// AddCareSchedule inserts the care schedule s of the plant of identifier
+// s.PlantId, created at the current time, and returns its identifier. The
+// identifier and creation time of s are ignored.
+func (db *SQLiteDatabase) AddCareSchedule(
+	ctx context.Context,
+	s plants.CareSchedule,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO care_schedule
+(plant_id, event_type, interval_days, season_start, season_end, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id;`,
+		s.PlantId,
+		int(s.EventType),
+		s.IntervalDays,
+		int(s.SeasonStart),
+		int(s.SeasonEnd),
+		time.Now().UTC(),
+	)
+	var id int
+	err := row.Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, nil
+}
+
+// UpdateCareSchedule replaces the event type, interval and season of the care
+// schedule of identifier s.Id, if it belongs to the plant of identifier
+// s.PlantId.
+func (db *SQLiteDatabase) UpdateCareSchedule(
+	ctx context.Context,
+	s plants.CareSchedule,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		`
+UPDATE care_schedule
+SET event_type=?3, interval_days=?4, season_start=?5, season_end=?6
+WHERE id=?1 AND plant_id=?2;`,
+		s.Id,
+		s.PlantId,
+		int(s.EventType),
+		s.IntervalDays,
+		int(s.SeasonStart),
+		int(s.SeasonEnd),
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errScheduleNotFound)
+}
+
+// DeleteCareSchedule deletes the care schedule of identifier scheduleId, if it
+// belongs to the plant of identifier plantId.
+func (db *SQLiteDatabase) DeleteCareSchedule(
+	ctx context.Context,
+	plantId, scheduleId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		"DELETE FROM care_schedule WHERE id=? AND plant_id=?;",
+		scheduleId,
+		plantId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errScheduleNotFound)
+}
+
+// GetCareTasks queries the database for the care schedules of the plant of
+// given identifier, or of all the plants of the households of the user if it
+// is 0, and the latest log entry of their event type, and returns their next
+// occurrence as of now. A user identifier of 0 selects the plants of all the
+// households.
+func (db *SQLiteDatabase) GetCareTasks(
+	ctx context.Context,
+	userId, plantId int,
+	now time.Time,
+) ([]plants.CareTask, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		careTasksQuery("?1", "?2"),
+		userId,
+		plantId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	tasks := []plants.CareTask{}
+	for rows.Next() {
+		var commonName string
+		var lastDone sql.NullTime
+		var householdId int
+		s, err := scanCareSchedule(rows, &commonName, &lastDone, &householdId)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		task := s.Task(commonName, lastDone.Time, now)
+		task.HouseholdId = householdId
+		tasks = append(tasks, task)
+	}
+	return tasks, sqliteError(rows.Err())
+}
+
+// AddPhoto inserts the metadata of the photo p of the plant of identifier
+// p.PlantId, created at the current time, and returns its identifier. The
+// identifier and creation time of p are ignored.
+func (db *SQLiteDatabase) AddPhoto(
+	ctx context.Context,
+	p plants.Photo,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO photo
+(plant_id, log_id, blob_key, thumbnail_key, content_type, size, caption,
+ taken_at, created_at)
+VALUES (?, NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?)
+RETURNING id;`,
+		p.PlantId,
+		p.LogId,
+		p.Key,
+		p.ThumbnailKey,
+		p.ContentType,
+		p.Size,
+		p.Caption,
+		nullTime(p.TakenAt.UTC()),
+		time.Now().UTC(),
+	)
+	var id int
+	err := row.Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, nil
+}
+
+// GetPhotos queries the database for the metadata of the photos of the plant of
+// given identifier, in the order they were added.
+func (db *SQLiteDatabase) GetPhotos(
+	ctx context.Context,
+	plantId int,
+) ([]plants.Photo, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		`
+SELECT id, plant_id, coalesce(log_id, 0), blob_key, thumbnail_key,
+       content_type, size, caption, taken_at, created_at
+FROM photo
+WHERE plant_id=?
+ORDER BY created_at, id;`,
+		plantId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	photos := []plants.Photo{}
+	for rows.Next() {
+		p, err := scanPhoto(rows)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		photos = append(photos, p)
+	}
+	return photos, sqliteError(rows.Err())
+}
+
+// GetPhoto queries the database for the metadata of the photo of identifier
+// photoId, if it belongs to the plant of identifier plantId.
+func (db *SQLiteDatabase) GetPhoto(
+	ctx context.Context,
+	plantId, photoId int,
+) (plants.Photo, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT id, plant_id, coalesce(log_id, 0), blob_key, thumbnail_key,
+       content_type, size, caption, taken_at, created_at
+FROM photo
+WHERE id=? AND plant_id=?;`,
+		photoId,
+		plantId,
+	)
+	p, err := scanPhoto(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.Photo{}, errPhotoNotFound
+	}
+	if err != nil {
+		return plants.Photo{}, sqliteError(err)
+	}
+	return p, nil
+}
+
+// Scans a row of the 'photo' table, whose capture time may be NULL.
+func scanPhoto(row interface{ Scan(dest ...any) error }) (plants.Photo, error) {
+	var p plants.Photo
+	var takenAt sql.NullTime
+	err := row.Scan(
+		&p.Id,
+		&p.PlantId,
+		&p.LogId,
+		&p.Key,
+		&p.ThumbnailKey,
+		&p.ContentType,
+		&p.Size,
+		&p.Caption,
+		&takenAt,
+		&p.CreatedAt,
+	)
+	p.TakenAt = takenAt.Time
+	return p, err
+}
+
+// DeletePhoto deletes the metadata of the photo of identifier photoId, if it
+// belongs to the plant of identifier plantId.
+func (db *SQLiteDatabase) DeletePhoto(
+	ctx context.Context,
+	plantId, photoId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		"DELETE FROM photo WHERE id=? AND plant_id=?;",
+		photoId,
+		plantId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errPhotoNotFound)
+}
+
+// AddUser inserts a user of given username and bcrypt password hash, and
+// returns its identifier. The user is the owner of a new household named after
+// it. The household of the first user is given the plants that have no
+// household, created before there were users. Returns an error if the username
+// is already taken, ignoring case.
+func (db *SQLiteDatabase) AddUser(
+	ctx context.Context,
+	username, passwordHash string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(
+		ctx,
+		`
+INSERT INTO "user" (username, password_hash, created_at)
+VALUES (?, ?, ?)
+RETURNING id;`,
+		username,
+		passwordHash,
+		time.Now().UTC(),
+	).Scan(&id)
+	err = sqliteError(err)
+	if errors.Is(err, ErrConflict) {
+		return 0, errUsernameTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+	householdId, err := insertHousehold(ctx, tx, id, username)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`
+UPDATE plant SET household_id = ?1
+WHERE household_id IS NULL AND (SELECT count(*) FROM "user") = 1;`,
+		householdId,
+	)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, sqliteError(tx.Commit())
+}
+
+// GetUser queries the database for the user of given identifier.
+func (db *SQLiteDatabase) GetUser(ctx context.Context, id int) (plants.User, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var u plants.User
+	err := db.db.QueryRowContext(
+		ctx,
+		`SELECT id, username, created_at FROM "user" WHERE id=?;`,
+		id,
+	).Scan(&u.Id, &u.Username, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.User{}, errUserNotFound
+	}
+	if err != nil {
+		return plants.User{}, sqliteError(err)
+	}
+	return u, nil
+}
+
+// GetUserByName queries the database for the user of given username, ignoring
+// case, and returns it with the bcrypt hash of its password.
+func (db *SQLiteDatabase) GetUserByName(
+	ctx context.Context,
+	username string,
+) (plants.User, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var u plants.User
+	var hash string
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT id, username, created_at, password_hash
+FROM "user"
+WHERE lower(username) = lower(?);`,
+		username,
+	).Scan(&u.Id, &u.Username, &u.CreatedAt, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.User{}, "", errUserNotFound
+	}
+	if err != nil {
+		return plants.User{}, "", sqliteError(err)
+	}
+	return u, hash, nil
+}
+
+// AddToken inserts the token t, identified by the SHA-256 hash of its secret,
+// and returns its identifier. Returns an error if a token of same hash exists.
+func (db *SQLiteDatabase) AddToken(
+	ctx context.Context,
+	t plants.Token,
+	hash string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO api_token (user_id, name, token_hash, scope, created_at)
+VALUES (NULLIF(?, 0), ?, ?, ?, ?)
+RETURNING id;`,
+		t.UserId,
+		t.Name,
+		hash,
+		t.Scope,
+		time.Now().UTC(),
+	).Scan(&id)
+	err = sqliteError(err)
+	if errors.Is(err, ErrConflict) {
+		return 0, errTokenExists
+	}
+	return id, err
+}
+
+// GetTokenByHash queries the database for the token whose secret has the given
+// SHA-256 hash. Revoked tokens are not found.
+func (db *SQLiteDatabase) GetTokenByHash(
+	ctx context.Context,
+	hash string,
+) (plants.Token, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT id, coalesce(user_id, 0), name, scope, created_at, revoked_at
+FROM api_token
+WHERE token_hash=? AND revoked_at IS NULL;`,
+		hash,
+	)
+	t, err := scanToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.Token{}, errTokenNotFound
+	}
+	if err != nil {
+		return plants.Token{}, sqliteError(err)
+	}
+	return t, nil
+}
+
+// GetTokens queries the database for the tokens of the user of given
+// identifier, or for all the tokens if userId is 0, including the revoked ones.
+func (db *SQLiteDatabase) GetTokens(
+	ctx context.Context,
+	userId int,
+) ([]plants.Token, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		`
+SELECT id, coalesce(user_id, 0), name, scope, created_at, revoked_at
+FROM api_token
+WHERE ?1=0 OR user_id=?1
+ORDER BY created_at, id;`,
+		userId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	tokens := []plants.Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, sqliteError(rows.Err())
+}
+
+// Scans a row of the 'api_token' table, whose revocation time may be NULL.
+func scanToken(row interface{ Scan(dest ...any) error }) (plants.Token, error) {
+	var t plants.Token
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&t.Id,
+		&t.UserId,
+		&t.Name,
+		&t.Scope,
+		&t.CreatedAt,
+		&revokedAt,
+	)
+	t.RevokedAt = revokedAt.Time
+	return t, err
+}
+
+// RevokeToken revokes the token of given identifier, if it belongs to the user
+// of identifier userId or if userId is 0. Revoking a revoked token is an error.
+func (db *SQLiteDatabase) RevokeToken(ctx context.Context, userId, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		`
+UPDATE api_token SET revoked_at = ?3
+WHERE id=?1 AND (?2=0 OR user_id=?2) AND revoked_at IS NULL;`,
+		id,
+		userId,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errTokenNotFound)
+}
+
+// AddHousehold inserts a household of given name, of which the user of
+// identifier userId is the owner, and returns its identifier.
+func (db *SQLiteDatabase) AddHousehold(
+	ctx context.Context,
+	userId int,
+	name string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	id, err := insertHousehold(ctx, tx, userId, name)
+	if err != nil {
+		return 0, err
+	}
+	return id, sqliteError(tx.Commit())
+}
+
+// Inserts a household of given name in tx, of which the user of identifier
+// userId is the owner, and returns its identifier.
+func insertHousehold(
+	ctx context.Context,
+	tx *sql.Tx,
+	userId int,
+	name string,
+) (int, error) {
+	now := time.Now().UTC()
+	var id int
+	err := tx.QueryRowContext(
+		ctx,
+		"INSERT INTO household (name, created_at) VALUES (?, ?) RETURNING id;",
+		name,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`
+INSERT INTO household_member (household_id, user_id, role, joined_at)
+VALUES (?, ?, ?, ?);`,
+		id,
+		userId,
+		plants.RoleOwner,
+		now,
+	)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, nil
+}
+
+// GetHouseholds queries the database for the households of which the user of
+// given identifier is a member, with the role of the user.
+func (db *SQLiteDatabase) GetHouseholds(
+	ctx context.Context,
+	userId int,
+) ([]plants.Household, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		`
+SELECT h.id, h.name, m.role, h.created_at
+FROM household h
+JOIN household_member m ON m.household_id = h.id
+WHERE m.user_id=?
+ORDER BY h.created_at, h.id;`,
+		userId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	households := []plants.Household{}
+	for rows.Next() {
+		var h plants.Household
+		err := rows.Scan(&h.Id, &h.Name, &h.Role, &h.CreatedAt)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		households = append(households, h)
+	}
+	return households, sqliteError(rows.Err())
+}
+
+// GetHousehold queries the database for the household of given identifier and
+// its members, in the order they joined.
+func (db *SQLiteDatabase) GetHousehold(
+	ctx context.Context,
+	id int,
+) (plants.Household, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var h plants.Household
+	err := db.db.QueryRowContext(
+		ctx,
+		"SELECT id, name, created_at FROM household WHERE id=?;",
+		id,
+	).Scan(&h.Id, &h.Name, &h.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.Household{}, errHouseholdNotFound
+	}
+	if err != nil {
+		return plants.Household{}, sqliteError(err)
+	}
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		`
+SELECT m.user_id, u.username, m.role, m.joined_at
+FROM household_member m
+JOIN "user" u ON u.id = m.user_id
+WHERE m.household_id=?
+ORDER BY m.joined_at, m.user_id;`,
+		id,
+	)
+	if err != nil {
+		return plants.Household{}, sqliteError(err)
+	}
+	defer rows.Close()
+
+	h.Members = []plants.Member{}
+	for rows.Next() {
+		var m plants.Member
+		err := rows.Scan(&m.UserId, &m.Username, &m.Role, &m.JoinedAt)
+		if err != nil {
+			return plants.Household{}, sqliteError(err)
+		}
+		h.Members = append(h.Members, m)
+	}
+	return h, sqliteError(rows.Err())
+}
+
+// UpdateHousehold renames the household of given identifier.
+func (db *SQLiteDatabase) UpdateHousehold(
+	ctx context.Context,
+	id int,
+	name string,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		"UPDATE household SET name=? WHERE id=?;",
+		name,
+		id,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errHouseholdNotFound)
+}
+
+// DeleteHousehold deletes the household of given identifier, with its members
+// and invitations. Returns an error if the household still has plants.
+func (db *SQLiteDatabase) DeleteHousehold(ctx context.Context, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	var hasPlants bool
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM plant WHERE household_id=?);",
+		id,
+	).Scan(&hasPlants)
+	if err != nil {
+		return sqliteError(err)
+	}
+	if hasPlants {
+		return errHouseholdNotEmpty
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM household WHERE id=?;", id)
+	if err != nil {
+		return sqliteError(err)
+	}
+	err = checkAffected(res, errHouseholdNotFound)
+	if err != nil {
+		return err
+	}
+	return sqliteError(tx.Commit())
+}
+
+// GetMemberRole queries the database for the role of the user of identifier
+// userId in the household of identifier householdId.
+func (db *SQLiteDatabase) GetMemberRole(
+	ctx context.Context,
+	householdId, userId int,
+) (plants.Role, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var role plants.Role
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT role FROM household_member
+WHERE household_id=? AND user_id=?;`,
+		householdId,
+		userId,
+	).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errMemberNotFound
+	}
+	if err != nil {
+		return "", sqliteError(err)
+	}
+	return role, nil
+}
+
+// SetMemberRole changes the role of the user of identifier userId in the
+// household of identifier householdId. Returns an error if the household would
+// be left without owner.
+func (db *SQLiteDatabase) SetMemberRole(
+	ctx context.Context,
+	householdId, userId int,
+	role plants.Role,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`
+UPDATE household_member SET role=?
+WHERE household_id=? AND user_id=?;`,
+		role,
+		householdId,
+		userId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	err = checkAffected(res, errMemberNotFound)
+	if err != nil {
+		return err
+	}
+	err = checkOwners(ctx, tx, householdId)
+	if err != nil {
+		return err
+	}
+	return sqliteError(tx.Commit())
+}
+
+// DeleteMember removes the user of identifier userId from the household of
+// identifier householdId. Returns an error if the household would be left
+// without owner.
+func (db *SQLiteDatabase) DeleteMember(
+	ctx context.Context,
+	householdId, userId int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM household_member WHERE household_id=? AND user_id=?;",
+		householdId,
+		userId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	err = checkAffected(res, errMemberNotFound)
+	if err != nil {
+		return err
+	}
+	err = checkOwners(ctx, tx, householdId)
+	if err != nil {
+		return err
+	}
+	return sqliteError(tx.Commit())
+}
+
+// Returns an error if the household of given identifier has no owner in tx.
+// Writes of SQLite are serialized, so concurrent changes of roles cannot leave
+// the household without owner.
+func checkOwners(ctx context.Context, tx *sql.Tx, householdId int) error {
+	var owners int
+	err := tx.QueryRowContext(
+		ctx,
+		`
+SELECT count(*) FROM household_member
+WHERE household_id=? AND role=?;`,
+		householdId,
+		plants.RoleOwner,
+	).Scan(&owners)
+	if err != nil {
+		return sqliteError(err)
+	}
+	if owners == 0 {
+		return errLastOwner
+	}
+	return nil
+}
+
+// AddInvitation inserts the invitation inv, identified by the SHA-256 hash of
+// the secret of its link, and returns its identifier.
+func (db *SQLiteDatabase) AddInvitation(
+	ctx context.Context,
+	inv plants.Invitation,
+	hash string,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO household_invitation
+(household_id, token_hash, role, created_at, expires_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id;`,
+		inv.HouseholdId,
+		hash,
+		inv.Role,
+		time.Now().UTC().Format(sqliteTimeLayout),
+		inv.ExpiresAt.UTC().Format(sqliteTimeLayout),
+	).Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, nil
+}
+
+// GetInvitations queries the database for the invitations to the household of
+// given identifier that are neither accepted nor expired at now.
+func (db *SQLiteDatabase) GetInvitations(
+	ctx context.Context,
+	householdId int,
+	now time.Time,
+) ([]plants.Invitation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		invitationsQuery("i.household_id=? AND i.expires_at > ?"),
+		householdId,
+		now.UTC().Format(sqliteTimeLayout),
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	invitations := []plants.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, sqliteError(rows.Err())
+}
+
+// GetInvitationByHash queries the database for the invitation whose link has a
+// secret of given SHA-256 hash, if it is neither accepted nor expired at now.
+func (db *SQLiteDatabase) GetInvitationByHash(
+	ctx context.Context,
+	hash string,
+	now time.Time,
+) (plants.Invitation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		invitationsQuery("i.token_hash=? AND i.expires_at > ?"),
+		hash,
+		now.UTC().Format(sqliteTimeLayout),
+	)
+	inv, err := scanInvitation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.Invitation{}, errInvitationNotFound
+	}
+	if err != nil {
+		return plants.Invitation{}, sqliteError(err)
+	}
+	return inv, nil
+}
+
+// Scans a row of an invitations query, see invitationsQuery.
+func scanInvitation(
+	row interface{ Scan(dest ...any) error },
+) (plants.Invitation, error) {
+	var inv plants.Invitation
+	err := row.Scan(
+		&inv.Id,
+		&inv.HouseholdId,
+		&inv.HouseholdName,
+		&inv.Role,
+		&inv.CreatedAt,
+		&inv.ExpiresAt,
+	)
+	return inv, err
+}
+
+// AcceptInvitation adds the user of identifier userId to the household of the
+// invitation of identifier id, with the role of the invitation, if it is
+// neither accepted nor expired at now. The invitation cannot be used again.
+func (db *SQLiteDatabase) AcceptInvitation(
+	ctx context.Context,
+	id, userId int,
+	now time.Time,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	at := now.UTC().Format(sqliteTimeLayout)
+	var householdId int
+	var role plants.Role
+	err = tx.QueryRowContext(
+		ctx,
+		`
+UPDATE household_invitation SET accepted_by=?2, accepted_at=?3
+WHERE id=?1 AND accepted_at IS NULL AND expires_at > ?3
+RETURNING household_id, role;`,
+		id,
+		userId,
+		at,
+	).Scan(&householdId, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvitationNotFound
+	}
+	if err != nil {
+		return sqliteError(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`
+INSERT INTO household_member (household_id, user_id, role, joined_at)
+VALUES (?, ?, ?, ?);`,
+		householdId,
+		userId,
+		role,
+		now.UTC(),
+	)
+	err = sqliteError(err)
+	if errors.Is(err, ErrConflict) {
+		return errAlreadyMember
+	}
+	if err != nil {
+		return err
+	}
+	return sqliteError(tx.Commit())
+}
+
+// DeleteInvitation deletes the invitation of identifier id to the household of
+// identifier householdId, if it is not accepted yet.
+func (db *SQLiteDatabase) DeleteInvitation(
+	ctx context.Context,
+	householdId, id int,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		`
+DELETE FROM household_invitation
+WHERE id=? AND household_id=? AND accepted_at IS NULL;`,
+		id,
+		householdId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errInvitationNotFound)
+}
+
+// AddLocation inserts the location l, in the parent location of identifier
+// l.ParentId unless it is 0, and returns its identifier.
+func (db *SQLiteDatabase) AddLocation(
+	ctx context.Context,
+	l plants.Location,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO location (household_id, parent_id, name, kind)
+VALUES (?, NULLIF(?, 0), ?, ?)
+RETURNING id;`,
+		l.HouseholdId,
+		l.ParentId,
+		l.Name,
+		l.Kind,
+	).Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, nil
+}
+
+// GetLocations queries the database for the locations of the households of
+// the user of identifier userId, or for all the locations if it is 0.
+func (db *SQLiteDatabase) GetLocations(
+	ctx context.Context,
+	userId int,
+) ([]plants.Location, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		locationsQuery(
+			"?1 = 0 OR l.household_id IN ("+memberHouseholds("?1")+")",
+		),
+		userId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	locations := []plants.Location{}
+	for rows.Next() {
+		l, err := scanLocation(rows)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		locations = append(locations, l)
+	}
+	return locations, sqliteError(rows.Err())
+}
+
+// GetLocation queries the database for the location of given identifier.
+func (db *SQLiteDatabase) GetLocation(
+	ctx context.Context,
+	id int,
+) (plants.Location, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(ctx, locationsQuery("l.id=?"), id)
+	l, err := scanLocation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.Location{}, errLocationNotFound
+	}
+	if err != nil {
+		return plants.Location{}, sqliteError(err)
+	}
+	return l, nil
+}
+
+// Scans a row of a locations query, see locationsQuery.
+func scanLocation(
+	row interface{ Scan(dest ...any) error },
+) (plants.Location, error) {
+	var l plants.Location
+	err := row.Scan(
+		&l.Id,
+		&l.HouseholdId,
+		&l.ParentId,
+		&l.Name,
+		&l.Kind,
+		&l.Path,
+	)
+	return l, err
+}
+
+// UpdateLocation replaces the name and the parent location of the location of
+// identifier l.Id. Its household and kind are left unchanged.
+func (db *SQLiteDatabase) UpdateLocation(
+	ctx context.Context,
+	l plants.Location,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := db.db.ExecContext(
+		ctx,
+		"UPDATE location SET name=?2, parent_id=NULLIF(?3, 0) WHERE id=?1;",
+		l.Id,
+		l.Name,
+		l.ParentId,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return checkAffected(res, errLocationNotFound)
+}
+
+// DeleteLocation deletes the location of given identifier, which must contain
+// neither plants nor other locations.
+func (db *SQLiteDatabase) DeleteLocation(ctx context.Context, id int) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	var used bool
+	err = tx.QueryRowContext(
+		ctx,
+		`
+SELECT EXISTS (SELECT 1 FROM plant WHERE location_id=?1)
+       OR EXISTS (SELECT 1 FROM location WHERE parent_id=?1);`,
+		id,
+	).Scan(&used)
+	if err != nil {
+		return sqliteError(err)
+	}
+	if used {
+		return errLocationNotEmpty
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM location WHERE id=?;", id)
+	if err != nil {
+		return sqliteError(err)
+	}
+	err = checkAffected(res, errLocationNotFound)
+	if err != nil {
+		return err
+	}
+	return sqliteError(tx.Commit())
+}
+
+// GetPlantLocation queries the database for the identifier of the household of
+// the plant of given identifier, 0 if it has none, and for the location of the
+// plant, the zero Location if it has none.
+func (db *SQLiteDatabase) GetPlantLocation(
+	ctx context.Context,
+	plantId int,
+) (int, plants.Location, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var householdId, locationId int
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+SELECT COALESCE(household_id, 0), COALESCE(location_id, 0)
+FROM plant
+WHERE id=?;`,
+		plantId,
+	).Scan(&householdId, &locationId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, plants.Location{}, errPlantNotFound
+	}
+	if err != nil {
+		return 0, plants.Location{}, sqliteError(err)
+	}
+	if locationId == 0 {
+		return householdId, plants.Location{}, nil
+	}
+
+	row := db.db.QueryRowContext(ctx, locationsQuery("l.id=?"), locationId)
+	l, err := scanLocation(row)
+	if err != nil {
+		return 0, plants.Location{}, sqliteError(err)
+	}
+	return householdId, l, nil
+}
+
+// MovePlant moves the plant of identifier plantId to the location of
+// identifier locationId, or out of any location if it is 0, and logs the move
+// with an entry of description desc that occurred at occurredAt. The location
+// must belong to the household of the plant. Returns the identifier of the
+// entry.
+func (db *SQLiteDatabase) MovePlant(
+	ctx context.Context,
+	plantId, locationId int,
+	desc string,
+	occurredAt time.Time,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	var householdId int
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(household_id, 0) FROM plant WHERE id=?;",
+		plantId,
+	).Scan(&householdId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errPlantNotFound
+	}
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	if locationId != 0 {
+		var found bool
+		err = tx.QueryRowContext(
+			ctx,
+			`
+SELECT EXISTS (SELECT 1 FROM location WHERE id=? AND household_id=?);`,
+			locationId,
+			householdId,
+		).Scan(&found)
+		if err != nil {
+			return 0, sqliteError(err)
+		}
+		if !found {
+			return 0, errLocationNotFound
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE plant SET location_id=NULLIF(?2, 0) WHERE id=?1;",
+		plantId,
+		locationId,
+	)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	var logId int
+	err = tx.QueryRowContext(
+		ctx,
+		`
+INSERT INTO plant_log
+(plant_id, description, event_type, occurred_at, recorded_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id;`,
+		plantId,
+		desc,
+		int(plants.EventMove),
+		occurredAt.UTC(),
+		time.Now().UTC(),
+	).Scan(&logId)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return logId, sqliteError(tx.Commit())
+}
+
+// AddPlantTags adds the tags to the plant of identifier plantId, creating the
+// tags that do not exist yet. The tags the plant already has are left as is.
+func (db *SQLiteDatabase) AddPlantTags(
+	ctx context.Context,
+	plantId int,
+	tags []string,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM plant WHERE id=?);",
+		plantId,
+	).Scan(&exists)
+	if err != nil {
+		return sqliteError(err)
+	}
+	if !exists {
+		return errPlantNotFound
+	}
+	for _, tag := range tags {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO tag (name) VALUES (?) ON CONFLICT (name) DO NOTHING;",
+			tag,
+		)
+		if err != nil {
+			return sqliteError(err)
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`
+INSERT INTO plant_tag (plant_id, tag_id)
+SELECT ?1, id FROM tag WHERE name = ?2
+ON CONFLICT DO NOTHING;`,
+			plantId,
+			tag,
+		)
+		if err != nil {
+			return sqliteError(err)
+		}
+	}
+	return sqliteError(tx.Commit())
+}
+
+// RemovePlantTag removes the tag from the plant of identifier plantId. The tag
+// is deleted once no plant has it.
+func (db *SQLiteDatabase) RemovePlantTag(
+	ctx context.Context,
+	plantId int,
+	tag string,
+) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`
+DELETE FROM plant_tag
+WHERE plant_id = ?1 AND tag_id = (SELECT id FROM tag WHERE name = ?2);`,
+		plantId,
+		tag,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	err = checkAffected(res, errTagNotFound)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`
+DELETE FROM tag
+WHERE name = ? AND NOT EXISTS (SELECT 1 FROM plant_tag WHERE tag_id = tag.id);`,
+		tag,
+	)
+	if err != nil {
+		return sqliteError(err)
+	}
+	return sqliteError(tx.Commit())
+}
+
+// GetPlantTags queries the database for the tags of the plant of given
+// identifier, in alphabetical order.
+func (db *SQLiteDatabase) GetPlantTags(
+	ctx context.Context,
+	plantId int,
+) ([]string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(ctx, plantTags("?")+";", plantId)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		err = rows.Scan(&tag)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, sqliteError(rows.Err())
+}
+
+// GetTagCounts queries the database for the tags of the plants of the
+// households of the user of identifier userId, or of all the plants if it is 0,
+// with the number of plants having each of them, in alphabetical order.
+func (db *SQLiteDatabase) GetTagCounts(
+	ctx context.Context,
+	userId int,
+) ([]plants.TagCount, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(ctx, tagCountsQuery("?1"), userId)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	counts := []plants.TagCount{}
+	for rows.Next() {
+		var c plants.TagCount
+		err = rows.Scan(&c.Name, &c.Count)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, sqliteError(rows.Err())
+}
+
+// AddTaxon inserts the taxon t, unless a taxon of the same name exists, and
+// returns its identifier. The family of an existing taxon is only set if it
+// was empty.
+func (db *SQLiteDatabase) AddTaxon(
+	ctx context.Context,
+	t plants.Taxon,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := db.db.QueryRowContext(
+		ctx,
+		`
+INSERT INTO taxon (family, genus, species, infraspecific_rank,
+                   infraspecific_name, cultivar)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (genus, species, infraspecific_rank, infraspecific_name, cultivar)
+DO UPDATE SET family = CASE
+       WHEN taxon.family = '' THEN excluded.family
+       ELSE taxon.family
+END
+RETURNING id;`,
+		t.Family,
+		t.Genus,
+		t.Species,
+		t.Rank,
+		t.Infraspecies,
+		t.Cultivar,
+	).Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return id, nil
+}
+
+// GetTaxon queries the database for the taxon of given identifier.
+func (db *SQLiteDatabase) GetTaxon(
+	ctx context.Context,
+	id int,
+) (plants.Taxon, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.db.QueryRowContext(
+		ctx,
+		"SELECT "+taxonColumns+" FROM taxon t WHERE t.id=?;",
+		id,
+	)
+	t, err := scanTaxon(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plants.Taxon{}, errTaxonNotFound
+	}
+	if err != nil {
+		return plants.Taxon{}, sqliteError(err)
+	}
+	return t, nil
+}
+
+// GetTaxa queries the database for the taxa selected by q, in alphabetical
+// order of their names.
+func (db *SQLiteDatabase) GetTaxa(
+	ctx context.Context,
+	q TaxonQuery,
+) ([]plants.Taxon, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query, args := taxaQuery(q, func(n int) string {
+		return "?" + strconv.Itoa(n)
+	})
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	taxa := []plants.Taxon{}
+	for rows.Next() {
+		t, err := scanTaxon(rows)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		taxa = append(taxa, t)
+	}
+	return taxa, sqliteError(rows.Err())
+}
+
+// AddTaxa inserts the taxa, in a single transaction, and returns the number of
+// taxa inserted. Like AddTaxon, the taxa whose name is already in the database
+// are not inserted again, and only their empty family is set.
+func (db *SQLiteDatabase) AddTaxa(
+	ctx context.Context,
+	taxa []plants.Taxon,
+) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, t := range taxa {
+		res, err := tx.ExecContext(
+			ctx,
+			`
+INSERT INTO taxon (family, genus, species, infraspecific_rank,
+                   infraspecific_name, cultivar)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+ON CONFLICT DO NOTHING;`,
+			t.Family,
+			t.Genus,
+			t.Species,
+			t.Rank,
+			t.Infraspecies,
+			t.Cultivar,
+		)
+		if err != nil {
+			return 0, sqliteError(err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, sqliteError(err)
+		}
+		added += int(n)
+		if n > 0 || t.Family == "" {
+			continue
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`
+UPDATE taxon SET family=?1
+WHERE family='' AND genus=?2 AND species=?3 AND infraspecific_rank=?4
+      AND infraspecific_name=?5 AND cultivar=?6;`,
+			t.Family,
+			t.Genus,
+			t.Species,
+			t.Rank,
+			t.Infraspecies,
+			t.Cultivar,
+		)
+		if err != nil {
+			return 0, sqliteError(err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return added, nil
+}
+
+// GetTaxonNames queries the database for the genera of the taxa, or the species
+// of the taxa of genus if it is not empty, starting with prefix, after their
+// hybrid marker if any, and ignoring case, in alphabetical order. At most limit
+// names are returned, all of them if it is not positive.
+func (db *SQLiteDatabase) GetTaxonNames(
+	ctx context.Context,
+	genus, prefix string,
+	limit int,
+) ([]string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query, args := taxonNamesQuery(genus, prefix, limit, func(n int) string {
+		return "?" + strconv.Itoa(n)
+	})
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		names = append(names, name)
+	}
+	return names, sqliteError(rows.Err())
+}
+
+// Scans a row selecting taxonColumns.
+func scanTaxon(
+	row interface{ Scan(dest ...any) error },
+) (plants.Taxon, error) {
+	var t plants.Taxon
+	err := row.Scan(
+		&t.Id,
+		&t.Family,
+		&t.Genus,
+		&t.Species,
+		&t.Rank,
+		&t.Infraspecies,
+		&t.Cultivar,
+	)
+	return t, err
+}
+
+// GetPlants queries the database for the plants of the households of the user
+// of identifier userId, or for all the plants if it is 0, with their taxon,
+// household, location and tags, but without their log entries, ordered by
+// identifier.
+func (db *SQLiteDatabase) GetPlants(
+	ctx context.Context,
+	userId int,
+) ([]plants.Plant, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		allPlantsQuery(
+			"?1",
+			"COALESCE((SELECT group_concat(name, ',') FROM ("+
+				plantTags("p.id")+")), '')",
+		),
+		userId,
+	)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	ps := []plants.Plant{}
+	for rows.Next() {
+		var p plants.Plant
+		var t plants.Taxon
+		var tags string
+		err = rows.Scan(
+			&p.Id,
+			&p.CommonName,
+			&t.Id,
+			&t.Family,
+			&t.Genus,
+			&t.Species,
+			&t.Rank,
+			&t.Infraspecies,
+			&t.Cultivar,
+			&p.HouseholdId,
+			&p.LocationId,
+			&p.LocationPath,
+			&tags,
+		)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		if t.Id != 0 {
+			p.Taxon = &t
+			p.GenericName, p.SpecificName = t.Genus, t.Species
+		}
+		// Tags cannot contain commas, see plants.ParseTag
+		if tags != "" {
+			p.Tags = strings.Split(tags, ",")
+		}
+		ps = append(ps, p)
+	}
+	return ps, sqliteError(rows.Err())
+}
+
+// GetLogs queries the database for the log entries of the plants of the
+// households of the user of identifier userId, or of all the plants if it is 0,
+// ordered by plant, then in chronological order.
+func (db *SQLiteDatabase) GetLogs(
+	ctx context.Context,
+	userId int,
+) ([]plants.PlantLog, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.db.QueryContext(ctx, allLogsQuery("?1"), userId)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	logs := []plants.PlantLog{}
+	for rows.Next() {
+		var l plants.PlantLog
+		err = rows.Scan(
+			&l.Id,
+			&l.PlantId,
+			&l.Desc,
+			&l.EventType,
+			&l.OccurredAt,
+			&l.RecordedAt,
+		)
+		if err != nil {
+			return nil, sqliteError(err)
+		}
+		logs = append(logs, l)
+	}
+	return logs, sqliteError(rows.Err())
+}
+
+// AddPlants inserts the plants ps, owned by the household of identifier
+// householdId, or by no one if it is 0, in a single transaction, and returns
+// their identifiers. Each plant has its common name, its location if
+// LocationId is not 0, its tags, and its taxon if Taxon is not nil, which is
+// added like AddTaxon does. Either all the plants are inserted, or none.
+func (db *SQLiteDatabase) AddPlants(
+	ctx context.Context,
+	householdId int,
+	ps []plants.Plant,
+) ([]int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int, 0, len(ps))
+	for _, p := range ps {
+		id, err := insertPlant(ctx, tx, householdId, p)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return ids, nil
+}
+
+// Inserts the plant p, owned by the household of identifier householdId, in tx
+// with its taxon and tags, see AddPlants, and returns its identifier.
+func insertPlant(
+	ctx context.Context,
+	tx *sql.Tx,
+	householdId int,
+	p plants.Plant,
+) (int, error) {
+	taxonId := 0
+	if t := p.Taxon; t != nil {
+		err := tx.QueryRowContext(
+			ctx,
+			`
+INSERT INTO taxon (family, genus, species, infraspecific_rank,
+                   infraspecific_name, cultivar)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (genus, species, infraspecific_rank, infraspecific_name, cultivar)
+DO UPDATE SET family = CASE
+       WHEN taxon.family = '' THEN excluded.family
+       ELSE taxon.family
+END
+RETURNING id;`,
+			t.Family,
+			t.Genus,
+			t.Species,
+			t.Rank,
+			t.Infraspecies,
+			t.Cultivar,
+		).Scan(&taxonId)
+		if err != nil {
+			return 0, sqliteError(err)
+		}
+	}
+
+	var id int
+	err := tx.QueryRowContext(
+		ctx,
+		`
+INSERT INTO plant (common_name, taxon_id, created_at, household_id,
+                   location_id)
+VALUES (?, NULLIF(?, 0), ?, NULLIF(?, 0), NULLIF(?, 0))
+RETURNING id;`,
+		p.CommonName,
+		taxonId,
+		time.Now().UTC().Format(sqliteTimeLayout),
+		householdId,
+		p.LocationId,
+	).Scan(&id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	for _, tag := range p.Tags {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO tag (name) VALUES (?) ON CONFLICT (name) DO NOTHING;",
+			tag,
+		)
+		if err != nil {
+			return 0, sqliteError(err)
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`
+INSERT INTO plant_tag (plant_id, tag_id)
+SELECT ?1, id FROM tag WHERE name = ?2
+ON CONFLICT DO NOTHING;`,
+			id,
+			tag,
+		)
+		if err != nil {
+			return 0, sqliteError(err)
+		}
+	}
+	return id, nil
+}
+
+// MigrateUp applies the pending migrations.
+func (db *SQLiteDatabase) MigrateUp(ctx context.Context) (int, error) {
+	return migrateUp(ctx, db, "sqlite")
+}
+
+// MigrateDown reverts the last applied migration.
+func (db *SQLiteDatabase) MigrateDown(ctx context.Context) (bool, error) {
+	return migrateDown(ctx, db, "sqlite")
+}
+
+// MigrationStatus returns the status of all the known migrations.
+func (db *SQLiteDatabase) MigrationStatus(
+	ctx context.Context,
+) ([]MigrationStatus, error) {
+	return migrationStatus(ctx, db, "sqlite")
+}
+
+func (db *SQLiteDatabase) createMigrationsTable(ctx context.Context) error {
+	_, err := db.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+       version INTEGER PRIMARY KEY,
+       name VARCHAR(255) NOT NULL,
+       applied_at TIMESTAMP NOT NULL
+);`)
+	return err
+}
+
+func (db *SQLiteDatabase) appliedMigrations(
+	ctx context.Context,
+) (map[int]time.Time, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (db *SQLiteDatabase) runMigration(
+	ctx context.Context,
+	m migration,
+	up bool,
+) ([]string, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"CREATE TEMP TABLE migration_note (message TEXT NOT NULL);",
+	)
+	if err != nil {
+		return nil, err
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, m.up)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`
+INSERT INTO schema_migrations (version, name, applied_at)
+VALUES (?, ?, ?);`,
+			m.version,
+			m.name,
+			time.Now().UTC(),
+		)
+	} else {
+		_, err = tx.ExecContext(ctx, m.down)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			"DELETE FROM schema_migrations WHERE version=?;",
+			m.version,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT message FROM migration_note;")
+	if err != nil {
+		return nil, err
+	}
+	var notes []string
+	for rows.Next() {
+		var note string
+		err = rows.Scan(&note)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "DROP TABLE temp.migration_note;")
+	if err != nil {
+		return nil, err
+	}
+	return notes, tx.Commit()
+}
+
+// Returns a copy of ctx cancelled after the query timeout of db.
+func (db *SQLiteDatabase) withTimeout(
+	ctx context.Context,
+) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, db.QueryTimeout)
+}
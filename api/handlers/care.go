@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var maxDueDays = 366
+
+// Returns a handler for the "/plants/schedules/{id}/" URL.
+// The request method should be GET or POST. If it is GET, sends back the care
+// schedules of the plant of given identifier as a json encoded array of
+// plants.CareSchedule. If it is POST, adds a care schedule to the plant from
+// the form values, see parseCareSchedule, and sends back its identifier. If an
+// error occurs while communicating with the database, sends an error response,
+// see writeServerError.
+func CareSchedulesHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			schedules, err := db.GetCareSchedules(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(schedules)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			s := plants.CareSchedule{PlantId: id}
+			err = parseCareSchedule(r.PostForm, &s, true)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			scheduleId, err := db.AddCareSchedule(r.Context(), s)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, strconv.Itoa(scheduleId))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/schedules/{id}/{scheduleId}/" URL.
+// The request method should be GET, PUT, PATCH or DELETE. The care schedule of
+// identifier scheduleId must belong to the plant of identifier id. If the method
+// is GET, sends back the schedule as json encoded data. If it is PUT, replaces
+// the schedule by the form values, see parseCareSchedule. If it is PATCH, only
+// replaces the values present in the form. If it is DELETE, deletes the
+// schedule. PUT, PATCH and DELETE send back an empty response with
+// http.StatusNoContent on success.
+func CareScheduleHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scheduleId, err := strconv.Atoi(r.PathValue("scheduleId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			s, err := db.GetCareSchedule(r.Context(), id, scheduleId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(s)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPut, http.MethodPatch:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			s := plants.CareSchedule{Id: scheduleId, PlantId: id}
+			if r.Method == http.MethodPatch {
+				s, err = db.GetCareSchedule(r.Context(), id, scheduleId)
+				if err != nil {
+					writeServerError(w, err)
+					return
+				}
+			}
+			err = parseCareSchedule(r.PostForm, &s, r.Method == http.MethodPut)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			err = db.UpdateCareSchedule(r.Context(), s)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			err = db.DeleteCareSchedule(r.Context(), id, scheduleId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/tasks/{id}/" URL.
+// The request method should be GET. Sends back the next occurrence of each care
+// schedule of the plant of given identifier, as a json encoded array of
+// plants.CareTask ordered by due date.
+func PlantTasksHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		tasks, err := db.GetCareTasks(r.Context(), 0, id, time.Now())
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		sortCareTasks(tasks)
+		err = json.NewEncoder(w).Encode(tasks)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Returns a handler for the "/plants/tasks/{id}/{scheduleId}/" URL.
+// The request method should be POST. Completes the next occurrence of the care
+// schedule of identifier scheduleId, which must belong to the plant of
+// identifier id, by adding an entry of the event type of the schedule to the
+// log of the plant. The entry is described by the "entry" form value and
+// occurred at the time given by the "occurred-at" form value, or now if it is
+// empty. See parseOccurredAt for the accepted formats.
+func CompleteTaskHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scheduleId, err := strconv.Atoi(r.PathValue("scheduleId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		occurredAt, err := parseOccurredAt(r.PostForm.Get("occurred-at"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		s, err := db.GetCareSchedule(r.Context(), id, scheduleId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		_, err = db.AddNewPlantLog(
+			r.Context(),
+			id,
+			r.PostForm.Get("entry"),
+			s.EventType,
+			occurredAt,
+		)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Returns a handler for the "/tasks/" URL.
+// The request method should be GET. Sends back the care tasks of all the plants
+// that are due today or overdue, as a json encoded array of plants.CareTask
+// ordered by due date. The "days" query parameter, at most 366, also selects
+// the tasks due in the given number of days.
+func TasksHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		days := 0
+		if s := r.URL.Query().Get("days"); s != "" {
+			var err error
+			days, err = strconv.Atoi(s)
+			if err != nil || days < 0 || days > maxDueDays {
+				writeError(
+					w,
+					http.StatusBadRequest,
+					"Days must be between 0 and 366",
+				)
+				return
+			}
+		}
+
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		now := time.Now()
+		tasks, err := db.GetCareTasks(r.Context(), userId, 0, now)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		// Tasks due before the end of the last selected day
+		year, month, day := now.Date()
+		end := time.Date(year, month, day+days+1, 0, 0, 0, 0, now.Location())
+		tasks = slices.DeleteFunc(tasks, func(t plants.CareTask) bool {
+			return !t.DueAt.Before(end)
+		})
+		sortCareTasks(tasks)
+		err = json.NewEncoder(w).Encode(tasks)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Parses the form values describing a care schedule into s:
+//   - "event-type": the name of the event type of the care
+//   - "interval-days": the number of days between two cares
+//   - "season-start" and "season-end": the months, from 1 to 12, of the first
+//     and last months of the season of the care, January and December by
+//     default
+//
+// If all is false, only the values present in the form are parsed, otherwise
+// the event type and interval are required.
+func parseCareSchedule(form url.Values, s *plants.CareSchedule, all bool) error {
+	if all || form.Has("event-type") {
+		name := strings.TrimSpace(form.Get("event-type"))
+		if name == "" {
+			return errors.New("Event type is required")
+		}
+		event, err := plants.ParseEventType(name)
+		if err != nil {
+			return err
+		}
+		s.EventType = event
+	}
+	if all || form.Has("interval-days") {
+		days, err := strconv.Atoi(strings.TrimSpace(form.Get("interval-days")))
+		if err != nil || days < 1 || days > maxDueDays {
+			return errors.New("Interval must be between 1 and 366 days")
+		}
+		s.IntervalDays = days
+	}
+	months := []struct {
+		field string
+		month *time.Month
+		def   time.Month
+	}{
+		{"season-start", &s.SeasonStart, time.January},
+		{"season-end", &s.SeasonEnd, time.December},
+	}
+	for _, m := range months {
+		v := strings.TrimSpace(form.Get(m.field))
+		if v == "" {
+			if all {
+				*m.month = m.def
+			}
+			continue
+		}
+		month, err := strconv.Atoi(v)
+		if err != nil || month < 1 || month > 12 {
+			return errors.New("Season months must be between 1 and 12")
+		}
+		*m.month = time.Month(month)
+	}
+	return nil
+}
+
+// Sorts tasks by due date, then by plant and schedule.
+func sortCareTasks(tasks []plants.CareTask) {
+	slices.SortStableFunc(tasks, func(a, b plants.CareTask) int {
+		if c := a.DueAt.Compare(b.DueAt); c != 0 {
+			return c
+		}
+		if a.PlantId != b.PlantId {
+			return a.PlantId - b.PlantId
+		}
+		return a.ScheduleId - b.ScheduleId
+	})
+}
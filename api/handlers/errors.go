@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/mgmu/hortus/api/database"
+	"log"
+	"net/http"
+)
+
+// Body of the error responses of the API.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// Sends an error response with given status code, whose body is msg encoded as
+// an errorBody.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{msg})
+}
+
+// Sends the error response matching err. Database errors of a known kind are
+// sent with their message and status code: http.StatusNotFound,
+// http.StatusUnprocessableEntity or http.StatusConflict. Other errors are
+// logged and sent as an internal server error, without their message.
+func writeServerError(w http.ResponseWriter, err error) {
+	var dbErr *database.Error
+	if errors.As(err, &dbErr) {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, database.ErrConstraint):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, database.ErrConflict):
+			status = http.StatusConflict
+		}
+		writeError(w, status, dbErr.Msg)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusServiceUnavailable, "Database timeout")
+		return
+	}
+	log.Printf("handlers: %v", err)
+	writeError(
+		w,
+		http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError),
+	)
+}
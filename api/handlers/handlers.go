@@ -5,43 +5,70 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/api/storage"
 	"github.com/mgmu/hortus/internal/plants"
 	"net/http"
 	"strconv"
 	"strings"
-	"unicode"
+	"time"
 	"unicode/utf8"
 )
 
 var (
-	notAllowed = "Method not allowed"
-	nameMaxLen = 255
+	notAllowed          = "Method not allowed"
+	nameMaxLen          = 255
+	datetimeLocalLayout = "2006-01-02T15:04"
+	searchMaxLen        = 255
+	defaultSearchSize   = 20
 )
 
 // Returns a handler for the "/plants/" URL.
-// The request method should be GET. If the request method is GET, sets the
-// content length, returns 200 status code and the body of the response contains
-// list of plant name/identifier couples, separated by a comma, one couple per
-// line and each line ends with a new line character. If an error occurs while
-// communicating with the database, sends an "Internal Server Error" with the
-// appropriate status code and error message.
+// The request method should be GET. Sends back a page of the list of plants as
+// a json encoded plants.PlantPage, the page being selected by the query
+// parameters of the URL, see parsePlantQuery. When there are more plants, the
+// URL of the next page is also sent in a "Link" header. If an error occurs while
+// communicating with the database, sends an error response, see
+// writeServerError.
 func PlantsListHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
 			return
 		}
 
-		plants, err := db.GetPlantsShortDescription()
+		q, err := parsePlantQuery(r.URL.Query())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		q.UserId, err = requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Fetch one more plant to know if there is a next page
+		limit := q.Limit
+		q.Limit++
+		descs, err := db.GetPlantsShortDescription(r.Context(), q)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		page := plants.PlantPage{Plants: descs}
+		if len(descs) > limit {
+			page.Plants = descs[:limit]
+			last := page.Plants[limit-1]
+			page.Next = nextPageUrl(r.URL, q, last)
+			w.Header().Set("Link", "<"+page.Next+">; rel=\"next\"")
+		}
 
+		w.Header().Set("Content-Type", "application/json")
 		enc := json.NewEncoder(w)
-		err = enc.Encode(plants)
+		err = enc.Encode(page)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeServerError(w, err)
 			return
 		}
 	}
@@ -50,120 +77,475 @@ func PlantsListHandler(db database.Database) func(http.ResponseWriter, *http.Req
 // Returns a handler for the "/plants/new" URL.
 // The request method should be POST. If it is not, sets the status code to
 // http.StatusMethodNotAllowed and sends an error response. If an error is
-// encountered when calling ParseForm or inserting the new plant , sends a
-// "Bad Request" error back. Otherwise, the new plant is inserted and its
-// identifier is sent back in the body in its textual form.
+// encountered when calling ParseForm or validating the names, sends a
+// "Bad Request" error back. If inserting the new plant fails, sends the error
+// response matching the error, see writeServerError. Otherwise, the new plant is
+// inserted and its identifier is sent back in the body in its textual form.
+// The plant belongs to the household of identifier given by the "household-id"
+// form value, or to the first household in which the user of the request can
+// add plants, see plantHousehold. Viewers cannot add plants. The taxon of the
+// plant is given by the "taxon-id", or "generic-name" and "specific-name", form
+// values, see plantTaxon.
 func NewPlantHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
 			return
 		}
 		err := r.ParseForm()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
 		// Validate input
 		comm, err := sanitizeCommonName(r.PostForm.Get("common-name"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		gen, err := sanitizeScientificName(r.PostForm.Get("generic-name"))
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		taxonId, ok := plantTaxon(w, r, db, plants.Taxon{})
+		if !ok {
 			return
 		}
 
-		spe, err := sanitizeScientificName(r.PostForm.Get("specific-name"))
+		userId, err := requestUser(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
-
-		id, err := db.AddNewPlant(comm, gen, spe)
+		householdId, ok := plantHousehold(
+			w,
+			r,
+			db,
+			userId,
+			r.PostForm.Get("household-id"),
+		)
+		if !ok {
+			return
+		}
+		id, err := db.AddNewPlant(r.Context(), householdId, comm, taxonId)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeServerError(w, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Header().Set("Content-Length", strconv.Itoa(len(strconv.Itoa(id))))
-		fmt.Fprintf(w, strconv.Itoa(id))
+		fmt.Fprint(w, strconv.Itoa(id))
 	}
 }
 
 // Returns a handler for the "/plants/{id}" URL.
-// The request method should be GET. If it is not, sets the status code to
-// http.StatusMethodNotAllowed and sends an error response. Queries the database
-// for plant information and sends it back as json encoded data.
-func PlantInfoHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+// The request method should be GET, PUT, PATCH or DELETE. If it is not, sets
+// the status code to http.StatusMethodNotAllowed and sends an error response.
+// If the method is GET, queries the database for plant information and sends it
+// back as json encoded data. If it is PUT, replaces the common name of the
+// plant by the "common-name" form value and its taxon by the one of the
+// "taxon-id", or "generic-name" and "specific-name", form values, see
+// plantTaxon. If it is PATCH, only replaces the names present in the form. If
+// it is DELETE, deletes the plant, all its log entries and its photos, removed
+// from store. PUT, PATCH and DELETE send back an empty response with
+// http.StatusNoContent on success.
+func PlantInfoHandler(
+	db database.Database,
+	store storage.BlobStore,
+) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		// Get the id of the plant from the url
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			getPlant(db, id, w, r)
+		case http.MethodPut, http.MethodPatch:
+			updatePlant(db, id, w, r)
+		case http.MethodDelete:
+			photos, err := db.GetPhotos(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = db.DeletePlant(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			for _, photo := range photos {
+				deleteBlobs(store, photo.Key, photo.ThumbnailKey)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Sends back the plant of given identifier, its location and its logs as json
+// encoded data.
+func getPlant(
+	db database.Database,
+	id int,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	comm, taxon, err := db.GetPlantNames(r.Context(), id)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	householdId, location, err := db.GetPlantLocation(r.Context(), id)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	tags, err := db.GetPlantTags(r.Context(), id)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	plantLogs, err := db.GetPlantLogs(r.Context(), id)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	// Encode the plant as a json object
+	plant := plants.Plant{
+		Id:           id,
+		CommonName:   comm,
+		GenericName:  taxon.Genus,
+		SpecificName: taxon.Species,
+		HouseholdId:  householdId,
+		LocationId:   location.Id,
+		LocationPath: location.Path,
+		Tags:         tags,
+		Logs:         plantLogs,
+	}
+	if taxon.Id != 0 {
+		plant.Taxon = &taxon
+	}
+	err = json.NewEncoder(w).Encode(plant)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+}
+
+// Updates the common name and the taxon of the plant of given identifier from
+// the form of r, see plantTaxon. For PATCH requests, the names missing from the
+// form are left unchanged.
+func updatePlant(
+	db database.Database,
+	id int,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	err := r.ParseForm()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The current taxon is needed by PUT requests too, to keep it along with
+	// its other parts if the names are unchanged
+	comm, taxon, err := db.GetPlantNames(r.Context(), id)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if r.Method == http.MethodPut || r.PostForm.Has("common-name") {
+		comm, err = sanitizeCommonName(r.PostForm.Get("common-name"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	taxonId, ok := plantTaxon(w, r, db, taxon)
+	if !ok {
+		return
+	}
+
+	err = db.UpdatePlant(r.Context(), id, comm, taxonId)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns a handler for the "/plants/log/{id}/" URL.
+// The request method should be POST. Adds a new entry to the log of the plant
+// of given identifier. The entry is described by the "new-entry" form value, is
+// of the type named by the "event-type" form value (a note if empty) and
+// occurred at the time given by the "occurred-at" form value, or now if it is
+// empty. See parseOccurredAt for the accepted formats. Sends back the identifier
+// of the entry.
+func NewPlantLogHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// Get the id of the plant to fetch from the url
 		id, err := strconv.Atoi(r.PathValue("id"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		comm, gen, spe, err := db.GetPlantNames(id)
+		occurredAt, err := parseOccurredAt(r.PostForm.Get("occurred-at"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		plantLogs, err := db.GetPlantLogs(id)
+		event, err := parseEventType(r.PostForm.Get("event-type"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// Encode the plant as a json object
-		plant := plants.Plant{id, comm, gen, spe, plantLogs}
-		err = json.NewEncoder(w).Encode(plant)
+		logId, err := db.AddNewPlantLog(
+			r.Context(),
+			id,
+			r.PostForm.Get("new-entry"),
+			event,
+			occurredAt,
+		)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeServerError(w, err)
 			return
 		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, strconv.Itoa(logId))
 	}
 }
 
-func NewPlantLogHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+// Returns a handler for the "/plants/log/{id}/{logId}/" URL.
+// The request method should be GET, PUT, PATCH or DELETE. If it is not, sets
+// the status code to http.StatusMethodNotAllowed and sends an error response.
+// The log entry of identifier logId must belong to the plant of identifier id.
+// If the method is GET, sends back the log entry as json encoded data. If it is
+// PUT, replaces the entry by the "new-entry", "event-type" and "occurred-at"
+// form values, interpreted as in NewPlantLogHandler. The description may also
+// be sent as "entry", its former name. If it is PATCH, only replaces the values
+// present in the form. If it is DELETE, deletes the entry. PUT, PATCH
+// and DELETE send back an empty response with http.StatusNoContent on success.
+func PlantLogHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logId, err := strconv.Atoi(r.PathValue("logId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		err := r.ParseForm()
+		switch r.Method {
+		case http.MethodGet:
+			plantLog, err := db.GetPlantLog(r.Context(), id, logId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(plantLog)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPut, http.MethodPatch:
+			updatePlantLog(db, id, logId, w, r)
+		case http.MethodDelete:
+			err = db.DeletePlantLog(r.Context(), id, logId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Updates the log entry of identifier logId of the plant of identifier id from
+// the form of r. For PATCH requests, the values missing from the form are left
+// unchanged.
+func updatePlantLog(
+	db database.Database,
+	id, logId int,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	err := r.ParseForm()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var plantLog plants.PlantLog
+	if r.Method == http.MethodPatch {
+		plantLog, err = db.GetPlantLog(r.Context(), id, logId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+	descKey := "new-entry"
+	if !r.PostForm.Has(descKey) {
+		descKey = "entry"
+	}
+	if r.Method == http.MethodPut || r.PostForm.Has(descKey) {
+		plantLog.Desc = r.PostForm.Get(descKey)
+	}
+	if r.Method == http.MethodPut || r.PostForm.Has("event-type") {
+		plantLog.EventType, err = parseEventType(r.PostForm.Get("event-type"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if r.Method == http.MethodPut || r.PostForm.Has("occurred-at") {
+		plantLog.OccurredAt, err = parseOccurredAt(r.PostForm.Get("occurred-at"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+	}
 
-		id, err := strconv.Atoi(r.PathValue("id"))
+	err = db.UpdatePlantLog(
+		r.Context(),
+		id,
+		logId,
+		plantLog.Desc,
+		plantLog.EventType,
+		plantLog.OccurredAt,
+	)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns a handler for the "/events/" URL.
+// The request method should be GET. Sends back the names of all the event types
+// a plant log can have, as a json encoded list.
+func EventTypesHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		err := json.NewEncoder(w).Encode(plants.EventTypes())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeServerError(w, err)
 			return
 		}
+	}
+}
 
-		err = db.AddNewPlantLog(id, r.PostForm.Get("new-entry"), 0)
+// Returns a handler for the "/search/" URL.
+// The request method should be GET. Searches the plants and log entries
+// matching the "q" query parameter, ignoring case and accents, and sends back
+// at most "limit" results, 20 by default, as a json encoded array of
+// plants.SearchResult, the best ranked first. Sends a bad request response if
+// the query is empty. If an error occurs while communicating with the database,
+// sends an error response, see writeServerError.
+func SearchHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if query == "" {
+			writeError(w, http.StatusBadRequest, "Search query is empty")
+			return
+		}
+		if len(query) > searchMaxLen {
+			writeError(w, http.StatusBadRequest, "Search query is too long")
+			return
+		}
+		limit := defaultSearchSize
+		if s := r.URL.Query().Get("limit"); s != "" {
+			var err error
+			limit, err = strconv.Atoi(s)
+			if err != nil || limit < 1 || limit > maxPageSize {
+				writeError(
+					w,
+					http.StatusBadRequest,
+					"Limit must be between 1 and 200",
+				)
+				return
+			}
+		}
+
+		userId, err := requestUser(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		results, err := db.Search(r.Context(), userId, query, limit)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		err = json.NewEncoder(w).Encode(results)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		return
+// Parses the name of the event type of a log entry. An empty string is a note.
+func parseEventType(s string) (plants.EventType, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return plants.EventNote, nil
 	}
+	return plants.ParseEventType(s)
+}
+
+// Parses the time at which a logged event occurred. The time is either in
+// RFC 3339 format or in the format of HTML datetime-local inputs, in which case
+// it is in the local time of the server. An empty string means now.
+func parseOccurredAt(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Now(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.ParseInLocation(datetimeLocalLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("Occurrence time is not valid")
+	}
+	return t, nil
 }
 
 // Checks that name is not empty after trim, not longer than 255
@@ -182,26 +564,3 @@ func sanitizeCommonName(name string) (string, error) {
 	}
 	return s, nil
 }
-
-// Checks that name is not longer than 255 characters after trim and is ascii.
-// The string returned is the trimmed version of name.
-func sanitizeScientificName(name string) (string, error) {
-	s := strings.TrimSpace(name)
-	if len(s) > nameMaxLen {
-		return "", errors.New("Scientific name length is greater than 255")
-	}
-	if !isAscii(s) {
-		return "", errors.New("Specific name is not ASCII")
-	}
-	return s, nil
-}
-
-// Returns true if s contains only ASCII characters.
-func isAscii(s string) bool {
-	for _, r := range s {
-		if r > unicode.MaxASCII {
-			return false
-		}
-	}
-	return true
-}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/api/storage"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Returns a server of the plant handlers backed by a new in-memory database.
+// Requests are made on behalf of no user, so all the plants are reachable.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	db := &database.MemoryDatabase{}
+	err := db.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &storage.FileStore{Dir: t.TempDir()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plants/new/", NewPlantHandler(db))
+	mux.HandleFunc(
+		"/plants/{id}/",
+		PlantAccess(db, PlantInfoHandler(db, store)),
+	)
+	mux.HandleFunc(
+		"/plants/log/{id}/",
+		PlantAccess(db, NewPlantLogHandler(db)),
+	)
+	mux.HandleFunc(
+		"/plants/log/{id}/{logId}/",
+		PlantAccess(db, PlantLogHandler(db)),
+	)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		srv.Close()
+		db.Close()
+	})
+	return srv
+}
+
+// Sends a request of given method with form to srv at path, and returns the
+// status code and the body of the response.
+func send(
+	t *testing.T,
+	srv *httptest.Server,
+	method, path string,
+	form url.Values,
+) (int, string) {
+	t.Helper()
+	return sendHeader(t, srv, method, path, nil, form)
+}
+
+// Same as send, with the headers of header added to the request.
+func sendHeader(
+	t *testing.T,
+	srv *httptest.Server,
+	method, path string,
+	header http.Header,
+	form url.Values,
+) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(
+		method,
+		srv.URL+path,
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// Returns the message of the error response body.
+func errorMessage(t *testing.T, body string) string {
+	t.Helper()
+	var e errorBody
+	err := json.Unmarshal([]byte(body), &e)
+	if err != nil {
+		t.Fatalf("body %q is not an error response: %v", body, err)
+	}
+	return e.Error
+}
+
+func TestNewPlantIds(t *testing.T) {
+	srv := newTestServer(t)
+	for _, want := range []string{"1", "2", "3"} {
+		form := url.Values{"common-name": {"Plant " + want}}
+		status, body := send(t, srv, http.MethodPost, "/plants/new/", form)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", status, http.StatusOK, body)
+		}
+		if body != want {
+			t.Errorf("id = %q, want %q", body, want)
+		}
+	}
+
+	status, body := send(t, srv, http.MethodGet, "/plants/2/", nil)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", status, http.StatusOK, body)
+	}
+	var p plants.Plant
+	err := json.Unmarshal([]byte(body), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Id != 2 || p.CommonName != "Plant 2" {
+		t.Errorf("plant = %d %q, want 2 \"Plant 2\"", p.Id, p.CommonName)
+	}
+}
+
+func TestNewPlantCommonName(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   string
+	}{
+		{"Monstera", http.StatusOK, "Monstera"},
+		{"  Pothos \t", http.StatusOK, "Pothos"},
+		{"", http.StatusBadRequest, "Common name is empty"},
+		{" \t ", http.StatusBadRequest, "Common name is empty"},
+		{
+			strings.Repeat("a", 256),
+			http.StatusBadRequest,
+			"Common name length is greater than 255",
+		},
+		{"\xff", http.StatusBadRequest, "Common name is not UTF-8"},
+	}
+	srv := newTestServer(t)
+	for _, test := range tests {
+		form := url.Values{"common-name": {test.name}}
+		status, body := send(t, srv, http.MethodPost, "/plants/new/", form)
+		if status != test.status {
+			t.Errorf(
+				"%q: status = %d, want %d: %s",
+				test.name,
+				status,
+				test.status,
+				body,
+			)
+			continue
+		}
+		if status != http.StatusOK {
+			if msg := errorMessage(t, body); msg != test.want {
+				t.Errorf("%q: error = %q, want %q", test.name, msg, test.want)
+			}
+			continue
+		}
+
+		status, body = send(t, srv, http.MethodGet, "/plants/"+body+"/", nil)
+		var p plants.Plant
+		err := json.Unmarshal([]byte(body), &p)
+		if status != http.StatusOK || err != nil {
+			t.Fatalf("%q: status = %d, body %s", test.name, status, body)
+		}
+		if p.CommonName != test.want {
+			t.Errorf(
+				"%q: common name = %q, want %q",
+				test.name,
+				p.CommonName,
+				test.want,
+			)
+		}
+	}
+}
+
+func TestUpdatePlantCommonName(t *testing.T) {
+	srv := newTestServer(t)
+	form := url.Values{"common-name": {"Monstera"}}
+	send(t, srv, http.MethodPost, "/plants/new/", form)
+
+	form = url.Values{"common-name": {"  "}}
+	status, body := send(t, srv, http.MethodPut, "/plants/1/", form)
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if msg := errorMessage(t, body); msg != "Common name is empty" {
+		t.Errorf("error = %q, want %q", msg, "Common name is empty")
+	}
+
+	// A PATCH without common name keeps it
+	form = url.Values{"generic-name": {"monstera"}}
+	status, body = send(t, srv, http.MethodPatch, "/plants/1/", form)
+	if status != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d: %s", status, http.StatusNoContent, body)
+	}
+	_, body = send(t, srv, http.MethodGet, "/plants/1/", nil)
+	var p plants.Plant
+	err := json.Unmarshal([]byte(body), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CommonName != "Monstera" || p.GenericName != "Monstera" {
+		t.Errorf(
+			"names = %q %q, want \"Monstera\" \"Monstera\"",
+			p.CommonName,
+			p.GenericName,
+		)
+	}
+}
+
+func TestNewPlantLog(t *testing.T) {
+	srv := newTestServer(t)
+	for _, name := range []string{"Monstera", "Pothos"} {
+		form := url.Values{"common-name": {name}}
+		send(t, srv, http.MethodPost, "/plants/new/", form)
+	}
+
+	// Log identifiers are shared by all the plants
+	logs := []struct {
+		plant string
+		want  string
+	}{
+		{"1", "1"},
+		{"2", "2"},
+		{"1", "3"},
+	}
+	for _, l := range logs {
+		form := url.Values{"new-entry": {"Watered"}, "event-type": {"watering"}}
+		path := "/plants/log/" + l.plant + "/"
+		status, body := send(t, srv, http.MethodPost, path, form)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", status, http.StatusOK, body)
+		}
+		if body != l.want {
+			t.Errorf("log id = %q, want %q", body, l.want)
+		}
+	}
+
+	// A log entry belongs to one plant only
+	status, _ := send(t, srv, http.MethodGet, "/plants/log/1/2/", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", status, http.StatusNotFound)
+	}
+	status, body := send(t, srv, http.MethodGet, "/plants/log/2/2/", nil)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", status, http.StatusOK, body)
+	}
+	var l plants.PlantLog
+	err := json.Unmarshal([]byte(body), &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Id != 2 || l.PlantId != 2 || l.EventType != plants.EventWatering {
+		t.Errorf("log = %+v, want watering entry 2 of plant 2", l)
+	}
+}
+
+func TestNewPlantLogMissingPlant(t *testing.T) {
+	srv := newTestServer(t)
+	form := url.Values{"new-entry": {"Watered"}}
+	status, body := send(t, srv, http.MethodPost, "/plants/log/42/", form)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf(
+			"status = %d, want %d: %s",
+			status,
+			http.StatusUnprocessableEntity,
+			body,
+		)
+	}
+	if msg := errorMessage(t, body); msg != "Plant does not exist" {
+		t.Errorf("error = %q, want %q", msg, "Plant does not exist")
+	}
+
+	// Deleting a plant deletes its log, whose entries are no longer found
+	send(t, srv, http.MethodPost, "/plants/new/", url.Values{
+		"common-name": {"Monstera"},
+	})
+	send(t, srv, http.MethodPost, "/plants/log/1/", form)
+	status, _ = send(t, srv, http.MethodDelete, "/plants/1/", nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", status, http.StatusNoContent)
+	}
+	status, _ = send(t, srv, http.MethodGet, "/plants/log/1/1/", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestUpdatePlantLogEntry(t *testing.T) {
+	srv := newTestServer(t)
+	send(t, srv, http.MethodPost, "/plants/new/", url.Values{
+		"common-name": {"Monstera"},
+	})
+	send(t, srv, http.MethodPost, "/plants/log/1/", url.Values{
+		"new-entry": {"Watered"},
+	})
+
+	// The description has the key of creation, or its former one
+	updates := []struct {
+		method string
+		form   url.Values
+		want   string
+	}{
+		{http.MethodPut, url.Values{"new-entry": {"Repotted"}}, "Repotted"},
+		{http.MethodPatch, url.Values{"entry": {"Pruned"}}, "Pruned"},
+		{
+			http.MethodPatch,
+			url.Values{"new-entry": {"Fed"}, "entry": {"Pruned"}},
+			"Fed",
+		},
+		{http.MethodPatch, url.Values{"event-type": {"watering"}}, "Fed"},
+	}
+	for _, u := range updates {
+		status, body := send(t, srv, u.method, "/plants/log/1/1/", u.form)
+		if status != http.StatusNoContent {
+			t.Fatalf(
+				"%v: status = %d, want %d: %s",
+				u.form,
+				status,
+				http.StatusNoContent,
+				body,
+			)
+		}
+		_, body = send(t, srv, http.MethodGet, "/plants/log/1/1/", nil)
+		var l plants.PlantLog
+		err := json.Unmarshal([]byte(body), &l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Desc != u.want {
+			t.Errorf("%v: description = %q, want %q", u.form, l.Desc, u.want)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	defaultInvitationDays = 7
+	maxInvitationDays     = 30
+	notOwner              = "Only the owners of the household can do this"
+)
+
+// Invitation sent back once when it is created, with the secret of its link.
+type createdInvitation struct {
+	plants.Invitation
+	Secret string `json:"token"`
+}
+
+// Returns a handler calling h if the user of the request, see requestUser, is
+// a member of the household owning the plant of identifier given by the "id"
+// path value. GET and HEAD requests are allowed to all the members, the other
+// requests only to the owners and caretakers, viewers get
+// http.StatusForbidden. If the user is not a member, sends back
+// http.StatusNotFound, so that the plants of the other households cannot be
+// discovered. Requests made on behalf of no user may access all the plants.
+func PlantAccess(
+	db database.Database,
+	h func(http.ResponseWriter, *http.Request),
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if userId == 0 {
+			h(w, r)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		role, err := db.GetPlantRole(r.Context(), userId, id)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if !role.CanEdit() &&
+			r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(
+				w,
+				http.StatusForbidden,
+				"Viewers cannot modify the plants of the household",
+			)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// Returns a handler for the "/households/" URL.
+// The request method should be GET or POST. If it is GET, sends back the
+// households of the user of the request, see requestUser, as a json encoded
+// array of plants.Household with the role of the user. If it is POST, creates a
+// household named by the "name" form value, of which the user is the owner, and
+// sends back its identifier. The requests must be made on behalf of a user.
+func HouseholdsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if userId == 0 {
+			writeError(
+				w,
+				http.StatusBadRequest,
+				"Households are managed on behalf of a user",
+			)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			households, err := db.GetHouseholds(r.Context(), userId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(households)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			name, err := sanitizeHouseholdName(r.PostForm.Get("name"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			id, err := db.AddHousehold(r.Context(), userId, name)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, strconv.Itoa(id))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/households/{id}/" URL.
+// The request method should be GET, PUT, PATCH or DELETE. If it is GET, sends
+// back the household of given identifier and its members as a json encoded
+// plants.Household, with the role of the user of the request. If it is PUT or
+// PATCH, renames the household after the "name" form value. If it is DELETE,
+// deletes the household, which must have no plants left. Only the owners of the
+// household can rename and delete it, and the household is not found by the
+// users who are not members.
+func HouseholdHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		role, ok := householdRole(w, r, db, id)
+		if !ok {
+			return
+		}
+		if r.Method != http.MethodGet && role != plants.RoleOwner {
+			writeError(w, http.StatusForbidden, notOwner)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			h, err := db.GetHousehold(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			h.Role = role
+			err = json.NewEncoder(w).Encode(h)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPut, http.MethodPatch:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			name, err := sanitizeHouseholdName(r.PostForm.Get("name"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			err = db.UpdateHousehold(r.Context(), id, name)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			err = db.DeleteHousehold(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/households/{id}/members/{userId}/" URL.
+// The request method should be PUT, PATCH or DELETE. If it is PUT or PATCH,
+// gives the member of identifier userId the role of the "role" form value:
+// "owner", "caretaker" or "viewer". If it is DELETE, removes the member from
+// the household. Only the owners can change the members, but any member can
+// leave the household. A household always keeps an owner.
+func MemberHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		memberId, err := strconv.Atoi(r.PathValue("userId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		role, ok := householdRole(w, r, db, id)
+		if !ok {
+			return
+		}
+		leaving := r.Method == http.MethodDelete && memberId == userId
+		if role != plants.RoleOwner && !leaving {
+			writeError(w, http.StatusForbidden, notOwner)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut, http.MethodPatch:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			newRole, err := plants.ParseRole(
+				strings.TrimSpace(r.PostForm.Get("role")),
+			)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			err = db.SetMemberRole(r.Context(), id, memberId, newRole)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			err = db.DeleteMember(r.Context(), id, memberId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/households/{id}/invitations/" URL.
+// The request method should be GET or POST. If it is GET, sends back the
+// pending invitations to the household of given identifier as a json encoded
+// array of plants.Invitation. If it is POST, creates an invitation to join the
+// household with the role of the "role" form value, valid for the number of
+// days of the "days" form value, 7 by default and at most 30. Sends back the
+// invitation as a json encoded plants.Invitation with the secret of its link in
+// the "token" field, which cannot be retrieved afterwards, see JoinHandler.
+// Only the owners of the household can manage its invitations.
+func InvitationsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		role, ok := householdRole(w, r, db, id)
+		if !ok {
+			return
+		}
+		if role != plants.RoleOwner {
+			writeError(w, http.StatusForbidden, notOwner)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			invitations, err := db.GetInvitations(r.Context(), id, time.Now())
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(invitations)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			inv := plants.Invitation{HouseholdId: id}
+			inv.Role, err = plants.ParseRole(
+				strings.TrimSpace(r.PostForm.Get("role")),
+			)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			days := defaultInvitationDays
+			if s := strings.TrimSpace(r.PostForm.Get("days")); s != "" {
+				days, err = strconv.Atoi(s)
+				if err != nil || days < 1 || days > maxInvitationDays {
+					writeError(
+						w,
+						http.StatusBadRequest,
+						"Days must be between 1 and 30",
+					)
+					return
+				}
+			}
+
+			secret, err := newSecret()
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			inv.CreatedAt = time.Now()
+			inv.ExpiresAt = inv.CreatedAt.AddDate(0, 0, days)
+			inv.Id, err = db.AddInvitation(r.Context(), inv, HashToken(secret))
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			h, err := db.GetHousehold(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			inv.HouseholdName = h.Name
+			err = json.NewEncoder(w).Encode(createdInvitation{inv, secret})
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/households/{id}/invitations/{invitationId}/" URL.
+// The request method should be DELETE. Deletes the pending invitation of
+// identifier invitationId to the household of identifier id, so that its link
+// can no longer be used. Only the owners of the household can delete its
+// invitations.
+func InvitationHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		invitationId, err := strconv.Atoi(r.PathValue("invitationId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		role, ok := householdRole(w, r, db, id)
+		if !ok {
+			return
+		}
+		if role != plants.RoleOwner {
+			writeError(w, http.StatusForbidden, notOwner)
+			return
+		}
+
+		err = db.DeleteInvitation(r.Context(), id, invitationId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Returns a handler for the "/invitations/{token}/" URL.
+// The request method should be GET or POST. If it is GET, sends back the
+// invitation whose link has the secret token as a json encoded
+// plants.Invitation. If it is POST, the user of the request, see requestUser,
+// joins the household of the invitation with its role, and the invitation can
+// no longer be used. Invitations that are expired or already used are not
+// found.
+func JoinHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		now := time.Now()
+		hash := HashToken(r.PathValue("token"))
+		inv, err := db.GetInvitationByHash(r.Context(), hash, now)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if r.Method == http.MethodGet {
+			err = json.NewEncoder(w).Encode(inv)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			return
+		}
+
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if userId == 0 {
+			writeError(
+				w,
+				http.StatusBadRequest,
+				"Invitations are accepted on behalf of a user",
+			)
+			return
+		}
+		err = db.AcceptInvitation(r.Context(), inv.Id, userId, now)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Returns the role of the user of the request, see requestUser, in the
+// household of given identifier. If the user is not a member, sends back
+// http.StatusNotFound and returns false. Requests made on behalf of no user
+// have the role of an owner in all the households that exist.
+func householdRole(
+	w http.ResponseWriter,
+	r *http.Request,
+	db database.Database,
+	id int,
+) (plants.Role, bool) {
+	userId, err := requestUser(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	if userId == 0 {
+		_, err = db.GetHousehold(r.Context(), id)
+		if err != nil {
+			writeServerError(w, err)
+			return "", false
+		}
+		return plants.RoleOwner, true
+	}
+
+	role, err := db.GetMemberRole(r.Context(), id, userId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Household not found")
+		return "", false
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return "", false
+	}
+	return role, true
+}
+
+// Returns the household in which the user of identifier userId adds a plant:
+// the household of identifier given by s, or the first household in which the
+// user can add plants if s is empty. The user must be an owner or a caretaker
+// of the household. Requests made on behalf of no user add plants to the
+// household given by s, or to no household if it is empty. If it fails, sends
+// an error response and returns false.
+func plantHousehold(
+	w http.ResponseWriter,
+	r *http.Request,
+	db database.Database,
+	userId int,
+	s string,
+) (int, bool) {
+	id := 0
+	if s = strings.TrimSpace(s); s != "" {
+		var err error
+		id, err = strconv.Atoi(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Household is not valid")
+			return 0, false
+		}
+	}
+	if userId == 0 {
+		return id, true
+	}
+
+	if id != 0 {
+		role, ok := householdRole(w, r, db, id)
+		if !ok {
+			return 0, false
+		}
+		if !role.CanEdit() {
+			writeError(
+				w,
+				http.StatusForbidden,
+				"Viewers cannot add plants to the household",
+			)
+			return 0, false
+		}
+		return id, true
+	}
+	households, err := db.GetHouseholds(r.Context(), userId)
+	if err != nil {
+		writeServerError(w, err)
+		return 0, false
+	}
+	for _, h := range households {
+		if h.Role.CanEdit() {
+			return h.Id, true
+		}
+	}
+	writeError(
+		w,
+		http.StatusForbidden,
+		"No household in which the user can add plants",
+	)
+	return 0, false
+}
+
+// Checks that the name is not empty after trim and not longer than 255
+// characters. The string returned is the trimmed name.
+func sanitizeHouseholdName(name string) (string, error) {
+	s := strings.TrimSpace(name)
+	if s == "" {
+		return "", errors.New("Household name is empty")
+	}
+	if !utf8.ValidString(s) {
+		return "", errors.New("Household name is not valid utf8")
+	}
+	if utf8.RuneCountInString(s) > nameMaxLen {
+		return "", errors.New("Household name length is greater than 255")
+	}
+	return s, nil
+}
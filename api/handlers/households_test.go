@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// Request made with the token of given name, and its expected response: the
+// status code, and the error message if any.
+type accessTest struct {
+	token  string
+	method string
+	path   string
+	form   url.Values
+	status int
+	want   string
+}
+
+// Runs the tests in order against each of the servers of newAuthServers.
+func runAccessTests(t *testing.T, tests []accessTest) {
+	t.Helper()
+	for backend, srv := range newAuthServers(t) {
+		for _, test := range tests {
+			status, body := srv.send(
+				t,
+				test.token,
+				test.method,
+				test.path,
+				test.form,
+			)
+			if status != test.status {
+				t.Errorf(
+					"%s, %s %s %s: status = %d, want %d: %s",
+					backend,
+					test.token,
+					test.method,
+					test.path,
+					status,
+					test.status,
+					body,
+				)
+				continue
+			}
+			if test.want == "" {
+				continue
+			}
+			if msg := errorMessage(t, body); msg != test.want {
+				t.Errorf(
+					"%s, %s %s %s: error = %q, want %q",
+					backend,
+					test.token,
+					test.method,
+					test.path,
+					msg,
+					test.want,
+				)
+			}
+		}
+	}
+}
+
+func TestPlantAccess(t *testing.T) {
+	rename := url.Values{"common-name": {"Monstera deliciosa"}}
+	viewers := "Viewers cannot modify the plants of the household"
+	runAccessTests(t, []accessTest{
+		{"alice", http.MethodGet, "/plants/1/", nil, 200, ""},
+		{"carol", http.MethodGet, "/plants/1/", nil, 200, ""},
+		{"victor", http.MethodGet, "/plants/1/", nil, 200, ""},
+		{"alice", http.MethodPatch, "/plants/1/", rename, 204, ""},
+		{"carol", http.MethodPatch, "/plants/1/", rename, 204, ""},
+		{"victor", http.MethodPatch, "/plants/1/", rename, 403, viewers},
+		{"victor", http.MethodDelete, "/plants/1/", nil, 403, viewers},
+		// The plants of the other households are not found
+		{"bob", http.MethodGet, "/plants/1/", nil, 404, "Plant not found"},
+		{"bob", http.MethodPatch, "/plants/1/", rename, 404, "Plant not found"},
+		{"alice", http.MethodGet, "/plants/2/", nil, 404, "Plant not found"},
+		{"alice", http.MethodGet, "/plants/3/", nil, 404, "Plant not found"},
+		// Services reach all the plants
+		{"service", http.MethodGet, "/plants/1/", nil, 200, ""},
+		{"service", http.MethodPatch, "/plants/2/", rename, 204, ""},
+	})
+}
+
+func TestHouseholdRole(t *testing.T) {
+	rename := url.Values{"name": {"Potager"}}
+	runAccessTests(t, []accessTest{
+		{"alice", http.MethodGet, "/households/1/", nil, 200, ""},
+		{"victor", http.MethodGet, "/households/1/", nil, 200, ""},
+		{"alice", http.MethodPatch, "/households/1/", rename, 204, ""},
+		{"carol", http.MethodPatch, "/households/1/", rename, 403, notOwner},
+		{"victor", http.MethodDelete, "/households/1/", nil, 403, notOwner},
+		// The households of which the user is not a member are not found
+		{
+			"bob",
+			http.MethodGet,
+			"/households/1/",
+			nil,
+			404,
+			"Household not found",
+		},
+		{
+			"bob",
+			http.MethodPatch,
+			"/households/1/",
+			rename,
+			404,
+			"Household not found",
+		},
+		{
+			"alice",
+			http.MethodGet,
+			"/households/9/",
+			nil,
+			404,
+			"Household not found",
+		},
+		// Services are owners of all the households that exist
+		{"service", http.MethodPatch, "/households/4/", rename, 204, ""},
+		{
+			"service",
+			http.MethodGet,
+			"/households/9/",
+			nil,
+			404,
+			"Household not found",
+		},
+	})
+}
+
+func TestMemberHandler(t *testing.T) {
+	owner := url.Values{"role": {"owner"}}
+	viewer := url.Values{"role": {"viewer"}}
+	lastOwner := "Household must keep an owner"
+	runAccessTests(t, []accessTest{
+		// Only the owners change the other members
+		{
+			"carol",
+			http.MethodPatch,
+			"/households/1/members/3/",
+			owner,
+			403,
+			notOwner,
+		},
+		{
+			"victor",
+			http.MethodDelete,
+			"/households/1/members/2/",
+			nil,
+			403,
+			notOwner,
+		},
+		{
+			"bob",
+			http.MethodDelete,
+			"/households/1/members/3/",
+			nil,
+			404,
+			"Household not found",
+		},
+		{
+			"alice",
+			http.MethodDelete,
+			"/households/1/members/9/",
+			nil,
+			404,
+			"Member not found",
+		},
+		// The sole owner can neither leave nor be demoted
+		{
+			"alice",
+			http.MethodPatch,
+			"/households/1/members/1/",
+			viewer,
+			409,
+			lastOwner,
+		},
+		{
+			"alice",
+			http.MethodDelete,
+			"/households/1/members/1/",
+			nil,
+			409,
+			lastOwner,
+		},
+		{
+			"service",
+			http.MethodDelete,
+			"/households/1/members/1/",
+			nil,
+			409,
+			lastOwner,
+		},
+		// Once there is another owner, it can
+		{
+			"alice",
+			http.MethodPatch,
+			"/households/1/members/2/",
+			owner,
+			204,
+			"",
+		},
+		{"alice", http.MethodDelete, "/households/1/members/1/", nil, 204, ""},
+		{
+			"alice",
+			http.MethodGet,
+			"/households/1/",
+			nil,
+			404,
+			"Household not found",
+		},
+		// Any member can leave
+		{"victor", http.MethodDelete, "/households/1/members/3/", nil, 204, ""},
+		{
+			"carol",
+			http.MethodDelete,
+			"/households/1/members/2/",
+			nil,
+			409,
+			lastOwner,
+		},
+	})
+}
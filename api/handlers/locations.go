@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Returns a handler for the "/locations/" URL.
+// The request method should be GET or POST. If it is GET, sends back the
+// locations of the households of the user of the request, see requestUser, as
+// a json encoded array of plants.Location ordered by household and path.
+// Requests made on behalf of no user get all the locations. If it is POST,
+// creates a location of the kind and name given by the "kind" and "name" form
+// values, and sends back its identifier. Sites are added to the household of
+// identifier given by the "household-id" form value, or to the first household
+// in which the user can add plants, see plantHousehold. The other locations are
+// added to the household of their parent location, of identifier given by the
+// "parent-id" form value. Viewers cannot add locations.
+func LocationsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			locations, err := db.GetLocations(r.Context(), userId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(locations)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			var l plants.Location
+			l.Kind, err = plants.ParseLocationKind(
+				strings.TrimSpace(r.PostForm.Get("kind")),
+			)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			l.Name, err = sanitizeLocationName(r.PostForm.Get("name"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			if l.Kind.IsTop() {
+				var ok bool
+				l.HouseholdId, ok = plantHousehold(
+					w,
+					r,
+					db,
+					userId,
+					r.PostForm.Get("household-id"),
+				)
+				if !ok {
+					return
+				}
+				if l.HouseholdId == 0 {
+					writeError(
+						w,
+						http.StatusBadRequest,
+						"Household is required",
+					)
+					return
+				}
+			} else {
+				parentId, err := parseParentLocation(r.PostForm.Get("parent-id"))
+				if err != nil {
+					writeError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				parent, ok := locationAccess(w, r, db, parentId)
+				if !ok {
+					return
+				}
+				l.HouseholdId = parent.HouseholdId
+				if !checkParentLocation(w, l, parent) {
+					return
+				}
+				l.ParentId = parent.Id
+			}
+
+			id, err := db.AddLocation(r.Context(), l)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, strconv.Itoa(id))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/locations/{id}/" URL.
+// The request method should be GET, PUT, PATCH or DELETE. If it is GET, sends
+// back the location of given identifier as a json encoded plants.Location. If
+// it is PUT, replaces the name and the parent location of the location by the
+// "name" and "parent-id" form values. If it is PATCH, only replaces the values
+// present in the form. The kind and the household of a location cannot be
+// changed. If it is DELETE, deletes the location, which must contain neither
+// plants nor other locations. Only the members of the household of the location
+// find it, and viewers cannot modify it.
+func LocationHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		l, ok := locationAccess(w, r, db, id)
+		if !ok {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			err = json.NewEncoder(w).Encode(l)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPut, http.MethodPatch:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			put := r.Method == http.MethodPut
+			if put || r.PostForm.Has("name") {
+				l.Name, err = sanitizeLocationName(r.PostForm.Get("name"))
+				if err != nil {
+					writeError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+			}
+			if !l.Kind.IsTop() && (put || r.PostForm.Has("parent-id")) {
+				parentId, err := parseParentLocation(r.PostForm.Get("parent-id"))
+				if err != nil {
+					writeError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				parent, ok := locationAccess(w, r, db, parentId)
+				if !ok {
+					return
+				}
+				if !checkParentLocation(w, l, parent) {
+					return
+				}
+				l.ParentId = parent.Id
+			}
+
+			err = db.UpdateLocation(r.Context(), l)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			err = db.DeleteLocation(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/move/{id}/" URL.
+// The request method should be POST. Moves the plant of given identifier to
+// the location of identifier given by the "location-id" form value, or out of
+// any location if it is empty, and logs the move with an entry of the "move"
+// event type. The entry is described by the "entry" form value, or by the path
+// of the location if it is empty, and occurred at the time given by the
+// "occurred-at" form value, see parseOccurredAt. The location must belong to
+// the household of the plant. Sends back the identifier of the entry.
+func MovePlantHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		occurredAt, err := parseOccurredAt(r.PostForm.Get("occurred-at"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		desc := strings.TrimSpace(r.PostForm.Get("entry"))
+		locationId := 0
+		if s := strings.TrimSpace(r.PostForm.Get("location-id")); s != "" {
+			locationId, err = strconv.Atoi(s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Location is not valid")
+				return
+			}
+			l, err := db.GetLocation(r.Context(), locationId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			if desc == "" {
+				desc = l.Path
+			}
+		}
+
+		logId, err := db.MovePlant(
+			r.Context(),
+			id,
+			locationId,
+			desc,
+			occurredAt,
+		)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, strconv.Itoa(logId))
+	}
+}
+
+// Returns the location of given identifier if the user of the request, see
+// requestUser, is a member of its household. GET and HEAD requests are allowed
+// to all the members, the other requests only to the owners and caretakers,
+// viewers get http.StatusForbidden. If the user is not a member, sends back
+// http.StatusNotFound and returns false. Requests made on behalf of no user may
+// access all the locations.
+func locationAccess(
+	w http.ResponseWriter,
+	r *http.Request,
+	db database.Database,
+	id int,
+) (plants.Location, bool) {
+	userId, err := requestUser(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return plants.Location{}, false
+	}
+	l, err := db.GetLocation(r.Context(), id)
+	if err != nil {
+		writeServerError(w, err)
+		return plants.Location{}, false
+	}
+	if userId == 0 {
+		return l, true
+	}
+
+	role, err := db.GetMemberRole(r.Context(), l.HouseholdId, userId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Location not found")
+		return plants.Location{}, false
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return plants.Location{}, false
+	}
+	if !role.CanEdit() &&
+		r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(
+			w,
+			http.StatusForbidden,
+			"Viewers cannot modify the locations of the household",
+		)
+		return plants.Location{}, false
+	}
+	return l, true
+}
+
+// Checks that the location l can be in the location parent: they belong to the
+// same household and the kind of parent can contain the kind of l. Otherwise
+// sends back http.StatusBadRequest and returns false.
+func checkParentLocation(
+	w http.ResponseWriter,
+	l plants.Location,
+	parent plants.Location,
+) bool {
+	if parent.HouseholdId != l.HouseholdId {
+		writeError(
+			w,
+			http.StatusBadRequest,
+			"Parent location is not in the household",
+		)
+		return false
+	}
+	if !parent.Kind.CanContain(l.Kind) {
+		writeError(
+			w,
+			http.StatusBadRequest,
+			fmt.Sprintf("A %s cannot be in a %s", l.Kind, parent.Kind),
+		)
+		return false
+	}
+	return true
+}
+
+// Parses the identifier of the parent location of a location other than a
+// site, which is required.
+func parseParentLocation(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("Parent location is required")
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("Parent location is not valid")
+	}
+	return id, nil
+}
+
+// Checks that the name is not empty after trim and not longer than 255
+// characters. The string returned is the trimmed name.
+func sanitizeLocationName(name string) (string, error) {
+	s := strings.TrimSpace(name)
+	if s == "" {
+		return "", errors.New("Location name is empty")
+	}
+	if !utf8.ValidString(s) {
+		return "", errors.New("Location name is not valid utf8")
+	}
+	if utf8.RuneCountInString(s) > nameMaxLen {
+		return "", errors.New("Location name length is greater than 255")
+	}
+	return s, nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestLocationAccess(t *testing.T) {
+	rename := url.Values{"name": {"Serre"}}
+	viewers := "Viewers cannot modify the locations of the household"
+	runAccessTests(t, []accessTest{
+		{"alice", http.MethodGet, "/locations/1/", nil, 200, ""},
+		{"victor", http.MethodGet, "/locations/1/", nil, 200, ""},
+		{"carol", http.MethodPatch, "/locations/1/", rename, 204, ""},
+		{"victor", http.MethodPatch, "/locations/1/", rename, 403, viewers},
+		{"victor", http.MethodDelete, "/locations/1/", nil, 403, viewers},
+		// The locations of the other households are not found
+		{
+			"bob",
+			http.MethodGet,
+			"/locations/1/",
+			nil,
+			404,
+			"Location not found",
+		},
+		{
+			"bob",
+			http.MethodPatch,
+			"/locations/1/",
+			rename,
+			404,
+			"Location not found",
+		},
+		{
+			"alice",
+			http.MethodGet,
+			"/locations/3/",
+			nil,
+			404,
+			"Location not found",
+		},
+		// Services reach all the locations
+		{"service", http.MethodGet, "/locations/2/", nil, 200, ""},
+	})
+}
+
+func TestMovePlantAccess(t *testing.T) {
+	runAccessTests(t, []accessTest{
+		{
+			"carol",
+			http.MethodPost,
+			"/plants/move/1/",
+			url.Values{"location-id": {"1"}},
+			200,
+			"",
+		},
+		{
+			"victor",
+			http.MethodPost,
+			"/plants/move/1/",
+			url.Values{"location-id": {"1"}},
+			403,
+			"Viewers cannot modify the plants of the household",
+		},
+		// Plants only move within their household
+		{
+			"alice",
+			http.MethodPost,
+			"/plants/move/1/",
+			url.Values{"location-id": {"2"}},
+			404,
+			"Location not found",
+		},
+		{
+			"service",
+			http.MethodPost,
+			"/plants/move/2/",
+			url.Values{"location-id": {"1"}},
+			404,
+			"Location not found",
+		},
+		{
+			"bob",
+			http.MethodPost,
+			"/plants/move/1/",
+			url.Values{"location-id": {"2"}},
+			404,
+			"Plant not found",
+		},
+		{"alice", http.MethodPost, "/plants/move/1/", nil, 200, ""},
+	})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	plantSorts      = map[string]database.PlantSort{
+		"id":      database.SortById,
+		"name":    database.SortByName,
+		"created": database.SortByCreation,
+	}
+)
+
+// Position of the last plant of a page, sent to clients in the URL of the next
+// page. The sort and order of the list are part of the cursor so that a cursor
+// is not used on a list sorted differently.
+type plantCursor struct {
+	Sort      database.PlantSort `json:"s"`
+	Desc      bool               `json:"d,omitempty"`
+	Id        int                `json:"i"`
+	Name      string             `json:"n,omitempty"`
+	CreatedAt time.Time          `json:"c,omitzero"`
+}
+
+// Parses the query parameters of a request for a page of the list of plants:
+//   - "limit": the number of plants of the page, 50 by default and at most 200
+//   - "sort": the sort key of the list, "name" (default), ignoring case, "id"
+//     or "created"
+//   - "order": "asc" (default) or "desc"
+//   - "cursor": the position of the last plant of the previous page, as sent
+//     in the URL of the next page
+//   - "generic" and "specific": the generic and specific names of the plants,
+//     ignoring case
+//   - "location": the identifier of the location of the plants, including the
+//     plants of the locations it contains
+//   - "tag": the tags of the plants, repeated or separated by commas, see
+//     parseTags
+//   - "match": "all" (default) to select the plants having all the tags, or
+//     "any" to select the plants having at least one of them
+func parsePlantQuery(values url.Values) (database.PlantQuery, error) {
+	q := database.PlantQuery{Sort: database.SortByName, Limit: defaultPageSize}
+
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return q, errors.New("Limit must be between 1 and 200")
+		}
+		q.Limit = limit
+	}
+	if s := values.Get("sort"); s != "" {
+		sort, ok := plantSorts[s]
+		if !ok {
+			return q, errors.New("Sort must be one of id, name or created")
+		}
+		q.Sort = sort
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, errors.New("Order must be asc or desc")
+	}
+	if s := values.Get("cursor"); s != "" {
+		cursor, err := decodePlantCursor(s)
+		if err != nil || cursor.Sort != q.Sort || cursor.Desc != q.Desc {
+			return q, errors.New("Cursor is not valid")
+		}
+		q.After = &database.PlantCursor{
+			Id:        cursor.Id,
+			Name:      cursor.Name,
+			CreatedAt: cursor.CreatedAt,
+		}
+	}
+	if s := values.Get("location"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			return q, errors.New("Location is not valid")
+		}
+		q.LocationId = id
+	}
+	tags, err := parseTags(values["tag"])
+	if err != nil {
+		return q, err
+	}
+	q.Tags = tags
+	switch values.Get("match") {
+	case "", "all":
+	case "any":
+		q.AnyTag = true
+	default:
+		return q, errors.New("Match must be all or any")
+	}
+	q.GenericName = strings.TrimSpace(values.Get("generic"))
+	q.SpecificName = strings.TrimSpace(values.Get("specific"))
+	return q, nil
+}
+
+// Returns the URL, relative to the API root, of the page following the one
+// requested by u and q, whose last plant is last.
+func nextPageUrl(u *url.URL, q database.PlantQuery, last plants.PlantShortDesc) string {
+	cursor := plantCursor{Sort: q.Sort, Desc: q.Desc, Id: last.Id}
+	switch q.Sort {
+	case database.SortByName:
+		cursor.Name = last.CommonName
+	case database.SortByCreation:
+		cursor.CreatedAt = last.CreatedAt
+	}
+	values := u.Query()
+	values.Set("cursor", encodePlantCursor(cursor))
+	return u.Path + "?" + values.Encode()
+}
+
+// Encodes c as an opaque string that can be used in a URL.
+func encodePlantCursor(c plantCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decodes a cursor encoded by encodePlantCursor.
+func decodePlantCursor(s string) (plantCursor, error) {
+	var c plantCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
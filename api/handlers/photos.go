@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/api/imaging"
+	"github.com/mgmu/hortus/api/storage"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// Maximum size of an uploaded photo, in bytes.
+	maxPhotoSize int64 = 10 << 20
+	// Content types of the accepted photos, as sniffed by
+	// http.DetectContentType.
+	photoTypes         = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+	photoKeyPrefix     = "photos"
+	thumbnailKeyPrefix = "thumbnails"
+	// Size in pixels of the square the thumbnails fit in.
+	thumbnailSize = 320
+)
+
+// Returns a handler for the "/plants/photos/{id}/" URL.
+// The request method should be GET or POST. If it is GET, sends back the
+// metadata of the photos of the plant of given identifier as a json encoded
+// array of plants.Photo. If it is POST, the request is a multipart form whose
+// "photo" file, of at most 10 MiB, is added to the photos of the plant, with
+// the "caption" form value as caption. If the "log-id" form value is set, the
+// photo illustrates the log entry of this identifier. If the "new-entry" form
+// value is set instead, the photo illustrates a new log entry described by it,
+// of type "event-type", which occurred at "occurred-at" or else when the photo
+// was taken, according to its EXIF metadata, or else now. The file must be a
+// JPEG, PNG, GIF or WebP image, as detected from its content. The GPS location
+// is removed from its metadata and a thumbnail is made of it. Sends back the
+// identifier of the photo.
+func PhotosHandler(
+	db database.Database,
+	store storage.BlobStore,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			photos, err := db.GetPhotos(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(photos)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			addPhoto(db, store, id, w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Adds the photo uploaded by r to the plant of identifier id.
+func addPhoto(
+	db database.Database,
+	store storage.BlobStore,
+	id int,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	// Leaves room for the other parts of the form
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoSize+1<<20)
+	err := r.ParseMultipartForm(1 << 20)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, "Photo is too large")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	photo := plants.Photo{PlantId: id, Caption: r.PostFormValue("caption")}
+	if utf8.RuneCountInString(photo.Caption) > nameMaxLen {
+		writeError(w, http.StatusBadRequest, "Caption is too long")
+		return
+	}
+	newEntry := r.PostForm.Has("new-entry")
+	if s := r.PostFormValue("log-id"); s != "" {
+		if newEntry {
+			writeError(
+				w,
+				http.StatusBadRequest,
+				"Log entry and new log entry are exclusive",
+			)
+			return
+		}
+		photo.LogId, err = strconv.Atoi(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// The log entry must belong to the plant
+		_, err = db.GetPlantLog(r.Context(), id, photo.LogId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+	var event plants.EventType
+	var occurredAt time.Time
+	if newEntry {
+		event, err = parseEventType(r.PostFormValue("event-type"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if s := r.PostFormValue("occurred-at"); s != "" {
+			occurredAt, err = parseOccurredAt(s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	}
+
+	file, header, err := r.FormFile("photo")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Photo file is required")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxPhotoSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "Photo is too large")
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if len(data) == 0 {
+		writeError(w, http.StatusBadRequest, "Photo file is empty")
+		return
+	}
+
+	// The type announced by the client is not trusted
+	photo.ContentType = http.DetectContentType(data)
+	if !slices.Contains(photoTypes, photo.ContentType) {
+		writeError(
+			w,
+			http.StatusUnsupportedMediaType,
+			"Photo must be a JPEG, PNG, GIF or WebP image",
+		)
+		return
+	}
+
+	// The location where the photo was taken is never stored, photos whose
+	// metadata cannot be cleaned are refused
+	data, err = imaging.StripGPS(data, photo.ContentType)
+	if err != nil {
+		writeError(
+			w,
+			http.StatusUnsupportedMediaType,
+			"Photo metadata is malformed",
+		)
+		return
+	}
+	thumbnail, err := imaging.Thumbnail(data, photo.ContentType, thumbnailSize)
+	if errors.Is(err, imaging.ErrTooLarge) {
+		writeError(
+			w,
+			http.StatusRequestEntityTooLarge,
+			"Photo dimensions are too large",
+		)
+		return
+	}
+	if err != nil {
+		writeError(
+			w,
+			http.StatusUnsupportedMediaType,
+			"Photo is not a valid image",
+		)
+		return
+	}
+	photo.TakenAt, _ = imaging.CaptureTime(data, photo.ContentType)
+
+	photo.Key, err = putBlob(r.Context(), store, photoKeyPrefix, data)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	photo.Size = int64(len(data))
+	photo.ThumbnailKey, err = putBlob(
+		r.Context(),
+		store,
+		thumbnailKeyPrefix,
+		thumbnail,
+	)
+	if err != nil {
+		deleteBlobs(store, photo.Key)
+		writeServerError(w, err)
+		return
+	}
+
+	if newEntry {
+		// The entry occurred when the photo was taken, unless told otherwise
+		if occurredAt.IsZero() {
+			occurredAt = photo.TakenAt
+		}
+		if occurredAt.IsZero() {
+			occurredAt = time.Now()
+		}
+		photo.LogId, err = db.AddNewPlantLog(
+			r.Context(),
+			id,
+			r.PostFormValue("new-entry"),
+			event,
+			occurredAt,
+		)
+		if err != nil {
+			deleteBlobs(store, photo.Key, photo.ThumbnailKey)
+			writeServerError(w, err)
+			return
+		}
+	}
+
+	photoId, err := db.AddPhoto(r.Context(), photo)
+	if err != nil {
+		if newEntry {
+			err := db.DeletePlantLog(context.Background(), id, photo.LogId)
+			if err != nil {
+				log.Printf("handlers: Deleting log entry: %v", err)
+			}
+		}
+		deleteBlobs(store, photo.Key, photo.ThumbnailKey)
+		writeServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, strconv.Itoa(photoId))
+}
+
+// Stores data under a new key of given prefix and returns the key.
+func putBlob(
+	ctx context.Context,
+	store storage.BlobStore,
+	prefix string,
+	data []byte,
+) (string, error) {
+	key, err := storage.NewKey(prefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = store.Put(ctx, key, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Returns a handler for the "/plants/photos/{id}/{photoId}/" URL.
+// The request method should be GET or DELETE. The photo of identifier photoId
+// must belong to the plant of identifier id. If the method is GET, sends back
+// the image of the photo. If it is DELETE, deletes the photo and sends back an
+// empty response with http.StatusNoContent.
+func PhotoHandler(
+	db database.Database,
+	store storage.BlobStore,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		photoId, err := strconv.Atoi(r.PathValue("photoId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			photo, err := db.GetPhoto(r.Context(), id, photoId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			servePhoto(store, photo.Key, photo.ContentType, w, r)
+		case http.MethodDelete:
+			photo, err := db.GetPhoto(r.Context(), id, photoId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = db.DeletePhoto(r.Context(), id, photoId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			deleteBlobs(store, photo.Key, photo.ThumbnailKey)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/photos/{id}/{photoId}/thumbnail/" URL.
+// The request method should be GET. The photo of identifier photoId must belong
+// to the plant of identifier id. Sends back the thumbnail of the photo, a JPEG
+// image, or the photo itself if it has no thumbnail.
+func ThumbnailHandler(
+	db database.Database,
+	store storage.BlobStore,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		photoId, err := strconv.Atoi(r.PathValue("photoId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		photo, err := db.GetPhoto(r.Context(), id, photoId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		// Photos added before thumbnails were made have none
+		if photo.ThumbnailKey == "" {
+			servePhoto(store, photo.Key, photo.ContentType, w, r)
+			return
+		}
+		servePhoto(store, photo.ThumbnailKey, imaging.ThumbnailType, w, r)
+	}
+}
+
+// Sends the image stored under key, of given content type. The images of a key
+// never change, so clients may cache them.
+func servePhoto(
+	store storage.BlobStore,
+	key string,
+	contentType string,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	blob, err := store.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Photo not found")
+		return
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	_, err = io.Copy(w, blob)
+	if err != nil {
+		log.Printf("handlers: %v", err)
+	}
+}
+
+// Deletes the blobs of given keys, once their metadata is deleted. Empty keys,
+// of missing thumbnails, are skipped. Errors are only logged: the photos are
+// already gone for clients, the blobs are just wasting space.
+func deleteBlobs(store storage.BlobStore, keys ...string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		err := store.Delete(context.Background(), key)
+		if err != nil {
+			log.Printf("handlers: Deleting blob %s: %v", key, err)
+		}
+	}
+}
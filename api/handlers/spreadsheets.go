@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// Maximum size of an imported spreadsheet, in bytes.
+	maxImportSize int64 = 1 << 20
+	// Maximum number of plants of an imported spreadsheet.
+	maxImportRows = 1000
+	// Columns of the spreadsheets of plants sent by ExportPlantsHandler.
+	plantColumns = []string{
+		"id",
+		"common_name",
+		"generic_name",
+		"specific_name",
+		"botanical_name",
+		"family",
+		"household_id",
+		"location",
+		"tags",
+	}
+	// Columns of the spreadsheets of log entries sent by ExportLogsHandler.
+	logColumns = []string{
+		"id",
+		"plant_id",
+		"common_name",
+		"event_type",
+		"occurred_at",
+		"recorded_at",
+		"desc",
+	}
+	// Separators of the columns of the imported spreadsheets, by name.
+	importSeparators = map[string]rune{",": ',', ";": ';', "tab": '\t'}
+	// First characters of the cells that spreadsheet applications may read as
+	// formulas.
+	formulaChars = "=+-@\t\r"
+)
+
+// Fields of the plants read by ImportPlantsHandler, in the order of the form
+// values naming their columns, with the columns of plantColumns they are read
+// from by default.
+var importFields = []struct{ field, column string }{
+	{"common-name", "common_name"},
+	{"generic-name", "generic_name"},
+	{"specific-name", "specific_name"},
+	{"location", "location"},
+	{"tags", "tags"},
+}
+
+// Returns a handler for the "/plants/export/" URL.
+// The request method should be GET. Sends back the plants of the households of
+// the user of the request, see requestUser, as a CSV file whose first line
+// names the columns of plantColumns, one plant by line in the order of their
+// identifiers. The botanical name is the full name of the taxon of the plant,
+// the location its path, and the tags are separated by commas. Cells starting
+// like a formula are escaped, see escapeCell. Requests made on behalf of no
+// user export all the plants.
+func ExportPlantsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ps, err := db.GetPlants(r.Context(), userId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		records := [][]string{plantColumns}
+		for _, p := range ps {
+			var name, family, household string
+			if p.Taxon != nil {
+				name, family = p.Taxon.Name(), p.Taxon.Family
+			}
+			if p.HouseholdId != 0 {
+				household = strconv.Itoa(p.HouseholdId)
+			}
+			records = append(records, []string{
+				strconv.Itoa(p.Id),
+				p.CommonName,
+				p.GenericName,
+				p.SpecificName,
+				name,
+				family,
+				household,
+				p.LocationPath,
+				strings.Join(p.Tags, ", "),
+			})
+		}
+		writeCSV(w, "plants.csv", records)
+	}
+}
+
+// Returns a handler for the "/plants/export/logs/" URL.
+// The request method should be GET. Sends back the log entries of the plants of
+// the households of the user of the request, see requestUser, as a CSV file
+// whose first line names the columns of logColumns, one entry by line ordered
+// by plant, then in chronological order. Times are in RFC 3339 format. Cells
+// starting like a formula are escaped, see escapeCell. Requests made on behalf
+// of no user export the entries of all the plants.
+func ExportLogsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ps, err := db.GetPlants(r.Context(), userId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		logs, err := db.GetLogs(r.Context(), userId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		names := make(map[int]string)
+		for _, p := range ps {
+			names[p.Id] = p.CommonName
+		}
+		records := [][]string{logColumns}
+		for _, l := range logs {
+			records = append(records, []string{
+				strconv.Itoa(l.Id),
+				strconv.Itoa(l.PlantId),
+				names[l.PlantId],
+				l.EventType.String(),
+				l.OccurredAt.Format(time.RFC3339),
+				l.RecordedAt.Format(time.RFC3339),
+				l.Desc,
+			})
+		}
+		writeCSV(w, "logs.csv", records)
+	}
+}
+
+// Sends records back as a CSV file, downloaded under given name. The cells that
+// could be read as formulas are escaped, see escapeCell, as they are written:
+// records, whose first one is shared by all the exports, is left unchanged.
+func writeCSV(w http.ResponseWriter, name string, records [][]string) {
+	var b bytes.Buffer
+	cw := csv.NewWriter(&b)
+	escaped := make([]string, 0, len(records[0]))
+	for _, record := range records {
+		escaped = escaped[:0]
+		for _, cell := range record {
+			escaped = append(escaped, escapeCell(cell))
+		}
+		cw.Write(escaped)
+	}
+	cw.Flush()
+	err := cw.Error()
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set(
+		"Content-Disposition",
+		"attachment; filename=\""+name+"\"",
+	)
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	w.Write(b.Bytes())
+}
+
+// Returns a handler for the "/plants/import/" URL.
+// The request method should be POST. The request is a multipart form whose
+// "file" is a CSV file of at most 1 MiB, whose first line names the columns and
+// whose other lines, at most 1000 of them, are plants added to the household
+// given by the "household-id" form value, see plantHousehold. The columns are
+// separated by the "separator" form value, one of ",", ";" or "tab", or else
+// by the one of them the first line has the most of.
+//
+// The common name, generic name, specific name, location and tags of the plants
+// are read from the columns named by the "common-name-column",
+// "generic-name-column", "specific-name-column", "location-column" and
+// "tags-column" form values, ignoring case, or else from the columns named as
+// in the files of ExportPlantsHandler, see importColumns. Values escaped as in
+// the exported files, see escapeCell, are unescaped. Only the common name is
+// required. Each line is validated as by NewPlantHandler, the location being
+// the path of a location of the household, such as "Maison / Salon", and the
+// tags being separated by commas. Empty lines are skipped.
+//
+// Sends back a json encoded plants.ImportReport. If the "dry-run" form value is
+// true, the lines are only validated. Otherwise, the plants are all added in a
+// single transaction, unless a line is not valid, in which case none is added
+// and the report is sent with http.StatusUnprocessableEntity.
+func ImportPlantsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		// Leaves room for the other parts of the form
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20)
+		err := r.ParseMultipartForm(1 << 20)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		dryRun := false
+		if s := r.PostFormValue("dry-run"); s != "" {
+			dryRun, err = strconv.ParseBool(s)
+			if err != nil {
+				writeError(
+					w,
+					http.StatusBadRequest,
+					"Dry run must be true or false",
+				)
+				return
+			}
+		}
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		householdId, ok := plantHousehold(
+			w,
+			r,
+			db,
+			userId,
+			r.PostFormValue("household-id"),
+		)
+		if !ok {
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "File is required")
+			return
+		}
+		defer file.Close()
+		if header.Size > maxImportSize {
+			writeError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		data, err := io.ReadAll(file)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		data = bytes.TrimPrefix(data, []byte("\ufeff"))
+		if !utf8.Valid(data) {
+			writeError(w, http.StatusBadRequest, "File is not valid utf8")
+			return
+		}
+
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.Comma, err = importSeparator(data, r.PostFormValue("separator"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		names, err := reader.Read()
+		if err == io.EOF {
+			writeError(w, http.StatusBadRequest, "File is empty")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		columns, err := importColumns(names, r.PostForm)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		locations, err := db.GetLocations(r.Context(), userId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		locations = slices.DeleteFunc(locations, func(l plants.Location) bool {
+			return l.HouseholdId != householdId
+		})
+
+		report := plants.ImportReport{
+			DryRun: dryRun,
+			Errors: []plants.ImportError{},
+			Ids:    []int{},
+		}
+		var ps []plants.Plant
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if strings.TrimSpace(strings.Join(record, "")) == "" {
+				continue
+			}
+			report.Rows++
+			if report.Rows > maxImportRows {
+				writeError(
+					w,
+					http.StatusBadRequest,
+					"Too many rows, at most 1000 are allowed",
+				)
+				return
+			}
+
+			line, _ := reader.FieldPos(0)
+			field := func(f string) string {
+				i, ok := columns[f]
+				if !ok || i >= len(record) {
+					return ""
+				}
+				return unescapeCell(record[i])
+			}
+			p, errs := importPlant(field, locations)
+			for _, f := range importFields {
+				err, ok := errs[f.field]
+				if !ok {
+					continue
+				}
+				column := ""
+				if i, ok := columns[f.field]; ok {
+					column = strings.TrimSpace(names[i])
+				}
+				report.Errors = append(report.Errors, plants.ImportError{
+					Row:    line,
+					Column: column,
+					Error:  err.Error(),
+				})
+			}
+			ps = append(ps, p)
+		}
+
+		status := http.StatusOK
+		if len(report.Errors) > 0 && !dryRun {
+			status = http.StatusUnprocessableEntity
+		}
+		if status == http.StatusOK && !dryRun {
+			ids, err := db.AddPlants(r.Context(), householdId, ps)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			report.Ids = ids
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		// The status is sent, the error can only be logged
+		err = json.NewEncoder(w).Encode(report)
+		if err != nil {
+			log.Printf("handlers: %v", err)
+		}
+	}
+}
+
+// Returns cell prefixed by a quote if it starts with a character of
+// formulaChars, so that spreadsheet applications show it as text instead of
+// evaluating it.
+func escapeCell(cell string) string {
+	if cell != "" && strings.ContainsRune(formulaChars, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// Returns cell without the quote added by escapeCell, if it has one.
+func unescapeCell(cell string) string {
+	if len(cell) > 1 &&
+		cell[0] == '\'' &&
+		strings.ContainsRune(formulaChars, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// Returns the separator of the columns of the CSV file data named by s, see
+// importSeparators, or, if s is empty, the one of them the first line of data
+// has the most of, a comma if it has none.
+func importSeparator(data []byte, s string) (rune, error) {
+	if s != "" {
+		sep, ok := importSeparators[s]
+		if !ok {
+			return 0, errors.New("Separator must be one of \",\", \";\" or tab")
+		}
+		return sep, nil
+	}
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	sep, most := ',', 0
+	for _, c := range []rune{',', ';', '\t'} {
+		if n := bytes.Count(line, []byte(string(c))); n > most {
+			sep, most = c, n
+		}
+	}
+	return sep, nil
+}
+
+// Returns the index in names, the first line of an imported CSV file, of the
+// column of each field of importFields, see ImportPlantsHandler. The columns
+// are named by the "<field>-column" values of form, or, if they are empty, by
+// the default columns of importFields. A column named by form must exist, and
+// so must the column of the common names. The other fields are left out if
+// their default column is missing.
+func importColumns(names []string, form url.Values) (map[string]int, error) {
+	columns := make(map[string]int)
+	for _, f := range importFields {
+		name, given := f.column, false
+		if s := strings.TrimSpace(form.Get(f.field + "-column")); s != "" {
+			name, given = s, true
+		}
+		i := slices.IndexFunc(names, func(s string) bool {
+			return strings.EqualFold(strings.TrimSpace(s), name)
+		})
+		if i < 0 {
+			if given || f.field == "common-name" {
+				return nil, fmt.Errorf("Column %q not found", name)
+			}
+			continue
+		}
+		columns[f.field] = i
+	}
+	return columns, nil
+}
+
+// Returns the plant of a line of an imported CSV file, whose values are given
+// by field for each field of importFields, see ImportPlantsHandler, and the
+// errors of its invalid values, by field. The location is looked up in
+// locations by its path, ignoring case.
+func importPlant(
+	field func(f string) string,
+	locations []plants.Location,
+) (plants.Plant, map[string]error) {
+	var p plants.Plant
+	errs := make(map[string]error)
+	var err error
+	p.CommonName, err = sanitizeCommonName(field("common-name"))
+	if err != nil {
+		errs["common-name"] = err
+	}
+
+	gen := strings.TrimSpace(field("generic-name"))
+	spe := strings.TrimSpace(field("specific-name"))
+	if gen != "" || spe != "" {
+		t, err := plants.NormalizeTaxon(plants.Taxon{Genus: gen, Species: spe})
+		if err == nil {
+			p.Taxon = &t
+			p.GenericName, p.SpecificName = t.Genus, t.Species
+		} else if _, genErr := plants.NormalizeTaxon(
+			plants.Taxon{Genus: gen},
+		); genErr == nil {
+			// The genus alone is valid
+			errs["specific-name"] = err
+		} else {
+			errs["generic-name"] = err
+		}
+	}
+
+	if path := strings.TrimSpace(field("location")); path != "" {
+		parts := strings.Split(path, "/")
+		for i, part := range parts {
+			parts[i] = strings.TrimSpace(part)
+		}
+		path = strings.Join(parts, " / ")
+		i := slices.IndexFunc(locations, func(l plants.Location) bool {
+			return strings.EqualFold(l.Path, path)
+		})
+		if i < 0 {
+			errs["location"] = errors.New("Location not found in the household")
+		} else {
+			p.LocationId = locations[i].Id
+			p.LocationPath = locations[i].Path
+		}
+	}
+
+	p.Tags, err = parseTags([]string{field("tags")})
+	if err != nil {
+		errs["tags"] = err
+	}
+	return p, errs
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestEscapeCell(t *testing.T) {
+	tests := []struct{ cell, escaped string }{
+		{"", ""},
+		{"Monstera", "Monstera"},
+		{`=HYPERLINK("http://x.example")`, `'=HYPERLINK("http://x.example")`},
+		{"+33 6", "'+33 6"},
+		{"-2", "'-2"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{"'quoted'", "'quoted'"},
+		{"a=b", "a=b"},
+	}
+	for _, test := range tests {
+		escaped := escapeCell(test.cell)
+		if escaped != test.escaped {
+			t.Errorf(
+				"escapeCell(%q) = %q, want %q",
+				test.cell,
+				escaped,
+				test.escaped,
+			)
+		}
+		if cell := unescapeCell(escaped); cell != test.cell {
+			t.Errorf("unescapeCell(%q) = %q, want %q", escaped, cell, test.cell)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	header := []string{"=id", "common-name"}
+	records := [][]string{header, {"1", "+33 6"}}
+	w := httptest.NewRecorder()
+	writeCSV(w, "plants.csv", records)
+
+	want := "'=id,common-name\n1,'+33 6\n"
+	if body := w.Body.String(); body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+	// The header is shared by the exports, which must not write to it
+	if !slices.Equal(header, []string{"=id", "common-name"}) {
+		t.Errorf("header = %q, escaped in place", header)
+	}
+	if records[1][1] != "+33 6" {
+		t.Errorf("record = %q, escaped in place", records[1])
+	}
+}
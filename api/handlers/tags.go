@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of tags added to a plant, or filtering the plants list, in a
+// single request.
+var maxRequestTags = 20
+
+// Returns a handler for the "/plants/tags/{id}/" URL.
+// The request method should be GET or POST. If it is GET, sends back the tags
+// of the plant of given identifier as a json encoded array of strings, in
+// alphabetical order. If it is POST, adds the tags given by the "tag" form
+// values to the plant, see parseTags, and sends back an empty response with
+// http.StatusNoContent. The tags the plant already has are left as is.
+func PlantTagsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			tags, err := db.GetPlantTags(r.Context(), id)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(tags)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			tags, err := parseTags(r.PostForm["tag"])
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if len(tags) == 0 {
+				writeError(w, http.StatusBadRequest, "Tag is required")
+				return
+			}
+
+			err = db.AddPlantTags(r.Context(), id, tags)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/tags/{id}/{tag}/" URL.
+// The request method should be DELETE. Removes the tag from the plant of given
+// identifier and sends back an empty response with http.StatusNoContent.
+func PlantTagHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		tag, err := plants.ParseTag(r.PathValue("tag"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err = db.RemovePlantTag(r.Context(), id, tag)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Returns a handler for the "/tags/" URL.
+// The request method should be GET. Sends back the tags of the plants of the
+// households of the user of the request, see requestUser, with the number of
+// plants having each of them, as a json encoded array of plants.TagCount in
+// alphabetical order. Requests made on behalf of no user count all the plants.
+func TagsHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		counts, err := db.GetTagCounts(r.Context(), userId)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		err = json.NewEncoder(w).Encode(counts)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Parses the tags of values, each value holding one or more tags separated by
+// commas, see plants.ParseTag. Empty tags are skipped and the tags returned
+// are not repeated. There must be at most 20 of them.
+func parseTags(values []string) ([]string, error) {
+	var tags []string
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			tag, err := plants.ParseTag(s)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if len(tags) > maxRequestTags {
+		return nil, errors.New("Too many tags, at most 20 are allowed")
+	}
+	return tags, nil
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Default number of names sent back by TaxonNamesHandler.
+	defaultTaxonNames = 20
+	// Maximum number of suggestions of a plants.NameCheck.
+	maxNameSuggestions = 5
+	// Error message of an invalid "limit" query value.
+	errLimit = "Limit must be between 1 and 200"
+)
+
+// Returns a handler for the "/taxa/" URL.
+// The request method should be GET or POST. If it is GET, sends back the taxa
+// of the catalog of the "family", "genus" and "species" query values, ignoring
+// case, as a json encoded array of plants.Taxon in alphabetical order of their
+// names. Missing values select all the taxa, and the "limit" query value caps
+// their number, 50 by default and at most 200. If it is POST, adds the taxon of
+// the "family", "genus", "species", "rank", "infraspecies" and "cultivar" form
+// values to the catalog, see taxonFromForm, and sends back its identifier. A
+// taxon of the same name already in the catalog is not added again, its
+// identifier is sent back instead.
+func TaxaHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			values := r.URL.Query()
+			q := database.TaxonQuery{
+				Family:  strings.TrimSpace(values.Get("family")),
+				Genus:   strings.TrimSpace(values.Get("genus")),
+				Species: strings.TrimSpace(values.Get("species")),
+				Limit:   queryLimit(values, defaultPageSize),
+			}
+			if q.Limit == 0 {
+				writeError(w, http.StatusBadRequest, errLimit)
+				return
+			}
+
+			taxa, err := db.GetTaxa(r.Context(), q)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(taxa)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			t, err := taxonFromForm(r.PostForm)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			id, err := db.AddTaxon(r.Context(), t)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set(
+				"Content-Length",
+				strconv.Itoa(len(strconv.Itoa(id))),
+			)
+			fmt.Fprint(w, strconv.Itoa(id))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/taxa/names/" URL.
+// The request method should be GET. Sends back the genera of the catalog
+// starting with the "genus" query value, or, if the request has a "species"
+// query value, the species of the genus of the "genus" query value starting
+// with the "species" query value, ignoring case. The names are sent as a json
+// encoded array of strings in alphabetical order, at most as many as the
+// "limit" query value, 20 by default and at most 200.
+func TaxonNamesHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		values := r.URL.Query()
+		limit := queryLimit(values, defaultTaxonNames)
+		if limit == 0 {
+			writeError(w, http.StatusBadRequest, errLimit)
+			return
+		}
+		genus := strings.TrimSpace(values.Get("genus"))
+		prefix := genus
+		if values.Has("species") {
+			if genus == "" {
+				writeError(w, http.StatusBadRequest, "Genus is required")
+				return
+			}
+			prefix = strings.TrimSpace(values.Get("species"))
+		} else {
+			genus = ""
+		}
+
+		names, err := db.GetTaxonNames(r.Context(), genus, prefix, limit)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		err = json.NewEncoder(w).Encode(names)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Returns a handler for the "/taxa/check/" URL.
+// The request method should be GET. Checks whether the catalog has a taxon of
+// the genus and the species of the "genus" and "species" query values, any
+// taxon of the genus if the species is empty, and sends back the result as a
+// json encoded plants.NameCheck. Unknown names come with the names of the
+// catalog they may be a misspelling of, see plants.SuggestNames, among the
+// names starting with the same letter.
+func CheckTaxonHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		values := r.URL.Query()
+		t, err := plants.NormalizeTaxon(plants.Taxon{
+			Genus:   values.Get("genus"),
+			Species: values.Get("species"),
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		check, err := checkTaxonName(r.Context(), db, t)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		err = json.NewEncoder(w).Encode(check)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Returns a handler for the "/taxa/{id}/" URL.
+// The request method should be GET. Sends back the taxon of given identifier as
+// a json encoded plants.Taxon.
+func TaxonHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		t, err := db.GetTaxon(r.Context(), id)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		err = json.NewEncoder(w).Encode(t)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Returns the taxon of the "family", "genus", "species", "rank",
+// "infraspecies" and "cultivar" values of form, normalized and checked against
+// the rules of botanical nomenclature, see plants.NormalizeTaxon.
+func taxonFromForm(form url.Values) (plants.Taxon, error) {
+	return plants.NormalizeTaxon(plants.Taxon{
+		Family:       form.Get("family"),
+		Genus:        form.Get("genus"),
+		Species:      form.Get("species"),
+		Rank:         plants.InfraRank(form.Get("rank")),
+		Infraspecies: form.Get("infraspecies"),
+		Cultivar:     form.Get("cultivar"),
+	})
+}
+
+// Returns the identifier of the taxon of the plant described by the form of r,
+// 0 for no taxon, current being the taxon the plant has. If the form has a
+// "taxon-id" value, the taxon is the one of the catalog of that identifier, or
+// no taxon if it is empty. Otherwise the taxon is named by the "generic-name"
+// and "specific-name" form values, and is added to the catalog if needed, see
+// database.Database.AddTaxon. There is no taxon if both are empty. For PATCH
+// requests, the names missing from the form are the ones of current, which the
+// plant keeps if both are missing. The plant also keeps current, along with its
+// other parts, if the names are unchanged, without checking them again: taxa
+// named before the rules of nomenclature were enforced stay valid. If it fails,
+// sends an error response and returns false.
+func plantTaxon(
+	w http.ResponseWriter,
+	r *http.Request,
+	db database.Database,
+	current plants.Taxon,
+) (int, bool) {
+	if r.PostForm.Has("taxon-id") {
+		s := strings.TrimSpace(r.PostForm.Get("taxon-id"))
+		if s == "" {
+			return 0, true
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Taxon is not valid")
+			return 0, false
+		}
+		t, err := db.GetTaxon(r.Context(), id)
+		if err != nil {
+			writeServerError(w, err)
+			return 0, false
+		}
+		return t.Id, true
+	}
+
+	patch := r.Method == http.MethodPatch
+	if patch &&
+		!r.PostForm.Has("generic-name") &&
+		!r.PostForm.Has("specific-name") {
+		return current.Id, true
+	}
+	gen, spe := current.Genus, current.Species
+	if !patch || r.PostForm.Has("generic-name") {
+		gen = strings.TrimSpace(r.PostForm.Get("generic-name"))
+	}
+	if !patch || r.PostForm.Has("specific-name") {
+		spe = strings.TrimSpace(r.PostForm.Get("specific-name"))
+	}
+	if gen == "" && spe == "" {
+		return 0, true
+	}
+	if current.Id != 0 && gen == current.Genus && spe == current.Species {
+		return current.Id, true
+	}
+
+	t, err := plants.NormalizeTaxon(plants.Taxon{Genus: gen, Species: spe})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+	if t.Genus == current.Genus && t.Species == current.Species {
+		return current.Id, true
+	}
+	id, err := db.AddTaxon(r.Context(), t)
+	if err != nil {
+		writeServerError(w, err)
+		return 0, false
+	}
+	return id, true
+}
+
+// Returns the "limit" value of values, def if it is missing, or 0 if it is not
+// between 1 and 200.
+func queryLimit(values url.Values, def int) int {
+	s := values.Get("limit")
+	if s == "" {
+		return def
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0
+	}
+	return limit
+}
+
+// Checks whether the catalog has a taxon of the genus and the species of t, see
+// CheckTaxonHandler.
+func checkTaxonName(
+	ctx context.Context,
+	db database.Database,
+	t plants.Taxon,
+) (plants.NameCheck, error) {
+	check := plants.NameCheck{Suggestions: []string{}}
+	known, err := db.GetTaxa(
+		ctx,
+		database.TaxonQuery{Genus: t.Genus, Species: t.Species, Limit: 1},
+	)
+	if err != nil {
+		return check, err
+	}
+	if len(known) > 0 {
+		check.Known = true
+		return check, nil
+	}
+
+	genus, err := db.GetTaxa(ctx, database.TaxonQuery{Genus: t.Genus, Limit: 1})
+	if err != nil {
+		return check, err
+	}
+	if len(genus) > 0 {
+		// Only the species is unknown
+		species, err := db.GetTaxonNames(
+			ctx,
+			t.Genus,
+			firstLetter(t.Species),
+			0,
+		)
+		if err != nil {
+			return check, err
+		}
+		for _, s := range plants.SuggestNames(
+			t.Species,
+			species,
+			maxNameSuggestions,
+		) {
+			check.Suggestions = append(
+				check.Suggestions,
+				genus[0].Genus+" "+s,
+			)
+		}
+		return check, nil
+	}
+
+	genera, err := db.GetTaxonNames(ctx, "", firstLetter(t.Genus), 0)
+	if err != nil {
+		return check, err
+	}
+	for _, g := range plants.SuggestNames(t.Genus, genera, maxNameSuggestions) {
+		// The species is only suggested along with the genera that have it
+		name := g
+		if t.Species != "" {
+			taxa, err := db.GetTaxa(
+				ctx,
+				database.TaxonQuery{Genus: g, Species: t.Species, Limit: 1},
+			)
+			if err != nil {
+				return check, err
+			}
+			if len(taxa) > 0 {
+				name += " " + t.Species
+			}
+		}
+		check.Suggestions = append(check.Suggestions, name)
+	}
+	return check, nil
+}
+
+// Returns the first letter of s, or s if it is empty.
+func firstLetter(s string) string {
+	for _, r := range s {
+		return string(r)
+	}
+	return s
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	tokenPrefix     = "hortus_"
+	tokenNameMaxLen = 64
+	// Value of the "WWW-Authenticate" header of the responses to requests
+	// without a valid token.
+	bearerChallenge = `Bearer realm="hortus"`
+)
+
+// Key of the token of a request in its context.
+type tokenKey struct{}
+
+// Token sent back once when it is issued, with its secret.
+type issuedToken struct {
+	plants.Token
+	Secret string `json:"token"`
+}
+
+// Returns a handler calling h if the request has a valid bearer token in its
+// "Authorization" header, whose scope allows the request: GET and HEAD
+// requests need a read or write token, the other requests a write token.
+// Otherwise sends back http.StatusUnauthorized or http.StatusForbidden.
+//
+// The token is stored in the context of the request given to h. If it is
+// issued to a user, the request is made on behalf of this user whatever its
+// UserHeader header, see requestUser. Only service tokens can make requests on
+// behalf of any user, or of no user.
+func Authenticate(db database.Database, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, secret, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || secret == "" {
+			w.Header().Set("WWW-Authenticate", bearerChallenge)
+			writeError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+
+		t, err := db.GetTokenByHash(r.Context(), HashToken(secret))
+		if errors.Is(err, database.ErrNotFound) {
+			w.Header().Set(
+				"WWW-Authenticate",
+				bearerChallenge+`, error="invalid_token"`,
+			)
+			writeError(w, http.StatusUnauthorized, "Invalid or revoked token")
+			return
+		}
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if t.Scope != plants.ScopeWrite &&
+			r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set(
+				"WWW-Authenticate",
+				bearerChallenge+`, error="insufficient_scope"`,
+			)
+			writeError(w, http.StatusForbidden, "Token scope is read only")
+			return
+		}
+
+		r = r.Clone(context.WithValue(r.Context(), tokenKey{}, t))
+		if t.UserId != 0 {
+			user := strconv.Itoa(t.UserId)
+			if s := r.Header.Get(UserHeader); s != "" && s != user {
+				writeError(
+					w,
+					http.StatusForbidden,
+					"Token cannot make requests on behalf of another user",
+				)
+				return
+			}
+			r.Header.Set(UserHeader, user)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Returns a handler calling h if the request is authenticated by a service
+// token, see Authenticate. Otherwise sends back http.StatusForbidden.
+func ServiceOnly(
+	h func(http.ResponseWriter, *http.Request),
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := r.Context().Value(tokenKey{}).(plants.Token)
+		if !ok || t.UserId != 0 {
+			writeError(
+				w,
+				http.StatusForbidden,
+				"Only services can make this request",
+			)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// Returns a handler for the "/tokens/" URL.
+// The request method should be GET or POST. If it is GET, sends back the tokens
+// of the user of the request, see requestUser, as a json encoded array of
+// plants.Token. Requests made on behalf of no user get all the tokens. If it is
+// POST, issues a token to the user of the request, named by the "name" form
+// value and of the scope given by the "scope" form value, "read" or "write".
+// Sends back the token as a json encoded plants.Token with its secret in the
+// "token" field, which cannot be retrieved afterwards. Service tokens are only
+// issued by the "token" command of the server.
+func TokensHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			tokens, err := db.GetTokens(r.Context(), userId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(tokens)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		case http.MethodPost:
+			if userId == 0 {
+				writeError(
+					w,
+					http.StatusBadRequest,
+					"Tokens must be issued on behalf of a user",
+				)
+				return
+			}
+			err := r.ParseForm()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			t := plants.Token{UserId: userId}
+			t.Name, t.Scope, err = ParseToken(
+				r.PostForm.Get("name"),
+				r.PostForm.Get("scope"),
+			)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			t, secret, err := IssueToken(r.Context(), db, t)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			err = json.NewEncoder(w).Encode(issuedToken{t, secret})
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+		default:
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/tokens/{id}/" URL.
+// The request method should be DELETE. Revokes the token of given identifier,
+// which must belong to the user of the request unless the request is made on
+// behalf of no user, and sends back an empty response with
+// http.StatusNoContent. Revoked tokens are kept, but are no longer accepted.
+func TokenHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err = db.RevokeToken(r.Context(), userId, id)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Checks the name and scope of a token. The name must not be empty after trim
+// nor longer than 64 characters, and the scope must be "read" or "write". The
+// name returned is the trimmed name.
+func ParseToken(name, scope string) (string, plants.Scope, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", errors.New("Token name is empty")
+	}
+	if !utf8.ValidString(name) {
+		return "", "", errors.New("Token name is not valid utf8")
+	}
+	if utf8.RuneCountInString(name) > tokenNameMaxLen {
+		return "", "", errors.New("Token name length is greater than 64")
+	}
+	switch s := plants.Scope(strings.TrimSpace(scope)); s {
+	case plants.ScopeRead, plants.ScopeWrite:
+		return name, s, nil
+	default:
+		return "", "", errors.New("Token scope must be \"read\" or \"write\"")
+	}
+}
+
+// Generates a secret for the token t and stores the token, identified by the
+// hash of the secret. Returns the token as stored and its secret.
+func IssueToken(
+	ctx context.Context,
+	db database.Database,
+	t plants.Token,
+) (plants.Token, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return plants.Token{}, "", err
+	}
+	secret = tokenPrefix + secret
+	t.CreatedAt = time.Now()
+	t.Id, err = db.AddToken(ctx, t, HashToken(secret))
+	if err != nil {
+		return plants.Token{}, "", err
+	}
+	return t, secret, nil
+}
+
+// Returns a random secret of 32 bytes, base64url encoded so that it can be used
+// in an URL.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Returns the hex encoded SHA-256 hash of the secret of a token, under which
+// the token is stored. Secrets are random, so a fast hash is enough.
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
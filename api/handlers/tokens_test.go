@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mgmu/hortus/api/database"
+	"github.com/mgmu/hortus/api/storage"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Server of the handlers that check the authorisation of the requests, all
+// wrapped in Authenticate as they are by the API server.
+type authServer struct {
+	*httptest.Server
+	// Secrets of the tokens, by name
+	tokens map[string]string
+}
+
+// Returns the databases the tests run against, by name: a new MemoryDatabase,
+// and a new SQLiteDatabase in a temporary file, migrated to the latest schema.
+func testDatabases(t *testing.T) map[string]database.Database {
+	t.Helper()
+	ctx := context.Background()
+	mem := &database.MemoryDatabase{}
+	err := mem.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HORTUS_SQLITE_PATH", filepath.Join(t.TempDir(), "hortus.db"))
+	lite := &database.SQLiteDatabase{}
+	err = lite.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lite.Close() })
+	_, err = lite.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]database.Database{"memory": mem, "sqlite": lite}
+}
+
+// Returns an authServer for each of the databases of testDatabases, by name.
+// The databases hold the users alice (1), carol (2), victor (3) and bob (4),
+// each the owner of their own household of the same identifier. carol is a
+// caretaker and victor a viewer of the household of alice, which has the plant
+// 1 and the location 1. The household of bob has the plant 2 and the location
+// 2.
+//
+// Each user has a write token named after them, and alice has a read token
+// "alice-read" and a revoked token "revoked". The service tokens are "service"
+// and "service-read".
+func newAuthServers(t *testing.T) map[string]*authServer {
+	t.Helper()
+	servers := make(map[string]*authServer)
+	for backend, db := range testDatabases(t) {
+		srv := &authServer{tokens: seedUsers(t, db)}
+		store := &storage.FileStore{Dir: t.TempDir()}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/plants/", PlantsListHandler(db))
+		mux.HandleFunc("/plants/new/", NewPlantHandler(db))
+		mux.HandleFunc(
+			"/plants/{id}/",
+			PlantAccess(db, PlantInfoHandler(db, store)),
+		)
+		mux.HandleFunc(
+			"/plants/move/{id}/",
+			PlantAccess(db, MovePlantHandler(db)),
+		)
+		mux.HandleFunc("/users/", ServiceOnly(UsersHandler(db)))
+		mux.HandleFunc("/users/login/", ServiceOnly(LoginHandler(db)))
+		mux.HandleFunc("/tokens/", TokensHandler(db))
+		mux.HandleFunc("/households/{id}/", HouseholdHandler(db))
+		mux.HandleFunc(
+			"/households/{id}/members/{userId}/",
+			MemberHandler(db),
+		)
+		mux.HandleFunc("/locations/{id}/", LocationHandler(db))
+		srv.Server = httptest.NewServer(Authenticate(db, mux))
+		t.Cleanup(srv.Close)
+		servers[backend] = srv
+	}
+	return servers
+}
+
+// Fills db with the users, households, plants and locations described by
+// newAuthServers, and returns the secrets of the tokens by name.
+func seedUsers(t *testing.T, db database.Database) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	ids := func(id int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	tokens := make(map[string]string)
+	issue := func(name string, userId int, scope plants.Scope) int {
+		t.Helper()
+		token, secret, err := IssueToken(ctx, db, plants.Token{
+			UserId: userId,
+			Name:   name,
+			Scope:  scope,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[name] = secret
+		return token.Id
+	}
+	issue("service", 0, plants.ScopeWrite)
+	issue("service-read", 0, plants.ScopeRead)
+
+	users := make(map[string]int)
+	for _, name := range []string{"alice", "carol", "victor", "bob"} {
+		users[name] = ids(db.AddUser(ctx, name, "hash"))
+		issue(name, users[name], plants.ScopeWrite)
+	}
+	issue("alice-read", users["alice"], plants.ScopeRead)
+	revoked := issue("revoked", users["alice"], plants.ScopeWrite)
+	err := db.RevokeToken(ctx, users["alice"], revoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Users get their own household when they are added
+	household := func(name string) int {
+		t.Helper()
+		households, err := db.GetHouseholds(ctx, users[name])
+		if err != nil || len(households) != 1 {
+			t.Fatalf("households of %s = %v, %v", name, households, err)
+		}
+		return households[0].Id
+	}
+	garden := household("alice")
+	flat := household("bob")
+	now := time.Now()
+	for name, role := range map[string]plants.Role{
+		"carol":  plants.RoleCaretaker,
+		"victor": plants.RoleViewer,
+	} {
+		id := ids(db.AddInvitation(
+			ctx,
+			plants.Invitation{
+				HouseholdId: garden,
+				Role:        role,
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(time.Hour),
+			},
+			HashToken(name),
+		))
+		err := db.AcceptInvitation(ctx, id, users[name], now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, household := range []int{garden, flat} {
+		ids(db.AddNewPlant(ctx, household, "Monstera", 0))
+		ids(db.AddLocation(ctx, plants.Location{
+			HouseholdId: household,
+			Name:        "Maison",
+			Kind:        plants.LocationSite,
+		}))
+	}
+	return tokens
+}
+
+// Sends a request authenticated by the token of given name, see send.
+func (srv *authServer) send(
+	t *testing.T,
+	token, method, path string,
+	form url.Values,
+) (int, string) {
+	t.Helper()
+	header := http.Header{"Authorization": {"Bearer " + srv.tokens[token]}}
+	return sendHeader(t, srv.Server, method, path, header, form)
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name string
+		// Authorization header, where a token name stands for its secret
+		scheme, token string
+		user          string
+		method        string
+		status        int
+		want          string
+	}{
+		{"no token", "", "", "", http.MethodGet, 401, "Missing bearer token"},
+		{
+			"basic",
+			"Basic",
+			"alice",
+			"",
+			http.MethodGet,
+			401,
+			"Missing bearer token",
+		},
+		{
+			"empty",
+			"Bearer",
+			"",
+			"",
+			http.MethodGet,
+			401,
+			"Missing bearer token",
+		},
+		{
+			"unknown",
+			"Bearer",
+			"hortus_unknown",
+			"",
+			http.MethodGet,
+			401,
+			"Invalid or revoked token",
+		},
+		{
+			"revoked",
+			"Bearer",
+			"revoked",
+			"",
+			http.MethodGet,
+			401,
+			"Invalid or revoked token",
+		},
+		{"scheme case", "bearer", "alice", "", http.MethodGet, 200, ""},
+		{"read", "Bearer", "alice-read", "", http.MethodGet, 200, ""},
+		// Passes through to the handler, which only allows GET
+		{"read head", "Bearer", "alice-read", "", http.MethodHead, 405, ""},
+		{
+			"read post",
+			"Bearer",
+			"alice-read",
+			"",
+			http.MethodPost,
+			403,
+			"Token scope is read only",
+		},
+		{
+			"service read post",
+			"Bearer",
+			"service-read",
+			"",
+			http.MethodPost,
+			403,
+			"Token scope is read only",
+		},
+		{"own user", "Bearer", "alice", "1", http.MethodGet, 200, ""},
+		{
+			"other user",
+			"Bearer",
+			"alice",
+			"4",
+			http.MethodGet,
+			403,
+			"Token cannot make requests on behalf of another user",
+		},
+		{"service user", "Bearer", "service", "4", http.MethodGet, 200, ""},
+		{
+			"invalid user",
+			"Bearer",
+			"service",
+			"bob",
+			http.MethodGet,
+			400,
+			"User identifier is not valid",
+		},
+		{
+			"zero user",
+			"Bearer",
+			"service",
+			"0",
+			http.MethodGet,
+			400,
+			"User identifier is not valid",
+		},
+	}
+	for backend, srv := range newAuthServers(t) {
+		for _, test := range tests {
+			header := make(http.Header)
+			if test.scheme != "" {
+				secret, ok := srv.tokens[test.token]
+				if !ok {
+					secret = test.token
+				}
+				header.Set("Authorization", test.scheme+" "+secret)
+			}
+			if test.user != "" {
+				header.Set(UserHeader, test.user)
+			}
+			status, body := sendHeader(
+				t,
+				srv.Server,
+				test.method,
+				"/plants/",
+				header,
+				nil,
+			)
+			if status != test.status {
+				t.Errorf(
+					"%s, %s: status = %d, want %d: %s",
+					backend,
+					test.name,
+					status,
+					test.status,
+					body,
+				)
+				continue
+			}
+			if test.want == "" {
+				continue
+			}
+			if msg := errorMessage(t, body); msg != test.want {
+				t.Errorf(
+					"%s, %s: error = %q, want %q",
+					backend,
+					test.name,
+					msg,
+					test.want,
+				)
+			}
+		}
+	}
+}
+
+func TestServiceOnly(t *testing.T) {
+	tests := []struct {
+		token  string
+		path   string
+		status int
+	}{
+		{"service", "/users/", http.StatusOK},
+		{"alice", "/users/", http.StatusForbidden},
+		{"bob", "/users/", http.StatusForbidden},
+		// Logs in the user created by the first request
+		{"service", "/users/login/", http.StatusOK},
+		{"alice", "/users/login/", http.StatusForbidden},
+	}
+	form := url.Values{"username": {"dave"}, "password": {"hortensia"}}
+	for backend, srv := range newAuthServers(t) {
+		for _, test := range tests {
+			status, body := srv.send(
+				t,
+				test.token,
+				http.MethodPost,
+				test.path,
+				form,
+			)
+			if status != test.status {
+				t.Errorf(
+					"%s, %s %s: status = %d, want %d: %s",
+					backend,
+					test.token,
+					test.path,
+					status,
+					test.status,
+					body,
+				)
+				continue
+			}
+			if status == http.StatusOK {
+				continue
+			}
+			msg := errorMessage(t, body)
+			if msg != "Only services can make this request" {
+				t.Errorf(
+					"%s, %s %s: error = %q",
+					backend,
+					test.token,
+					test.path,
+					msg,
+				)
+			}
+		}
+	}
+}
+
+func TestUserHeader(t *testing.T) {
+	tests := []struct {
+		token string
+		user  string
+		want  []int
+	}{
+		{"alice", "", []int{1}},
+		{"victor", "", []int{1}},
+		{"bob", "", []int{2}},
+		{"service", "", []int{1, 2}},
+		{"service", "1", []int{1}},
+		{"service", "4", []int{2}},
+	}
+	for backend, srv := range newAuthServers(t) {
+		for _, test := range tests {
+			header := http.Header{
+				"Authorization": {"Bearer " + srv.tokens[test.token]},
+			}
+			if test.user != "" {
+				header.Set(UserHeader, test.user)
+			}
+			status, body := sendHeader(
+				t,
+				srv.Server,
+				http.MethodGet,
+				"/plants/",
+				header,
+				nil,
+			)
+			var page plants.PlantPage
+			err := json.Unmarshal([]byte(body), &page)
+			if status != http.StatusOK || err != nil {
+				t.Errorf(
+					"%s, %s %q: status = %d, body %s",
+					backend,
+					test.token,
+					test.user,
+					status,
+					body,
+				)
+				continue
+			}
+			got := []int{}
+			for _, p := range page.Plants {
+				got = append(got, p.Id)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf(
+					"%s, %s %q: plants = %v, want %v",
+					backend,
+					test.token,
+					test.user,
+					got,
+					test.want,
+				)
+			}
+		}
+	}
+}
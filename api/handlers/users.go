@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mgmu/hortus/api/database"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	usernameMaxLen = 64
+	passwordMinLen = 8
+	// bcrypt ignores the bytes of a password after the 72nd
+	passwordMaxLen = 72
+	// Header holding the identifier of the user on whose behalf a request is
+	// made, set by the web server.
+	UserHeader = "X-Hortus-User"
+)
+
+// Hash compared to the passwords given for unknown usernames, so that the
+// response time does not tell which usernames exist.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("hortus"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Returns a handler for the "/users/" URL.
+// The request method should be POST. Creates a user account from the "username"
+// and "password" form values, see sanitizeUsername and checkPassword, and sends
+// back its identifier. The password is stored as a bcrypt hash. If the username
+// is already taken, ignoring case, sends back http.StatusConflict.
+func UsersHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		username, err := sanitizeUsername(r.PostForm.Get("username"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		password := r.PostForm.Get("password")
+		err = checkPassword(password)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		hash, err := bcrypt.GenerateFromPassword(
+			[]byte(password),
+			bcrypt.DefaultCost,
+		)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		id, err := db.AddUser(r.Context(), username, string(hash))
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, strconv.Itoa(id))
+	}
+}
+
+// Returns a handler for the "/users/{id}/" URL.
+// The request method should be GET. Sends back the user of given identifier as
+// a json encoded plants.User. Requests made on behalf of a user can only get
+// this user.
+func UserHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		userId, err := requestUser(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if userId != 0 && userId != id {
+			writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+
+		user, err := db.GetUser(r.Context(), id)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		err = json.NewEncoder(w).Encode(user)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Returns a handler for the "/users/login/" URL.
+// The request method should be POST. Checks the "password" form value against
+// the password of the user of given "username", and sends back the user as a
+// json encoded plants.User if it matches. Otherwise sends back
+// http.StatusUnauthorized, whether the user exists or not.
+func LoginHandler(db database.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, notAllowed)
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		username := strings.TrimSpace(r.PostForm.Get("username"))
+		password := r.PostForm.Get("password")
+		user, hash, err := db.GetUserByName(r.Context(), username)
+		if errors.Is(err, database.ErrNotFound) {
+			bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+			writeError(
+				w,
+				http.StatusUnauthorized,
+				"Invalid username or password",
+			)
+			return
+		}
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err != nil {
+			writeError(
+				w,
+				http.StatusUnauthorized,
+				"Invalid username or password",
+			)
+			return
+		}
+
+		err = json.NewEncoder(w).Encode(user)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+}
+
+// Returns the identifier of the user on whose behalf r is made, given by its
+// UserHeader header, or 0 if it has none.
+func requestUser(r *http.Request) (int, error) {
+	s := r.Header.Get(UserHeader)
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, errors.New("User identifier is not valid")
+	}
+	return id, nil
+}
+
+// Checks that the username is not empty after trim, not longer than 64
+// characters and only made of letters, digits, '.', '-' and '_'. The string
+// returned is the trimmed username.
+func sanitizeUsername(username string) (string, error) {
+	s := strings.TrimSpace(username)
+	if s == "" {
+		return "", errors.New("Username is empty")
+	}
+	if !utf8.ValidString(s) {
+		return "", errors.New("Username is not valid utf8")
+	}
+	if utf8.RuneCountInString(s) > usernameMaxLen {
+		return "", errors.New("Username length is greater than 64")
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) &&
+			!strings.ContainsRune(".-_", r) {
+			return "", errors.New(
+				"Username must only contain letters, digits, '.', '-' and '_'",
+			)
+		}
+	}
+	return s, nil
+}
+
+// Checks that the password is between 8 characters and 72 bytes long.
+func checkPassword(password string) error {
+	if utf8.RuneCountInString(password) < passwordMinLen {
+		return errors.New("Password must be at least 8 characters long")
+	}
+	if len(password) > passwordMaxLen {
+		return errors.New("Password must be at most 72 bytes long")
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"time"
+)
+
+// EXIF tags used by this package.
+const (
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagOffsetOriginal   = 0x9011
+)
+
+// Layout of the EXIF dates and times.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+var errBadExif = errors.New("imaging: Malformed EXIF data")
+
+// EXIF metadata, in the TIFF format.
+type exif struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// An entry of an image file directory.
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	// Position of the value, or of the offset of the value if it does not fit
+	// in 4 bytes, in the TIFF data.
+	pos int
+}
+
+// Sizes of the values of the TIFF types, by type. Type 13 is the IFD type of
+// the pointers to sub-directories, a LONG offset.
+var typeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+	13: 4,
+}
+
+// Parses the header of TIFF data b.
+func parseExif(b []byte) (*exif, error) {
+	if len(b) < 8 {
+		return nil, errBadExif
+	}
+	e := &exif{b: b}
+	switch string(b[:4]) {
+	case "II*\x00":
+		e.order = binary.LittleEndian
+	case "MM\x00*":
+		e.order = binary.BigEndian
+	default:
+		return nil, errBadExif
+	}
+	return e, nil
+}
+
+// Returns the entries of the first directory, IFD0.
+func (e *exif) ifd0() ([]ifdEntry, error) {
+	return e.ifd(e.order.Uint32(e.b[4:8]))
+}
+
+// Returns the entries of the directory at given offset, which must be past the
+// header.
+func (e *exif) ifd(offset uint32) ([]ifdEntry, error) {
+	start := int(offset)
+	if offset < 8 || offset > uint32(len(e.b)) || start+2 > len(e.b) {
+		return nil, errBadExif
+	}
+	n := int(e.order.Uint16(e.b[start:]))
+	if start+2+12*n > len(e.b) {
+		return nil, errBadExif
+	}
+	entries := make([]ifdEntry, n)
+	for i := range entries {
+		p := start + 2 + 12*i
+		entries[i] = ifdEntry{
+			tag:   e.order.Uint16(e.b[p:]),
+			typ:   e.order.Uint16(e.b[p+2:]),
+			count: e.order.Uint32(e.b[p+4:]),
+			pos:   p + 8,
+		}
+	}
+	return entries, nil
+}
+
+// Returns the position and size in bytes of the value of entry en. Values
+// stored at an offset must be past the header.
+func (e *exif) value(en ifdEntry) (int, int, error) {
+	size, ok := typeSizes[en.typ]
+	if !ok || en.count > uint32(len(e.b)) {
+		return 0, 0, errBadExif
+	}
+	size *= int(en.count)
+	pos := en.pos
+	if size > 4 {
+		offset := e.order.Uint32(e.b[en.pos:])
+		if offset < 8 || offset > uint32(len(e.b)) {
+			return 0, 0, errBadExif
+		}
+		pos = int(offset)
+	}
+	if pos+size > len(e.b) {
+		return 0, 0, errBadExif
+	}
+	return pos, size, nil
+}
+
+// Returns the value of the ASCII entry of given tag, if there is one.
+func (e *exif) ascii(entries []ifdEntry, tag uint16) (string, bool) {
+	for _, en := range entries {
+		if en.tag != tag || en.typ != 2 {
+			continue
+		}
+		pos, size, err := e.value(en)
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimRight(string(e.b[pos:pos+size]), "\x00 "), true
+	}
+	return "", false
+}
+
+// Returns the value of the SHORT, LONG or IFD entry of given tag, if there is
+// one.
+func (e *exif) uint(entries []ifdEntry, tag uint16) (uint32, bool) {
+	for _, en := range entries {
+		if en.tag != tag || en.count != 1 {
+			continue
+		}
+		switch en.typ {
+		case 3:
+			return uint32(e.order.Uint16(e.b[en.pos:])), true
+		case 4, 13:
+			return e.order.Uint32(e.b[en.pos:]), true
+		}
+	}
+	return 0, false
+}
+
+// Reports whether there is an entry of given tag, whatever its type.
+func hasTag(entries []ifdEntry, tag uint16) bool {
+	for _, en := range entries {
+		if en.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the time the image was captured, from the original date and time of
+// the EXIF sub-directory, or else from the date and time of IFD0. Without time
+// offset, the time is in loc.
+func (e *exif) captureTime(loc *time.Location) (time.Time, bool) {
+	ifd0, err := e.ifd0()
+	if err != nil {
+		return time.Time{}, false
+	}
+	if offset, ok := e.uint(ifd0, tagExifIFD); ok {
+		sub, err := e.ifd(offset)
+		if err == nil {
+			if s, ok := e.ascii(sub, tagDateTimeOriginal); ok {
+				if zone, ok := e.ascii(sub, tagOffsetOriginal); ok {
+					t, err := time.Parse(exifTimeLayout+"-07:00", s+zone)
+					if err == nil {
+						return t, true
+					}
+				}
+				t, err := time.ParseInLocation(exifTimeLayout, s, loc)
+				if err == nil {
+					return t, true
+				}
+			}
+		}
+	}
+	if s, ok := e.ascii(ifd0, tagDateTime); ok {
+		t, err := time.ParseInLocation(exifTimeLayout, s, loc)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns the EXIF orientation of the image, from 1 to 8, 1 being the normal
+// orientation.
+func (e *exif) orientation() int {
+	ifd0, err := e.ifd0()
+	if err != nil {
+		return 1
+	}
+	o, ok := e.uint(ifd0, tagOrientation)
+	if !ok || o < 1 || o > 8 {
+		return 1
+	}
+	return int(o)
+}
+
+// Overwrites the GPS directory with zeros, leaving an empty directory. The
+// other metadata, and the size of the data, are unchanged. Fails if there is a
+// pointer to a GPS directory that cannot be followed, rather than leaving the
+// location behind.
+func (e *exif) clearGPS() error {
+	ifd0, err := e.ifd0()
+	if err != nil {
+		return err
+	}
+	offset, ok := e.uint(ifd0, tagGPSIFD)
+	if !ok {
+		if hasTag(ifd0, tagGPSIFD) {
+			return errBadExif
+		}
+		return nil
+	}
+	entries, err := e.ifd(offset)
+	if err != nil {
+		return err
+	}
+	for _, en := range entries {
+		pos, size, err := e.value(en)
+		if err != nil {
+			return err
+		}
+		clear(e.b[pos : pos+size])
+	}
+	// The entries, and the offset of the next directory, which must be 0 for
+	// the GPS directory
+	start := int(offset)
+	end := min(start+2+12*len(entries)+4, len(e.b))
+	clear(e.b[start:end])
+	return nil
+}
+
+// Returns the range of the EXIF metadata, in the TIFF format, in the image data
+// b of given content type. Returns an empty range if there is none.
+func findExif(b []byte, contentType string) (int, int, error) {
+	switch contentType {
+	case "image/jpeg":
+		return findJPEGExif(b)
+	case "image/png":
+		start, end, _, err := findPNGExif(b)
+		return start, end, err
+	case "image/webp":
+		return findWebPExif(b)
+	}
+	return 0, 0, nil
+}
+
+// Finds the EXIF metadata of a JPEG image, in an APP1 segment before the start
+// of the image scan.
+func findJPEGExif(b []byte) (int, int, error) {
+	if len(b) < 2 || b[0] != 0xff || b[1] != 0xd8 {
+		return 0, 0, errors.New("imaging: Malformed JPEG image")
+	}
+	p := 2
+	for p+4 <= len(b) {
+		if b[p] != 0xff {
+			return 0, 0, errors.New("imaging: Malformed JPEG image")
+		}
+		marker := b[p+1]
+		switch {
+		case marker == 0xff:
+			// Fill byte
+			p++
+			continue
+		case marker == 0x01 || marker >= 0xd0 && marker <= 0xd7:
+			// Markers without data
+			p += 2
+			continue
+		case marker == 0xda || marker == 0xd9:
+			// Start of scan or end of image, metadata comes before
+			return 0, 0, nil
+		}
+		length := int(binary.BigEndian.Uint16(b[p+2:]))
+		if length < 2 || p+2+length > len(b) {
+			return 0, 0, errors.New("imaging: Malformed JPEG image")
+		}
+		data := b[p+4 : p+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+			return p + 10, p + 2 + length, nil
+		}
+		p += 2 + length
+	}
+	return 0, 0, nil
+}
+
+// Finds the EXIF metadata of a PNG image, in an eXIf chunk. Also returns the
+// position of the chunk.
+func findPNGExif(b []byte) (int, int, int, error) {
+	p := 8
+	for p+12 <= len(b) {
+		length := binary.BigEndian.Uint32(b[p:])
+		if length > uint32(len(b)) || p+12+int(length) > len(b) {
+			return 0, 0, 0, errors.New("imaging: Malformed PNG image")
+		}
+		switch string(b[p+4 : p+8]) {
+		case "eXIf":
+			return p + 8, p + 8 + int(length), p, nil
+		case "IEND":
+			return 0, 0, 0, nil
+		}
+		p += 12 + int(length)
+	}
+	return 0, 0, 0, nil
+}
+
+// Finds the EXIF metadata of a WebP image, in an EXIF chunk of the RIFF
+// container.
+func findWebPExif(b []byte) (int, int, error) {
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WEBP" {
+		return 0, 0, errors.New("imaging: Malformed WebP image")
+	}
+	p := 12
+	for p+8 <= len(b) {
+		size := binary.LittleEndian.Uint32(b[p+4:])
+		if size > uint32(len(b)) || p+8+int(size) > len(b) {
+			return 0, 0, errors.New("imaging: Malformed WebP image")
+		}
+		if string(b[p:p+4]) == "EXIF" {
+			start := p + 8
+			// Some encoders keep the header of the JPEG APP1 segment
+			if bytes.HasPrefix(b[start:], []byte("Exif\x00\x00")) {
+				start += 6
+			}
+			return start, p + 8 + int(size), nil
+		}
+		p += 8 + int(size) + int(size%2)
+	}
+	return 0, 0, nil
+}
+
+// StripGPS returns a copy of the image data b, of given content type, whose
+// EXIF metadata no longer holds the GPS location where the image was captured.
+// The image and its other metadata are unchanged. Returns an error if the
+// metadata cannot be parsed, in which case the location could not be removed.
+func StripGPS(b []byte, contentType string) ([]byte, error) {
+	start, end, err := findExif(b, contentType)
+	if err != nil || start == end {
+		return b, err
+	}
+	stripped := bytes.Clone(b)
+	e, err := parseExif(stripped[start:end])
+	if err != nil {
+		return nil, err
+	}
+	err = e.clearGPS()
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "image/png" {
+		// The checksum of the chunk covers its type and data
+		_, _, chunk, _ := findPNGExif(stripped)
+		crc := crc32.ChecksumIEEE(stripped[chunk+4 : end])
+		binary.BigEndian.PutUint32(stripped[end:], crc)
+	}
+	return stripped, nil
+}
+
+// CaptureTime returns the time at which the image of data b, of given content
+// type, was captured according to its EXIF metadata. Times without offset are
+// in the local time zone. Returns false if the metadata has no capture time.
+func CaptureTime(b []byte, contentType string) (time.Time, bool) {
+	start, end, err := findExif(b, contentType)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+	e, err := parseExif(b[start:end])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return e.captureTime(time.Local)
+}
+
+// Returns the EXIF orientation of the image of data b, 1 if it is unknown.
+func orientation(b []byte, contentType string) int {
+	start, end, err := findExif(b, contentType)
+	if err != nil || start == end {
+		return 1
+	}
+	e, err := parseExif(b[start:end])
+	if err != nil {
+		return 1
+	}
+	return e.orientation()
+}
@@ -0,0 +1,518 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Byte order of the TIFF data built by the tests.
+type testOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// An entry of a directory built by buildTIFF. The value is in the byte order
+// of the TIFF data.
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// Builds TIFF data of given byte order made of IFD0 and of the sub-directories
+// of subs, by the tag of their pointer in IFD0. The pointers of IFD0 are
+// filled with the offsets of their sub-directory. Values that do not fit in 4
+// bytes follow the directories.
+func buildTIFF(
+	order testOrder,
+	ifd0 []testEntry,
+	subs map[uint16][]testEntry,
+) []byte {
+	tags := make([]uint16, 0, len(subs))
+	for tag := range subs {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	ifdSize := func(entries []testEntry) int { return 2 + 12*len(entries) + 4 }
+	offsets := map[uint16]int{}
+	end := 8 + ifdSize(ifd0)
+	for _, tag := range tags {
+		offsets[tag] = end
+		end += ifdSize(subs[tag])
+	}
+
+	b := make([]byte, end)
+	if order.String() == binary.LittleEndian.String() {
+		copy(b, "II*\x00")
+	} else {
+		copy(b, "MM\x00*")
+	}
+	order.PutUint32(b[4:], 8)
+	writeIFD := func(start int, entries []testEntry, pointers bool) {
+		order.PutUint16(b[start:], uint16(len(entries)))
+		for i, en := range entries {
+			p := start + 2 + 12*i
+			order.PutUint16(b[p:], en.tag)
+			order.PutUint16(b[p+2:], en.typ)
+			order.PutUint32(b[p+4:], en.count)
+			if offset, ok := offsets[en.tag]; ok && pointers {
+				order.PutUint32(b[p+8:], uint32(offset))
+			} else if len(en.value) <= 4 {
+				copy(b[p+8:], en.value)
+			} else {
+				order.PutUint32(b[p+8:], uint32(len(b)))
+				b = append(b, en.value...)
+			}
+		}
+	}
+	writeIFD(8, ifd0, true)
+	for _, tag := range tags {
+		writeIFD(offsets[tag], subs[tag], false)
+	}
+	return b
+}
+
+// Returns the ASCII entry of given tag whose value is the string s.
+func asciiEntry(tag uint16, s string) testEntry {
+	return testEntry{tag, 2, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+// Returns the entry of given tag and type whose value is the 4 bytes number n.
+func longEntry(order testOrder, tag, typ uint16, n uint32) testEntry {
+	return testEntry{tag, typ, 1, order.AppendUint32(nil, n)}
+}
+
+// Returns the entry of given tag whose value is the RATIONAL numbers of the
+// integers of ns.
+func rationalsEntry(order testOrder, tag uint16, ns ...uint32) testEntry {
+	var value []byte
+	for _, n := range ns {
+		value = order.AppendUint32(value, n)
+		value = order.AppendUint32(value, 1)
+	}
+	return testEntry{tag, 5, uint32(len(ns)), value}
+}
+
+// Returns the EXIF metadata of the fixtures: an orientation, a capture time
+// and the location of Paris. The pointer to the GPS directory is of type
+// gpsType.
+func gpsTIFF(order testOrder, gpsType uint16) []byte {
+	orientation := testEntry{tagOrientation, 3, 1, order.AppendUint16(nil, 6)}
+	return buildTIFF(
+		order,
+		[]testEntry{
+			orientation,
+			asciiEntry(tagDateTime, "2024:05:18 10:00:00"),
+			longEntry(order, tagExifIFD, 4, 0),
+			longEntry(order, tagGPSIFD, gpsType, 0),
+		},
+		map[uint16][]testEntry{
+			tagExifIFD: {
+				asciiEntry(tagDateTimeOriginal, "2024:05:17 09:30:00"),
+				asciiEntry(tagOffsetOriginal, "+02:00"),
+			},
+			tagGPSIFD: {
+				// GPSVersionID, GPSLatitudeRef, GPSLatitude, GPSLongitudeRef
+				// and GPSLongitude
+				{0x0000, 1, 4, []byte{2, 3, 0, 0}},
+				asciiEntry(0x0001, "N"),
+				rationalsEntry(order, 0x0002, 48, 51, 24),
+				asciiEntry(0x0003, "E"),
+				rationalsEntry(order, 0x0004, 2, 21, 3),
+			},
+		},
+	)
+}
+
+// Fixtures: JPEG images whose EXIF metadata is gpsTIFF, in both byte orders
+// and with both types of pointers.
+var gpsFixtures = []struct {
+	file    string
+	order   testOrder
+	gpsType uint16
+}{
+	{"gps-le.jpg", binary.LittleEndian, 4},
+	{"gps-be.jpg", binary.BigEndian, 4},
+	{"gps-ifd-le.jpg", binary.LittleEndian, 13},
+	{"gps-ifd-be.jpg", binary.BigEndian, 13},
+}
+
+// Reads the fixture of given name of the testdata directory.
+func readFixture(t testing.TB, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Returns the EXIF metadata of JPEG image b.
+func jpegExif(t *testing.T, b []byte) *exif {
+	t.Helper()
+	start, end, err := findJPEGExif(b)
+	if err != nil || start == end {
+		t.Fatalf("no EXIF metadata: %v", err)
+	}
+	e, err := parseExif(b[start:end])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// Returns the ranges of the GPS directory of e and of the values of its
+// entries.
+func gpsRanges(t *testing.T, e *exif) [][2]int {
+	t.Helper()
+	ifd0, err := e.ifd0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, ok := e.uint(ifd0, tagGPSIFD)
+	if !ok {
+		t.Fatal("no GPS directory")
+	}
+	entries, err := e.ifd(offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := int(offset)
+	ranges := [][2]int{{start, start + 2 + 12*len(entries) + 4}}
+	for _, en := range entries {
+		pos, size, err := e.value(en)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges = append(ranges, [2]int{pos, pos + size})
+	}
+	return ranges
+}
+
+func TestFixtures(t *testing.T) {
+	for _, fixture := range gpsFixtures {
+		b := readFixture(t, fixture.file)
+		e := jpegExif(t, b)
+		want := gpsTIFF(fixture.order, fixture.gpsType)
+		if !bytes.Equal(e.b, want) {
+			t.Errorf("%s: metadata is not the one of gpsTIFF", fixture.file)
+		}
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	for _, fixture := range gpsFixtures {
+		b := readFixture(t, fixture.file)
+		original := bytes.Clone(b)
+		stripped, err := StripGPS(b, "image/jpeg")
+		if err != nil {
+			t.Errorf("%s: %v", fixture.file, err)
+			continue
+		}
+		if !bytes.Equal(b, original) {
+			t.Errorf("%s: the original data is modified", fixture.file)
+		}
+		if len(stripped) != len(b) {
+			t.Errorf(
+				"%s: size = %d, want %d",
+				fixture.file,
+				len(stripped),
+				len(b),
+			)
+			continue
+		}
+
+		// The GPS directory and its values are zeroed, the rest of the data
+		// is unchanged
+		start, _, _ := findJPEGExif(b)
+		zeroed := make([]bool, len(b))
+		for _, r := range gpsRanges(t, jpegExif(t, b)) {
+			for i := start + r[0]; i < start+r[1]; i++ {
+				zeroed[i] = true
+			}
+		}
+		for i := range b {
+			if zeroed[i] && stripped[i] != 0 {
+				t.Errorf("%s: GPS byte %d is not zeroed", fixture.file, i)
+				break
+			}
+			if !zeroed[i] && stripped[i] != b[i] {
+				t.Errorf("%s: byte %d is modified", fixture.file, i)
+				break
+			}
+		}
+
+		e := jpegExif(t, stripped)
+		ifd0, err := e.ifd0()
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset, _ := e.uint(ifd0, tagGPSIFD)
+		entries, err := e.ifd(offset)
+		if err != nil || len(entries) != 0 {
+			t.Errorf(
+				"%s: GPS directory has %d entries, error %v",
+				fixture.file,
+				len(entries),
+				err,
+			)
+		}
+		if o := e.orientation(); o != 6 {
+			t.Errorf("%s: orientation = %d, want 6", fixture.file, o)
+		}
+		if _, ok := CaptureTime(stripped, "image/jpeg"); !ok {
+			t.Errorf("%s: capture time is lost", fixture.file)
+		}
+		_, err = jpeg.Decode(bytes.NewReader(stripped))
+		if err != nil {
+			t.Errorf("%s: image cannot be decoded: %v", fixture.file, err)
+		}
+
+		// Stripping again changes nothing
+		again, err := StripGPS(stripped, "image/jpeg")
+		if err != nil || !bytes.Equal(again, stripped) {
+			t.Errorf("%s: stripping twice fails: %v", fixture.file, err)
+		}
+	}
+}
+
+func TestStripGPSWithoutExif(t *testing.T) {
+	b := readFixture(t, "gps-le.jpg")
+	start, end, _ := findJPEGExif(b)
+	// The APP1 segment, from its marker, is removed
+	plain := append(bytes.Clone(b[:start-10]), b[end:]...)
+	stripped, err := StripGPS(plain, "image/jpeg")
+	if err != nil || !bytes.Equal(stripped, plain) {
+		t.Errorf("stripping an image without metadata fails: %v", err)
+	}
+	stripped, err = StripGPS([]byte("GIF89a"), "image/gif")
+	if err != nil || string(stripped) != "GIF89a" {
+		t.Errorf("stripping an image of unknown type fails: %v", err)
+	}
+}
+
+func TestStripGPSTruncated(t *testing.T) {
+	b := readFixture(t, "gps-be.jpg")
+	start, end, _ := findJPEGExif(b)
+	// Cuts in the JPEG header, in the APP1 segment and in the image data
+	for _, n := range []int{1, start - 4, start + 20, end - 1} {
+		_, err := StripGPS(b[:n], "image/jpeg")
+		if err == nil {
+			t.Errorf("image truncated at %d: no error", n)
+		}
+	}
+	stripped, err := StripGPS(b[:end+10], "image/jpeg")
+	if err != nil {
+		t.Errorf("image truncated after the metadata: %v", err)
+	} else if len(stripped) != end+10 {
+		t.Errorf("size = %d, want %d", len(stripped), end+10)
+	}
+}
+
+func TestClearGPSMalformed(t *testing.T) {
+	le := binary.LittleEndian
+	valid := gpsTIFF(le, 4)
+	// Offset of the pointer to the GPS directory, the fourth entry of IFD0
+	gpsPointer := 8 + 2 + 12*3
+	gpsOffset := int(le.Uint32(valid[gpsPointer+8:]))
+
+	tests := []struct {
+		name   string
+		b      []byte
+		change func(b []byte)
+		err    bool
+	}{
+		{"valid", valid, nil, false},
+		{"no GPS", buildTIFF(le, []testEntry{
+			asciiEntry(tagDateTime, "2024:05:18 10:00:00"),
+		}, nil), nil, false},
+		{"short header", valid[:6], nil, true},
+		{"bad magic", valid, func(b []byte) { copy(b, "IX*\x00") }, true},
+		{"IFD0 out of range", valid, func(b []byte) {
+			le.PutUint32(b[4:], uint32(len(b)))
+		}, true},
+		{"IFD0 truncated", valid[:8+2+12*2], nil, true},
+		{"IFD0 count too large", valid, func(b []byte) {
+			le.PutUint16(b[8:], 0xffff)
+		}, true},
+		{"GPS pointer out of range", valid, func(b []byte) {
+			le.PutUint32(b[gpsPointer+8:], 0xfffffff0)
+		}, true},
+		{"GPS pointer of ASCII type", valid, func(b []byte) {
+			le.PutUint16(b[gpsPointer+2:], 2)
+		}, true},
+		{"GPS pointer of count 2", valid, func(b []byte) {
+			le.PutUint32(b[gpsPointer+4:], 2)
+		}, true},
+		{"GPS directory truncated", valid[:gpsOffset+2+12], nil, true},
+		{"GPS value out of range", valid, func(b []byte) {
+			// The offset of GPSLatitude, the third entry
+			le.PutUint32(b[gpsOffset+2+12*2+8:], uint32(len(b)))
+		}, true},
+		{"GPS value of unknown type", valid, func(b []byte) {
+			le.PutUint16(b[gpsOffset+2+12*2+2:], 99)
+		}, true},
+		{"GPS value count too large", valid, func(b []byte) {
+			le.PutUint32(b[gpsOffset+2+12*2+4:], 0xffffffff)
+		}, true},
+	}
+	for _, test := range tests {
+		b := bytes.Clone(test.b)
+		if test.change != nil {
+			test.change(b)
+		}
+		e, err := parseExif(b)
+		if err == nil {
+			err = e.clearGPS()
+		}
+		if (err != nil) != test.err {
+			t.Errorf("%s: error = %v, want error %t", test.name, err, test.err)
+		}
+	}
+}
+
+func TestCaptureTime(t *testing.T) {
+	paris := time.FixedZone("", 2*60*60)
+	want := time.Date(2024, 5, 17, 9, 30, 0, 0, paris)
+	for _, fixture := range gpsFixtures {
+		b := readFixture(t, fixture.file)
+		got, ok := CaptureTime(b, "image/jpeg")
+		if !ok || !got.Equal(want) {
+			t.Errorf(
+				"%s: capture time = %v, %t, want %v",
+				fixture.file,
+				got,
+				ok,
+				want,
+			)
+		}
+	}
+
+	le := binary.LittleEndian
+	utc := time.UTC
+	tests := []struct {
+		name string
+		b    []byte
+		want time.Time
+		ok   bool
+	}{
+		{
+			"IFD0 only",
+			buildTIFF(le, []testEntry{
+				asciiEntry(tagDateTime, "2024:05:18 10:00:00"),
+			}, nil),
+			time.Date(2024, 5, 18, 10, 0, 0, 0, utc),
+			true,
+		},
+		{
+			"original without offset",
+			buildTIFF(le, []testEntry{
+				asciiEntry(tagDateTime, "2024:05:18 10:00:00"),
+				longEntry(le, tagExifIFD, 4, 0),
+			}, map[uint16][]testEntry{
+				tagExifIFD: {
+					asciiEntry(tagDateTimeOriginal, "2024:05:17 09:30:00"),
+				},
+			}),
+			time.Date(2024, 5, 17, 9, 30, 0, 0, utc),
+			true,
+		},
+		{
+			"invalid original",
+			buildTIFF(le, []testEntry{
+				asciiEntry(tagDateTime, "2024:05:18 10:00:00"),
+				longEntry(le, tagExifIFD, 13, 0),
+			}, map[uint16][]testEntry{
+				tagExifIFD: {asciiEntry(tagDateTimeOriginal, "yesterday")},
+			}),
+			time.Date(2024, 5, 18, 10, 0, 0, 0, utc),
+			true,
+		},
+		{
+			"no time",
+			buildTIFF(le, []testEntry{
+				{tagOrientation, 3, 1, le.AppendUint16(nil, 1)},
+			}, nil),
+			time.Time{},
+			false,
+		},
+		{"no directory", []byte("II*\x00\xff\xff\xff\xff"), time.Time{}, false},
+	}
+	for _, test := range tests {
+		e, err := parseExif(test.b)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got, ok := e.captureTime(utc)
+		if ok != test.ok || !got.Equal(test.want) {
+			t.Errorf(
+				"%s: capture time = %v, %t, want %v, %t",
+				test.name,
+				got,
+				ok,
+				test.want,
+				test.ok,
+			)
+		}
+	}
+}
+
+// Content types of the images whose metadata is parsed.
+var exifTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+func FuzzStripGPS(f *testing.F) {
+	for _, fixture := range gpsFixtures {
+		f.Add(readFixture(f, fixture.file))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, contentType := range exifTypes {
+			stripped, err := StripGPS(b, contentType)
+			if err != nil {
+				continue
+			}
+			if len(stripped) != len(b) {
+				t.Fatalf(
+					"%s: size = %d, want %d",
+					contentType,
+					len(stripped),
+					len(b),
+				)
+			}
+			// What is left of the GPS directory is empty
+			start, end, err := findExif(stripped, contentType)
+			if err != nil || start == end {
+				continue
+			}
+			e, err := parseExif(stripped[start:end])
+			if err != nil {
+				t.Fatalf("%s: stripped metadata cannot be parsed", contentType)
+			}
+			ifd0, _ := e.ifd0()
+			if offset, ok := e.uint(ifd0, tagGPSIFD); ok {
+				entries, err := e.ifd(offset)
+				if err == nil && len(entries) > 0 {
+					t.Fatalf("%s: GPS directory is not empty", contentType)
+				}
+			}
+		}
+	})
+}
+
+func FuzzCaptureTime(f *testing.F) {
+	for _, fixture := range gpsFixtures {
+		f.Add(readFixture(f, fixture.file))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, contentType := range exifTypes {
+			CaptureTime(b, contentType)
+		}
+	})
+}
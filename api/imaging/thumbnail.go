@@ -0,0 +1,122 @@
+// Package imaging processes the photos of the plants: thumbnails and EXIF
+// metadata.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Maximum number of pixels of a decoded image, so that small files of huge
+// dimensions do not exhaust memory.
+const maxPixels = 50_000_000
+
+// ThumbnailType is the content type of the thumbnails.
+const ThumbnailType = "image/jpeg"
+
+// ErrTooLarge is returned when the dimensions of an image are too large for
+// it to be decoded.
+var ErrTooLarge = errors.New("imaging: Image dimensions are too large")
+
+// Thumbnail decodes the image of data b, of given content type, and returns a
+// JPEG copy scaled down to fit in a square of size pixels, turned according to
+// its EXIF orientation. Transparent parts are white.
+func Thumbnail(b []byte, contentType string, size int) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	// Orientations 5 to 8 swap width and height
+	o := orientation(b, contentType)
+	w, h := fit(img.Bounds().Dx(), img.Bounds().Dy(), size)
+	if o >= 5 {
+		w, h = fit(img.Bounds().Dy(), img.Bounds().Dx(), size)
+		w, h = h, w
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+	white := image.NewUniform(color.White)
+	draw.Draw(scaled, scaled.Bounds(), white, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(
+		scaled,
+		scaled.Bounds(),
+		img,
+		img.Bounds(),
+		draw.Over,
+		nil,
+	)
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, orient(scaled, o), &jpeg.Options{Quality: 80})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Returns the dimensions of an image of width w and height h scaled down to fit
+// in a square of size pixels, keeping its aspect ratio. Smaller images keep
+// their dimensions.
+func fit(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return w, h
+	}
+	if w >= h {
+		return size, max(1, h*size/w)
+	}
+	return max(1, w*size/h), size
+}
+
+// Returns img turned so that it is displayed upright, given its EXIF
+// orientation o.
+func orient(img *image.RGBA, o int) *image.RGBA {
+	if o <= 1 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Position of the pixel (x, y) of the stored image once displayed
+			var dx, dy int
+			switch o {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, img.RGBAAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
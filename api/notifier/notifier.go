@@ -0,0 +1,86 @@
+// Package notifier sends reminders of the care tasks of the plants through
+// notification channels, such as email or webhooks.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"github.com/mgmu/hortus/internal/plants"
+	"strings"
+	"time"
+)
+
+// Notifier sends notifications through a channel. Notify stops when its
+// context is done.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Kind of a notification.
+type Kind string
+
+const (
+	// Tasks that just became overdue.
+	KindReminder Kind = "reminder"
+	// Daily summary of the tasks due today or overdue.
+	KindDigest Kind = "digest"
+)
+
+// Notification is a message about care tasks. Subject and Body are a plain
+// text rendering of the tasks, for channels meant to be read by people.
+type Notification struct {
+	Kind    Kind              `json:"kind"`
+	Subject string            `json:"subject"`
+	Body    string            `json:"body"`
+	Tasks   []plants.CareTask `json:"tasks"`
+	SentAt  time.Time         `json:"sent_at"`
+}
+
+// Layout of the dates in the body of notifications.
+const dateLayout = "2006-01-02"
+
+// Returns a reminder of the tasks that became overdue.
+func newReminder(tasks []plants.CareTask, now time.Time) Notification {
+	subject := fmt.Sprintf("Hortus: %d overdue care task(s)", len(tasks))
+	return Notification{
+		Kind:    KindReminder,
+		Subject: subject,
+		Body:    "These plants are waiting for care:\n\n" + taskList(tasks),
+		Tasks:   tasks,
+		SentAt:  now,
+	}
+}
+
+// Returns the digest of the tasks due on the day of now.
+func newDigest(tasks []plants.CareTask, now time.Time) Notification {
+	subject := fmt.Sprintf(
+		"Hortus: %d care task(s) for %s",
+		len(tasks),
+		now.Format(dateLayout),
+	)
+	return Notification{
+		Kind:    KindDigest,
+		Subject: subject,
+		Body:    "Care planned for today:\n\n" + taskList(tasks),
+		Tasks:   tasks,
+		SentAt:  now,
+	}
+}
+
+// Returns one line per task, such as:
+//
+//   - Rosier: watering, overdue since 2025-06-01 (last done 2025-05-27)
+func taskList(tasks []plants.CareTask) string {
+	var b strings.Builder
+	for _, t := range tasks {
+		fmt.Fprintf(&b, "- %s: %s", t.CommonName, t.EventType)
+		if t.Overdue {
+			fmt.Fprintf(&b, ", overdue since %s", t.DueAt.Format(dateLayout))
+		}
+		if !t.LastDone.IsZero() {
+			fmt.Fprintf(&b, " (last done %s)", t.LastDone.Format(dateLayout))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
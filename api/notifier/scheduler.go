@@ -0,0 +1,155 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"github.com/mgmu/hortus/internal/plants"
+	"log"
+	"time"
+)
+
+// TaskSource provides the care tasks of the plants. It is implemented by
+// database.Database.
+type TaskSource interface {
+	// GetCareTasks returns the next care task of each care schedule of the
+	// plant of given identifier, or of all the plants of the households the
+	// user of given identifier is a member of if it is 0, as of now. A user
+	// identifier of 0 selects the plants of all the households.
+	GetCareTasks(
+		ctx context.Context,
+		userId, plantId int,
+		now time.Time,
+	) ([]plants.CareTask, error)
+}
+
+// Scheduler periodically checks the care tasks of the plants of a household,
+// and sends notifications through its notifiers: a reminder when tasks become
+// overdue, and a daily digest of the tasks due today or overdue. The recipients
+// of the notifiers are the same whatever the household, so the tasks of the
+// other households are never sent. A task is reminded once per due date. The
+// state of the scheduler is kept in memory, so a restarted scheduler may send
+// a reminder again.
+type Scheduler struct {
+	Tasks     TaskSource
+	Notifiers []Notifier
+	// Household whose tasks are notified, the plants of no household if 0.
+	HouseholdId int
+	// Duration between two checks of the tasks, an hour if not positive.
+	Interval time.Duration
+	// Hour of the day, from 0 to 23, from which the digest is sent.
+	DigestHour int
+	// Maximum duration of a check and its notifications, no limit if not
+	// positive.
+	Timeout time.Duration
+	// Returns the current time, time.Now if nil.
+	Now func() time.Time
+
+	// Due dates of the overdue tasks already reminded, by schedule.
+	reminded map[int]time.Time
+	// Day of the last digest sent.
+	lastDigest time.Time
+}
+
+// Run checks the tasks now and then after each interval, until ctx is done.
+// Errors are logged.
+func (s *Scheduler) Run(ctx context.Context) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := s.Check(ctx)
+		if err != nil {
+			log.Printf("notifier: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check fetches the tasks of the household and sends the reminders and digest
+// that are due. It must not be called concurrently with itself or Run.
+func (s *Scheduler) Check(ctx context.Context) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	tasks, err := s.Tasks.GetCareTasks(ctx, 0, 0, now)
+	if err != nil {
+		return err
+	}
+
+	// Tasks due by the end of the day, and the overdue ones not reminded yet
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	tomorrow := today.AddDate(0, 0, 1)
+	var due, overdue []plants.CareTask
+	reminded := make(map[int]time.Time)
+	for _, t := range tasks {
+		if t.HouseholdId != s.HouseholdId {
+			continue
+		}
+		if t.DueAt.Before(tomorrow) {
+			due = append(due, t)
+		}
+		if !t.Overdue {
+			continue
+		}
+		if at, ok := s.reminded[t.ScheduleId]; ok && at.Equal(t.DueAt) {
+			reminded[t.ScheduleId] = at
+		} else {
+			overdue = append(overdue, t)
+		}
+	}
+
+	var errs []error
+	if len(overdue) > 0 {
+		sent, err := s.notifyAll(ctx, newReminder(overdue, now))
+		errs = append(errs, err)
+		if sent {
+			for _, t := range overdue {
+				reminded[t.ScheduleId] = t.DueAt
+			}
+		}
+	}
+	// Completed tasks are forgotten, they are reminded again when overdue
+	s.reminded = reminded
+
+	if now.Hour() >= s.DigestHour && s.lastDigest.Before(today) {
+		sent := true
+		if len(due) > 0 {
+			sent, err = s.notifyAll(ctx, newDigest(due, now))
+			errs = append(errs, err)
+		}
+		if sent {
+			s.lastDigest = today
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sends n through all the notifiers of s. Returns whether at least one
+// notifier succeeded, and the errors of the others.
+func (s *Scheduler) notifyAll(ctx context.Context, n Notification) (bool, error) {
+	sent := false
+	var errs []error
+	for _, notifier := range s.Notifiers {
+		err := notifier.Notify(ctx, n)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			sent = true
+		}
+	}
+	return sent, errors.Join(errs...)
+}
@@ -0,0 +1,268 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"github.com/mgmu/hortus/internal/plants"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TaskSource whose tasks are set by the tests, recording the user identifiers
+// it is queried with.
+type fakeTasks struct {
+	tasks   []plants.CareTask
+	userIds []int
+}
+
+func (f *fakeTasks) GetCareTasks(
+	ctx context.Context,
+	userId, plantId int,
+	now time.Time,
+) ([]plants.CareTask, error) {
+	f.userIds = append(f.userIds, userId)
+	return f.tasks, nil
+}
+
+// Notifier recording the notifications it sends, or failing with err if it is
+// not nil.
+type fakeNotifier struct {
+	sent []Notification
+	err  error
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n Notification) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+// Returns the kinds of the notifications sent by f, and forgets them.
+func (f *fakeNotifier) take() []Kind {
+	var kinds []Kind
+	for _, n := range f.sent {
+		kinds = append(kinds, n.Kind)
+	}
+	f.sent = nil
+	return kinds
+}
+
+// Returns the watering task of the schedule of given identifier due at due,
+// overdue if due is before now.
+func task(scheduleId int, due, now time.Time) plants.CareTask {
+	return plants.CareTask{
+		ScheduleId: scheduleId,
+		PlantId:    1,
+		CommonName: "Rosier",
+		EventType:  plants.EventWatering,
+		DueAt:      due,
+		Overdue:    due.Before(now),
+	}
+}
+
+// Returns the time of given day of June 2025 and hour.
+func june(day, hour int) time.Time {
+	return time.Date(2025, time.June, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestSchedulerReminders(t *testing.T) {
+	var now time.Time
+	tasks := &fakeTasks{}
+	n := &fakeNotifier{}
+	s := &Scheduler{
+		Tasks:      tasks,
+		Notifiers:  []Notifier{n},
+		DigestHour: 23,
+		Now:        func() time.Time { return now },
+	}
+	// Due dates of the schedules 1 and 2, and the schedules reminded
+	steps := []struct {
+		now  time.Time
+		due  []time.Time
+		want []int
+	}{
+		// Not overdue yet
+		{june(1, 6), []time.Time{june(2, 0)}, nil},
+		// Overdue, reminded once for this due date
+		{june(2, 6), []time.Time{june(2, 0)}, []int{1}},
+		{june(2, 7), []time.Time{june(2, 0)}, nil},
+		{june(3, 6), []time.Time{june(2, 0)}, nil},
+		// Completed, then overdue again: the new due date is reminded
+		{june(3, 7), []time.Time{june(6, 0)}, nil},
+		{june(6, 6), []time.Time{june(6, 0)}, []int{1}},
+		// Another schedule becomes overdue, only it is reminded
+		{june(7, 6), []time.Time{june(6, 0), june(7, 0)}, []int{2}},
+		// Both are completed, then overdue the same day
+		{june(7, 7), []time.Time{june(9, 0), june(9, 0)}, nil},
+		{june(9, 6), []time.Time{june(9, 0), june(9, 0)}, []int{1, 2}},
+	}
+	for i, step := range steps {
+		now = step.now
+		tasks.tasks = nil
+		for j, due := range step.due {
+			tasks.tasks = append(tasks.tasks, task(j+1, due, now))
+		}
+		err := s.Check(context.Background())
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+
+		var got []int
+		for _, sent := range n.sent {
+			if sent.Kind != KindReminder {
+				t.Errorf("step %d: sent a %s", i, sent.Kind)
+				continue
+			}
+			for _, task := range sent.Tasks {
+				got = append(got, task.ScheduleId)
+			}
+		}
+		if len(step.want) > 0 && len(n.sent) != 1 {
+			t.Errorf("step %d: %d reminders sent, want 1", i, len(n.sent))
+		}
+		if !slices.Equal(got, step.want) {
+			t.Errorf("step %d: reminded %v, want %v", i, got, step.want)
+		}
+		n.sent = nil
+	}
+	for _, id := range tasks.userIds {
+		if id != 0 {
+			t.Errorf("tasks queried for user %d, want all the households", id)
+		}
+	}
+}
+
+func TestSchedulerDigest(t *testing.T) {
+	var now time.Time
+	tasks := &fakeTasks{}
+	n := &fakeNotifier{}
+	s := &Scheduler{
+		Tasks:      tasks,
+		Notifiers:  []Notifier{n},
+		DigestHour: 8,
+		Now:        func() time.Time { return now },
+	}
+	steps := []struct {
+		now  time.Time
+		want []Kind
+	}{
+		// Before the digest hour
+		{june(1, 7), nil},
+		// Once a day from the digest hour
+		{june(1, 8), []Kind{KindDigest}},
+		{june(1, 9), nil},
+		{june(1, 23), nil},
+		{june(2, 7), nil},
+		{june(2, 10), []Kind{KindDigest}},
+		// Days without tasks due have no digest
+		{june(3, 8), nil},
+		{june(3, 9), nil},
+	}
+	for i, step := range steps {
+		now = step.now
+		// A task due at the end of each of the first two days, not overdue
+		due := june(now.Day(), 23)
+		if now.Day() == 3 {
+			due = june(4, 8)
+		}
+		tasks.tasks = []plants.CareTask{task(1, due, now)}
+		err := s.Check(context.Background())
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		got := n.take()
+		if !slices.Equal(got, step.want) {
+			t.Errorf("step %d: sent %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	now := june(2, 9)
+	tasks := &fakeTasks{tasks: []plants.CareTask{task(1, june(2, 0), now)}}
+	failing := &fakeNotifier{err: errors.New("unreachable")}
+	s := &Scheduler{
+		Tasks:      tasks,
+		Notifiers:  []Notifier{failing},
+		DigestHour: 8,
+		Now:        func() time.Time { return now },
+	}
+
+	// Nothing was sent, the reminder and the digest are sent again
+	err := s.Check(context.Background())
+	if !errors.Is(err, failing.err) {
+		t.Fatalf("error = %v, want %v", err, failing.err)
+	}
+	failing.err = nil
+	now = june(2, 10)
+	err = s.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Kind{KindReminder, KindDigest}
+	if got := failing.take(); !slices.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+
+	// One notifier is enough for the notifications to be sent
+	now = june(3, 9)
+	tasks.tasks = []plants.CareTask{task(1, june(3, 0), now)}
+	ok := &fakeNotifier{}
+	failing.err = errors.New("unreachable")
+	s.Notifiers = []Notifier{failing, ok}
+	err = s.Check(context.Background())
+	if !errors.Is(err, failing.err) {
+		t.Fatalf("error = %v, want %v", err, failing.err)
+	}
+	now = june(3, 10)
+	s.Check(context.Background())
+	if got := ok.take(); !slices.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerHousehold(t *testing.T) {
+	now := june(2, 9)
+	tasks := &fakeTasks{}
+	for household := range 3 {
+		task := task(household+1, june(2, 0), now)
+		task.HouseholdId = household
+		tasks.tasks = append(tasks.tasks, task)
+	}
+	for household := range 3 {
+		n := &fakeNotifier{}
+		s := &Scheduler{
+			Tasks:       tasks,
+			Notifiers:   []Notifier{n},
+			HouseholdId: household,
+			DigestHour:  8,
+			Now:         func() time.Time { return now },
+		}
+		err := s.Check(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the tasks of the household are sent, in each notification
+		if len(n.sent) != 2 {
+			t.Fatalf(
+				"household %d: %d notifications sent, want 2",
+				household,
+				len(n.sent),
+			)
+		}
+		for _, sent := range n.sent {
+			if len(sent.Tasks) != 1 || sent.Tasks[0].HouseholdId != household {
+				t.Errorf(
+					"household %d: %s of %+v",
+					household,
+					sent.Kind,
+					sent.Tasks,
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends notifications by email through an SMTP server. The
+// connection is upgraded with STARTTLS when the server supports it.
+type SMTPNotifier struct {
+	// Address of the server, as host:port.
+	Addr string
+	From string
+	To   []string
+	// Authentication to the server, none if nil. Note that smtp.PlainAuth
+	// refuses to send credentials over an unencrypted connection, except to
+	// localhost.
+	Auth smtp.Auth
+}
+
+// Notify sends n as a plain text email to all the recipients of s.
+func (s *SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	if len(s.To) == 0 {
+		return errors.New("notifier: No email recipient")
+	}
+	msg, err := s.message(n)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	// The smtp package does not use contexts, the deadline of ctx applies to
+	// the whole exchange
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if s.Auth != nil {
+		err = c.Auth(s.Auth)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(s.From)
+	if err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		err = c.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Returns the email of notification n, with a quoted-printable UTF-8 body.
+func (s *SMTPNotifier) message(n Notification) ([]byte, error) {
+	var b bytes.Buffer
+	date := n.SentAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	b.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	body := strings.ReplaceAll(n.Body, "\n", "\r\n")
+	_, err := w.Write([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
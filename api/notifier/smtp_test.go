@@ -0,0 +1,198 @@
+package notifier
+
+import (
+	"context"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A fake SMTP server accepting one session on a local listener. It records
+// the commands of the client and the message, and rejects the recipients of
+// reject.
+type fakeSMTP struct {
+	ln       net.Listener
+	reject   string
+	commands []string
+	message  string
+	done     chan struct{}
+}
+
+// Starts a fake SMTP server, closed at the end of the test.
+func startSMTP(t *testing.T, reject string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, reject: reject, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// Serves one session.
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 localhost ESMTP fake")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		s.commands = append(s.commands, line)
+		verb, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			c.PrintfLine("250-localhost")
+			c.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			if s.reject != "" && strings.Contains(line, s.reject) {
+				c.PrintfLine("550 No such user")
+			} else {
+				c.PrintfLine("250 OK")
+			}
+		case "DATA":
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			b, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			s.message = string(b)
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// Waits for the end of the session.
+func (s *fakeSMTP) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session did not end")
+	}
+}
+
+func TestSMTPNotify(t *testing.T) {
+	server := startSMTP(t, "")
+	smtp := &SMTPNotifier{
+		Addr: server.ln.Addr().String(),
+		From: "hortus@example.com",
+		To:   []string{"alice@example.com", "bob@example.com"},
+	}
+	sentAt := time.Date(2025, time.June, 2, 8, 0, 0, 0, time.UTC)
+	n := newReminder(
+		[]plants.CareTask{task(1, june(1, 0), sentAt)},
+		sentAt,
+	)
+	n.Subject = "Hortus: 1 tâche à faire"
+	err := smtp.Notify(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.wait(t)
+
+	want := []string{
+		"MAIL FROM:<hortus@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"RCPT TO:<bob@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	var got []string
+	for _, c := range server.commands {
+		if strings.HasPrefix(c, "EHLO") {
+			continue
+		}
+		// The MAIL command may announce the body type
+		c, _, _ = strings.Cut(c, " BODY=")
+		got = append(got, c)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(server.message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := map[string]string{
+		"From":                      "hortus@example.com",
+		"To":                        "alice@example.com, bob@example.com",
+		"Date":                      "Mon, 02 Jun 2025 08:00:00 +0000",
+		"Content-Type":              "text/plain; charset=utf-8",
+		"Content-Transfer-Encoding": "quoted-printable",
+	}
+	for name, value := range headers {
+		if got := msg.Header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != n.Subject {
+		t.Errorf("subject = %q, %v, want %q", subject, err, n.Subject)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lines of the body end with CRLF, read as LF by the server
+	if string(body) != n.Body {
+		t.Errorf("body = %q, want %q", body, n.Body)
+	}
+}
+
+func TestSMTPNotifyErrors(t *testing.T) {
+	server := startSMTP(t, "bob@")
+	smtp := &SMTPNotifier{
+		Addr: server.ln.Addr().String(),
+		From: "hortus@example.com",
+		To:   []string{"alice@example.com", "bob@example.com"},
+	}
+	n := newDigest(nil, june(2, 8))
+	err := smtp.Notify(context.Background(), n)
+	if err == nil || !strings.Contains(err.Error(), "No such user") {
+		t.Errorf("error = %v, want the rejection of the recipient", err)
+	}
+	server.wait(t)
+	if server.message != "" {
+		t.Error("message sent despite a rejected recipient")
+	}
+
+	smtp.To = nil
+	err = smtp.Notify(context.Background(), n)
+	if err == nil {
+		t.Error("notified without recipient")
+	}
+
+	// Nobody listens anymore
+	server.ln.Close()
+	smtp.To = []string{"alice@example.com"}
+	err = smtp.Notify(context.Background(), n)
+	if err == nil {
+		t.Error("notified a closed server")
+	}
+}
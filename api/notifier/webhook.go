@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookNotifier sends notifications as json encoded POST requests to a URL.
+type WebhookNotifier struct {
+	URL string
+	// Client sending the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// If not empty, requests have a "X-Hortus-Signature" header holding
+	// "sha256=" followed by the hex encoded HMAC-SHA256 of the body with this
+	// secret as key, for receivers to check that the request comes from Hortus.
+	Secret string
+}
+
+// Notify posts n to the URL of h. Returns an error if the response status is
+// not 2xx.
+func (h *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		h.URL,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set(
+			"X-Hortus-Signature",
+			"sha256="+hex.EncodeToString(mac.Sum(nil)),
+		)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notifier: Webhook responded %s", resp.Status)
+	}
+	return nil
+}
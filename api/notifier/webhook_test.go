@@ -0,0 +1,119 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A request received by a test webhook.
+type webhookRequest struct {
+	method      string
+	contentType string
+	signature   string
+	body        []byte
+}
+
+// Starts a webhook answering with given status code, that sends the requests
+// it receives to the returned channel.
+func startWebhook(
+	t *testing.T,
+	status int,
+) (*httptest.Server, chan webhookRequest) {
+	t.Helper()
+	requests := make(chan webhookRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			requests <- webhookRequest{
+				r.Method,
+				r.Header.Get("Content-Type"),
+				r.Header.Get("X-Hortus-Signature"),
+				body,
+			}
+			w.WriteHeader(status)
+		},
+	))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestWebhookNotify(t *testing.T) {
+	srv, requests := startWebhook(t, http.StatusNoContent)
+	hook := &WebhookNotifier{
+		URL:    srv.URL,
+		Client: srv.Client(),
+		Secret: "s3cret",
+	}
+	now := june(2, 8)
+	n := newDigest([]plants.CareTask{task(1, june(2, 20), now)}, now)
+	err := hook.Notify(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+
+	if req.method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.method)
+	}
+	if req.contentType != "application/json" {
+		t.Errorf("content type = %q, want application/json", req.contentType)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(req.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if req.signature != want {
+		t.Errorf("signature = %q, want %q", req.signature, want)
+	}
+
+	var got Notification
+	err = json.Unmarshal(req.body, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != KindDigest ||
+		got.Subject != n.Subject ||
+		got.Body != n.Body ||
+		!got.SentAt.Equal(now) ||
+		len(got.Tasks) != 1 ||
+		got.Tasks[0].ScheduleId != 1 {
+		t.Errorf("notification = %+v, want %+v", got, n)
+	}
+}
+
+func TestWebhookNotifyUnsigned(t *testing.T) {
+	srv, requests := startWebhook(t, http.StatusOK)
+	hook := &WebhookNotifier{URL: srv.URL}
+	err := hook.Notify(context.Background(), newDigest(nil, june(2, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req.signature != "" {
+		t.Errorf("signature = %q, want none", req.signature)
+	}
+}
+
+func TestWebhookNotifyError(t *testing.T) {
+	srv, requests := startWebhook(t, http.StatusInternalServerError)
+	hook := &WebhookNotifier{URL: srv.URL, Client: srv.Client()}
+	err := hook.Notify(context.Background(), newDigest(nil, june(2, 8)))
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("error = %v, want the status of the response", err)
+	}
+	<-requests
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = hook.Notify(ctx, newDigest(nil, june(2, 8)))
+	if err == nil {
+		t.Error("notified with a done context")
+	}
+}
@@ -1,31 +1,558 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/mgmu/hortus/api/database"
 	"github.com/mgmu/hortus/api/handlers"
+	"github.com/mgmu/hortus/api/notifier"
+	"github.com/mgmu/hortus/api/storage"
+	"github.com/mgmu/hortus/internal/plants"
 	"log"
 	"net/http"
+	"net/smtp"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var dbKind = flag.String(
+	"db",
+	"postgres",
+	"storage backend to use: \"postgres\", \"sqlite\" or \"memory\"",
+)
+
+var autoMigrate = flag.Bool(
+	"migrate",
+	false,
+	"apply pending schema migrations at startup",
+)
+
+var queryTimeout = flag.Duration(
+	"query-timeout",
+	5*time.Second,
+	"maximum duration of a database query, no limit if 0",
+)
+
+var notifyInterval = flag.Duration(
+	"notify-interval",
+	15*time.Minute,
+	"duration between two checks of the care tasks to notify",
+)
+
+var digestHour = flag.Int(
+	"digest-hour",
+	8,
+	"hour of the day from which the daily digest of care tasks is sent",
+)
+
+var photoDir = flag.String(
+	"photo-dir",
+	"photos",
+	"directory where the photos of the plants are stored",
+)
+
+// Minimum length of the service token given by the environment.
+var minTokenLen = 32
+
+// Number of taxa added to the catalog in a single transaction when importing a
+// checklist.
+var taxaBatchSize = 1000
+
 func main() {
+	flag.Usage = usage
+	flag.Parse()
+
 	// Connection to database
-	db := database.PostgresDatabase{}
-	err := db.Connect()
+	ctx := context.Background()
+	db, err := newDatabase(*dbKind, *queryTimeout)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	err = db.Connect(ctx)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 	defer db.Close()
 
-	// Add API handlers
-	http.HandleFunc("/plants/", handlers.PlantsListHandler(&db))
-	http.HandleFunc("/plants/new/", handlers.NewPlantHandler(&db))
-	http.HandleFunc("/plants/{id}/", handlers.PlantInfoHandler(&db))
-	http.HandleFunc("/plants/log/{id}/", handlers.NewPlantLogHandler(&db))
+	if flag.NArg() > 0 {
+		err = runCommand(ctx, db, flag.Args())
+		if err != nil {
+			db.Close()
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
+	// Check that the schema is the one expected by this binary
+	if m, ok := db.(database.Migrator); ok {
+		if *autoMigrate {
+			_, err = m.MigrateUp(ctx)
+		} else {
+			err = database.CheckMigrations(ctx, m)
+		}
+		if err != nil {
+			db.Close()
+			log.Fatal(err.Error())
+		}
+	}
 
-	// Start server
-	err = http.ListenAndServe(":8080", nil)
+	// Accept the service token given by the environment
+	err = addServiceToken(ctx, db, os.Getenv("HORTUS_API_TOKEN"))
+	if err != nil {
+		db.Close()
+		log.Fatal(err.Error())
+	}
+
+	// Send care reminders in the background
+	notifiers, err := newNotifiers()
+	if err != nil {
+		db.Close()
+		log.Fatal(err.Error())
+	}
+	if len(notifiers) > 0 {
+		household, err := notifyHousehold(ctx, db)
+		if err != nil {
+			db.Close()
+			log.Fatal(err.Error())
+		}
+		scheduler := &notifier.Scheduler{
+			Tasks:       db,
+			Notifiers:   notifiers,
+			HouseholdId: household,
+			Interval:    *notifyInterval,
+			DigestHour:  *digestHour,
+			Timeout:     time.Minute,
+		}
+		go scheduler.Run(ctx)
+	}
+
+	// Add API handlers. The handlers of a plant are only reachable by the
+	// members of its household.
+	store := &storage.FileStore{Dir: *photoDir}
+	http.HandleFunc("/plants/", handlers.PlantsListHandler(db))
+	http.HandleFunc("/plants/new/", handlers.NewPlantHandler(db))
+	http.HandleFunc("/plants/export/", handlers.ExportPlantsHandler(db))
+	http.HandleFunc("/plants/import/", handlers.ImportPlantsHandler(db))
+	http.HandleFunc("/plants/export/logs/", handlers.ExportLogsHandler(db))
+	http.HandleFunc(
+		"/plants/{id}/",
+		handlers.PlantAccess(db, handlers.PlantInfoHandler(db, store)),
+	)
+	http.HandleFunc(
+		"/plants/log/{id}/",
+		handlers.PlantAccess(db, handlers.NewPlantLogHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/log/{id}/{logId}/",
+		handlers.PlantAccess(db, handlers.PlantLogHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/schedules/{id}/",
+		handlers.PlantAccess(db, handlers.CareSchedulesHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/schedules/{id}/{scheduleId}/",
+		handlers.PlantAccess(db, handlers.CareScheduleHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/move/{id}/",
+		handlers.PlantAccess(db, handlers.MovePlantHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/tags/{id}/",
+		handlers.PlantAccess(db, handlers.PlantTagsHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/tags/{id}/{tag}/",
+		handlers.PlantAccess(db, handlers.PlantTagHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/tasks/{id}/",
+		handlers.PlantAccess(db, handlers.PlantTasksHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/tasks/{id}/{scheduleId}/",
+		handlers.PlantAccess(db, handlers.CompleteTaskHandler(db)),
+	)
+	http.HandleFunc(
+		"/plants/photos/{id}/",
+		handlers.PlantAccess(db, handlers.PhotosHandler(db, store)),
+	)
+	http.HandleFunc(
+		"/plants/photos/{id}/{photoId}/",
+		handlers.PlantAccess(db, handlers.PhotoHandler(db, store)),
+	)
+	http.HandleFunc(
+		"/plants/photos/{id}/{photoId}/thumbnail/",
+		handlers.PlantAccess(db, handlers.ThumbnailHandler(db, store)),
+	)
+	http.HandleFunc("/tasks/", handlers.TasksHandler(db))
+	http.HandleFunc("/events/", handlers.EventTypesHandler())
+	http.HandleFunc("/search/", handlers.SearchHandler(db))
+	http.HandleFunc("/tags/", handlers.TagsHandler(db))
+	http.HandleFunc("/taxa/", handlers.TaxaHandler(db))
+	http.HandleFunc("/taxa/{id}/", handlers.TaxonHandler(db))
+	http.HandleFunc("/taxa/names/", handlers.TaxonNamesHandler(db))
+	http.HandleFunc("/taxa/check/", handlers.CheckTaxonHandler(db))
+	http.HandleFunc("/users/", handlers.ServiceOnly(handlers.UsersHandler(db)))
+	http.HandleFunc("/users/{id}/", handlers.UserHandler(db))
+	http.HandleFunc(
+		"/users/login/",
+		handlers.ServiceOnly(handlers.LoginHandler(db)),
+	)
+	http.HandleFunc("/tokens/", handlers.TokensHandler(db))
+	http.HandleFunc("/tokens/{id}/", handlers.TokenHandler(db))
+	http.HandleFunc("/households/", handlers.HouseholdsHandler(db))
+	http.HandleFunc("/households/{id}/", handlers.HouseholdHandler(db))
+	http.HandleFunc(
+		"/households/{id}/members/{userId}/",
+		handlers.MemberHandler(db),
+	)
+	http.HandleFunc(
+		"/households/{id}/invitations/",
+		handlers.InvitationsHandler(db),
+	)
+	http.HandleFunc(
+		"/households/{id}/invitations/{invitationId}/",
+		handlers.InvitationHandler(db),
+	)
+	http.HandleFunc("/invitations/{token}/", handlers.JoinHandler(db))
+	http.HandleFunc("/locations/", handlers.LocationsHandler(db))
+	http.HandleFunc("/locations/{id}/", handlers.LocationHandler(db))
+
+	// Start server, all the requests must have a token
+	err = http.ListenAndServe(
+		":8080",
+		handlers.Authenticate(db, http.DefaultServeMux),
+	)
 	fmt.Fprintf(os.Stderr, "ListenAndServe: %v\n", err)
 	os.Exit(1)
 }
+
+// Returns the database implementation matching kind, whose queries last at most
+// timeout.
+func newDatabase(kind string, timeout time.Duration) (database.Database, error) {
+	switch kind {
+	case "postgres":
+		return &database.PostgresDatabase{QueryTimeout: timeout}, nil
+	case "sqlite":
+		return &database.SQLiteDatabase{QueryTimeout: timeout}, nil
+	case "memory":
+		return &database.MemoryDatabase{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown database kind %q", kind)
+	}
+}
+
+// Returns the notifiers configured by the environment:
+//   - email, if HORTUS_SMTP_ADDR is set, from HORTUS_SMTP_FROM to the comma
+//     separated addresses of HORTUS_SMTP_TO, authenticated with
+//     HORTUS_SMTP_USERNAME and HORTUS_SMTP_PASSWORD if set
+//   - webhook, if HORTUS_WEBHOOK_URL is set, signed with HORTUS_WEBHOOK_SECRET
+//     if set
+//
+// Both receive the tasks of a single household, see notifyHousehold.
+func newNotifiers() ([]notifier.Notifier, error) {
+	var notifiers []notifier.Notifier
+	if addr := os.Getenv("HORTUS_SMTP_ADDR"); addr != "" {
+		mail := &notifier.SMTPNotifier{
+			Addr: addr,
+			From: os.Getenv("HORTUS_SMTP_FROM"),
+		}
+		for _, to := range strings.Split(os.Getenv("HORTUS_SMTP_TO"), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				mail.To = append(mail.To, to)
+			}
+		}
+		if mail.From == "" || len(mail.To) == 0 {
+			return nil, errors.New(
+				"HORTUS_SMTP_FROM and HORTUS_SMTP_TO are required with HORTUS_SMTP_ADDR",
+			)
+		}
+		if user := os.Getenv("HORTUS_SMTP_USERNAME"); user != "" {
+			host, _, _ := strings.Cut(addr, ":")
+			password := os.Getenv("HORTUS_SMTP_PASSWORD")
+			mail.Auth = smtp.PlainAuth("", user, password, host)
+		}
+		notifiers = append(notifiers, mail)
+	}
+	if url := os.Getenv("HORTUS_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, &notifier.WebhookNotifier{
+			URL:    url,
+			Secret: os.Getenv("HORTUS_WEBHOOK_SECRET"),
+		})
+	}
+	return notifiers, nil
+}
+
+// Returns the identifier of the household whose care tasks are notified, given
+// by HORTUS_NOTIFY_HOUSEHOLD, which must exist in db. If it is not set, only
+// the tasks of the plants of no household are notified.
+func notifyHousehold(ctx context.Context, db database.Database) (int, error) {
+	s := os.Getenv("HORTUS_NOTIFY_HOUSEHOLD")
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, errors.New(
+			"HORTUS_NOTIFY_HOUSEHOLD is not a valid household identifier",
+		)
+	}
+	_, err = db.GetHousehold(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("HORTUS_NOTIFY_HOUSEHOLD: %w", err)
+	}
+	return id, nil
+}
+
+// Prints the usage of the server to the standard error.
+func usage() {
+	fmt.Fprintf(
+		os.Stderr,
+		"Usage: %[1]s [flags]\n"+
+			"       %[1]s [flags] migrate up|down|status\n"+
+			"       %[1]s [flags] token create NAME read|write [USERNAME]\n"+
+			"       %[1]s [flags] token list\n"+
+			"       %[1]s [flags] token revoke ID\n"+
+			"       %[1]s [flags] taxa import FILE\n"+
+			"       %[1]s [flags] taxa check\n",
+		os.Args[0],
+	)
+	flag.PrintDefaults()
+}
+
+// Runs the command given on the command line instead of starting the server.
+func runCommand(ctx context.Context, db database.Database, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, db, args[1:])
+	case "token":
+		return runToken(ctx, db, args[1:])
+	case "taxa":
+		return runTaxa(ctx, db, args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+// Runs the "migrate" command, applying or reverting the migrations of the
+// database.
+func runMigrate(ctx context.Context, db database.Database, args []string) error {
+	if len(args) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	m, ok := db.(database.Migrator)
+	if !ok {
+		return fmt.Errorf("Database %q has no migrations", *dbKind)
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := m.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migration(s) applied\n", n)
+	case "down":
+		reverted, err := m.MigrateDown(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted {
+			fmt.Println("1 migration reverted")
+		} else {
+			fmt.Println("No migration to revert")
+		}
+	case "status":
+		status, err := m.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+// Runs the "token" command, issuing, listing or revoking the tokens of the API.
+// Tokens issued without username are service tokens.
+func runToken(ctx context.Context, db database.Database, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch {
+	case args[0] == "create" && (len(args) == 3 || len(args) == 4):
+		var t plants.Token
+		var err error
+		t.Name, t.Scope, err = handlers.ParseToken(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if len(args) == 4 {
+			user, _, err := db.GetUserByName(ctx, args[3])
+			if err != nil {
+				return err
+			}
+			t.UserId = user.Id
+		}
+		t, secret, err := handlers.IssueToken(ctx, db, t)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Token %d: %s\n", t.Id, secret)
+	case args[0] == "list" && len(args) == 1:
+		tokens, err := db.GetTokens(ctx, 0)
+		if err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			owner := "service"
+			if t.UserId != 0 {
+				owner = "user " + strconv.Itoa(t.UserId)
+			}
+			status := "active"
+			if !t.RevokedAt.IsZero() {
+				status = "revoked " + t.RevokedAt.Format(time.DateTime)
+			}
+			fmt.Printf(
+				"%d\t%s\t%s\t%s\t%s\n",
+				t.Id,
+				t.Name,
+				owner,
+				t.Scope,
+				status,
+			)
+		}
+	case args[0] == "revoke" && len(args) == 2:
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		err = db.RevokeToken(ctx, 0, id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Token %d revoked\n", id)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+// Runs the "taxa" command, importing the taxa of a checklist file into the
+// catalog, see plants.ReadChecklist, or checking the taxa of the catalog. The
+// taxa are added by batches of taxaBatchSize, each in its own transaction.
+func runTaxa(ctx context.Context, db database.Database, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "import":
+		return importTaxa(ctx, db, args[1])
+	case len(args) == 1 && args[0] == "check":
+		return checkTaxa(ctx, db)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+// Imports the taxa of the checklist file of given name, see runTaxa.
+func importTaxa(ctx context.Context, db database.Database, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var batch []plants.Taxon
+	read, added := 0, 0
+	addBatch := func() error {
+		n, err := db.AddTaxa(ctx, batch)
+		added += n
+		batch = batch[:0]
+		return err
+	}
+	skipped, err := plants.ReadChecklist(f, func(t plants.Taxon) error {
+		read++
+		batch = append(batch, t)
+		if len(batch) < taxaBatchSize {
+			return nil
+		}
+		return addBatch()
+	})
+	if err == nil && len(batch) > 0 {
+		err = addBatch()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf(
+		"%d taxa read, %d added, %d rows skipped\n",
+		read,
+		added,
+		skipped,
+	)
+	return nil
+}
+
+// Prints the taxa of the catalog that break the rules of botanical nomenclature,
+// see plants.NormalizeTaxon, such as the ones named before the rules were
+// enforced. The plants keep them until their names are changed.
+func checkTaxa(ctx context.Context, db database.Database) error {
+	taxa, err := db.GetTaxa(ctx, database.TaxonQuery{})
+	if err != nil {
+		return err
+	}
+	invalid := 0
+	for _, t := range taxa {
+		_, err := plants.NormalizeTaxon(t)
+		if err != nil {
+			invalid++
+			fmt.Printf("%d\t%s\t%v\n", t.Id, t.Name(), err)
+		}
+	}
+	fmt.Printf("%d taxa checked, %d invalid\n", len(taxa), invalid)
+	return nil
+}
+
+// Stores secret as a read-write service token named "web", if it is not empty
+// and not already stored. The token is not stored again once revoked.
+func addServiceToken(
+	ctx context.Context,
+	db database.Database,
+	secret string,
+) error {
+	if secret == "" {
+		return nil
+	}
+	if len(secret) < minTokenLen {
+		return fmt.Errorf(
+			"HORTUS_API_TOKEN must be at least %d characters long",
+			minTokenLen,
+		)
+	}
+	t := plants.Token{Name: "web", Scope: plants.ScopeWrite}
+	_, err := db.AddToken(ctx, t, handlers.HashToken(secret))
+	if errors.Is(err, database.ErrConflict) {
+		return nil
+	}
+	return err
+}
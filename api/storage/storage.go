@@ -0,0 +1,39 @@
+// Package storage stores binary objects, such as photos, outside of the
+// database.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a blob does not exist.
+var ErrNotFound = errors.New("storage: Blob not found")
+
+// BlobStore stores blobs by key. Keys are slash separated paths of letters,
+// numbers, dashes and underscores, see NewKey. Operations stop when their
+// context is done.
+type BlobStore interface {
+	// Put stores the content of r under key, replacing any previous blob, and
+	// returns its size.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Get returns a reader of the blob of given key, to be closed by the
+	// caller.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob of given key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewKey returns a new random key, prefixed by prefix and a slash.
+func NewKey(prefix string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return prefix + "/" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,92 @@
+package plants
+
+import "time"
+
+// CareSchedule is a recurring care of a plant: an event of given type to repeat
+// every IntervalDays days, from the month SeasonStart to the month SeasonEnd
+// included. A season ending before it starts spans the new year, e.g. from
+// November to February. A schedule applying all year long is from January to
+// December.
+type CareSchedule struct {
+	Id           int        `json:"id"`
+	PlantId      int        `json:"plant_id"`
+	EventType    EventType  `json:"event_type"`
+	IntervalDays int        `json:"interval_days"`
+	SeasonStart  time.Month `json:"season_start"`
+	SeasonEnd    time.Month `json:"season_end"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CareTask is the next occurrence of the care schedule of identifier
+// ScheduleId. HouseholdId is the household owning the plant, 0 if none.
+// LastDone is the time of the latest log entry of the plant of the event type
+// of the schedule, zero if there is none. The task is due on the day of DueAt,
+// and is overdue if that day is over.
+type CareTask struct {
+	ScheduleId  int       `json:"schedule_id"`
+	PlantId     int       `json:"plant_id"`
+	HouseholdId int       `json:"household_id"`
+	CommonName  string    `json:"common_name"`
+	EventType   EventType `json:"event_type"`
+	LastDone    time.Time `json:"last_done,omitzero"`
+	DueAt       time.Time `json:"due_at"`
+	Overdue     bool      `json:"overdue"`
+}
+
+// Valid reports whether the interval and the season of s are valid and its
+// event type is known.
+func (s CareSchedule) Valid() bool {
+	return s.EventType.Valid() &&
+		s.IntervalDays > 0 &&
+		s.SeasonStart >= time.January && s.SeasonStart <= time.December &&
+		s.SeasonEnd >= time.January && s.SeasonEnd <= time.December
+}
+
+// InSeason reports whether s applies during the month of t.
+func (s CareSchedule) InSeason(t time.Time) bool {
+	m := t.Month()
+	if s.SeasonStart <= s.SeasonEnd {
+		return s.SeasonStart <= m && m <= s.SeasonEnd
+	}
+	return m >= s.SeasonStart || m <= s.SeasonEnd
+}
+
+// NextDue returns the start of the day, in loc, at which the care of s is due
+// after having been done at lastDone. A care that was never done, lastDone
+// being zero, is due the day s was created. A care falling out of season is due
+// the first day of the next season.
+func (s CareSchedule) NextDue(lastDone time.Time, loc *time.Location) time.Time {
+	due := s.CreatedAt
+	if !lastDone.IsZero() {
+		due = lastDone.AddDate(0, 0, s.IntervalDays)
+	}
+	due = startOfDay(due.In(loc))
+	if !s.InSeason(due) {
+		year := due.Year()
+		if due.Month() > s.SeasonStart {
+			year++
+		}
+		due = time.Date(year, s.SeasonStart, 1, 0, 0, 0, 0, loc)
+	}
+	return due
+}
+
+// Task returns the next occurrence of s, for the plant of given common name,
+// as of now.
+func (s CareSchedule) Task(commonName string, lastDone, now time.Time) CareTask {
+	due := s.NextDue(lastDone, now.Location())
+	return CareTask{
+		ScheduleId: s.Id,
+		PlantId:    s.PlantId,
+		CommonName: commonName,
+		EventType:  s.EventType,
+		LastDone:   lastDone,
+		DueAt:      due,
+		Overdue:    due.Before(startOfDay(now)),
+	}
+}
+
+// Returns the time at which the day of t starts, in the location of t.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
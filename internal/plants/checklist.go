@@ -0,0 +1,133 @@
+package plants
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Columns of a Darwin Core checklist holding the parts of the names of the
+// taxa, in lower case, by part. The first column found is used.
+var checklistColumns = map[string][]string{
+	"family":       {"family"},
+	"genus":        {"genus", "genericname"},
+	"species":      {"specificepithet"},
+	"rank":         {"taxonrank", "verbatimtaxonrank"},
+	"infraspecies": {"infraspecificepithet"},
+	"cultivar":     {"cultivarepithet"},
+}
+
+// Ranks of the rows of a checklist that are imported, with the parts of the
+// names of their taxa that are kept, in lower case. An empty rank keeps all the
+// parts. Infraspecific ranks are read by ParseInfraRank.
+var checklistRanks = map[string]struct{ species, infra bool }{
+	"":         {true, true},
+	"genus":    {false, false},
+	"species":  {true, false},
+	"cultivar": {true, true},
+}
+
+// ReadChecklist reads the taxa of a tab-separated checklist, such as the Taxon
+// file of a Darwin Core archive, and calls fn with each of them, normalized by
+// NormalizeTaxon, in the order of the file. The first line names the columns,
+// among which "genus" or "genericName" is required, and "family",
+// "specificEpithet", "taxonRank", "infraspecificEpithet" and "cultivarEpithet"
+// are read if present. Rows of ranks above the genus, or whose taxon is not
+// valid, such as infraspecific names without rank, are skipped. Returns the
+// number of rows skipped, and the first error of fn, which stops the reading.
+func ReadChecklist(r io.Reader, fn func(Taxon) error) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("Checklist is empty")
+	}
+
+	header := strings.Split(
+		strings.TrimPrefix(strings.TrimRight(scanner.Text(), "\r"), "\ufeff"),
+		"\t",
+	)
+	columns := make(map[string]int)
+	for part, names := range checklistColumns {
+		for _, name := range names {
+			i := indexFold(header, name)
+			if i >= 0 {
+				columns[part] = i
+				break
+			}
+		}
+	}
+	if _, ok := columns["genus"]; !ok {
+		return 0, errors.New("Checklist has no genus column")
+	}
+
+	skipped := 0
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		field := func(part string) string {
+			i, ok := columns[part]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return fields[i]
+		}
+
+		t, ok := checklistTaxon(field)
+		if !ok {
+			skipped++
+			continue
+		}
+		if err := fn(t); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, scanner.Err()
+}
+
+// Returns the taxon of a row of a checklist whose parts are given by field, see
+// checklistColumns, and whether the row is imported.
+func checklistTaxon(field func(part string) string) (Taxon, bool) {
+	t := Taxon{
+		Family:   field("family"),
+		Genus:    field("genus"),
+		Cultivar: field("cultivar"),
+	}
+	rank := strings.ToLower(strings.TrimSpace(field("rank")))
+	keep, ok := checklistRanks[rank]
+	if !ok {
+		// Infraspecific ranks, the other ones are above the genus
+		r, err := ParseInfraRank(rank)
+		if err != nil {
+			return Taxon{}, false
+		}
+		keep.species, keep.infra = true, true
+		t.Rank = r
+	}
+	if keep.species {
+		t.Species = field("species")
+	}
+	if keep.infra {
+		t.Infraspecies = field("infraspecies")
+	}
+
+	t, err := NormalizeTaxon(t)
+	return t, err == nil
+}
+
+// Returns the index of the first string of s equal to name, ignoring case and
+// surrounding spaces, or -1 if there is none.
+func indexFold(s []string, name string) int {
+	for i, v := range s {
+		if strings.EqualFold(strings.TrimSpace(v), name) {
+			return i
+		}
+	}
+	return -1
+}
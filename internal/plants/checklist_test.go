@@ -0,0 +1,168 @@
+package plants
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Reads the checklist s, and returns its taxa and the number of rows skipped.
+func readChecklist(t *testing.T, s string) ([]Taxon, int) {
+	t.Helper()
+	var taxa []Taxon
+	skipped, err := ReadChecklist(strings.NewReader(s), func(t Taxon) error {
+		taxa = append(taxa, t)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return taxa, skipped
+}
+
+func TestReadChecklistColumns(t *testing.T) {
+	tests := []struct {
+		checklist string
+		want      []Taxon
+	}{
+		{
+			"taxonID\tfamily\tgenus\tspecificEpithet\n" +
+				"1\taraceae\tmonstera\tdeliciosa\n",
+			[]Taxon{{
+				Family:  "Araceae",
+				Genus:   "Monstera",
+				Species: "deliciosa",
+			}},
+		},
+		// Aliases, names in any case surrounded by spaces
+		{
+			" GenericName \tSPECIFICEPITHET\tVerbatimTaxonRank\t" +
+				"InfraspecificEpithet\tcultivarEpithet\n" +
+				"Monstera\tdeliciosa\tvar.\tborsigiana\tAlbo Variegata\n",
+			[]Taxon{{
+				Genus:        "Monstera",
+				Species:      "deliciosa",
+				Rank:         RankVariety,
+				Infraspecies: "borsigiana",
+				Cultivar:     "Albo Variegata",
+			}},
+		},
+		// The first column found is used
+		{
+			"genericName\tgenus\ttaxonRank\tverbatimTaxonRank\t" +
+				"specificEpithet\tinfraspecificEpithet\n" +
+				"Ficus\tMonstera\tsubspecies\tvariety\tdeliciosa\tborsigiana\n",
+			[]Taxon{{
+				Genus:        "Monstera",
+				Species:      "deliciosa",
+				Rank:         RankSubspecies,
+				Infraspecies: "borsigiana",
+			}},
+		},
+		// Byte order mark, carriage returns, blank and short lines
+		{
+			"\ufeffgenus\tspecificEpithet\r\n" +
+				"Monstera\tdeliciosa\r\n" +
+				"\r\n" +
+				"Ficus\r\n",
+			[]Taxon{
+				{Genus: "Monstera", Species: "deliciosa"},
+				{Genus: "Ficus"},
+			},
+		},
+	}
+	for _, test := range tests {
+		got, skipped := readChecklist(t, test.checklist)
+		if !slices.Equal(got, test.want) || skipped != 0 {
+			t.Errorf(
+				"%q: taxa = %+v, %d skipped, want %+v",
+				test.checklist,
+				got,
+				skipped,
+				test.want,
+			)
+		}
+	}
+}
+
+func TestReadChecklistRanks(t *testing.T) {
+	checklist := "genus\tspecificEpithet\ttaxonRank\tinfraspecificEpithet\n" +
+		"\t\tfamily\t\n" +
+		"Monstera\t\tgenus\t\n" +
+		"Monstera\tdeliciosa\tgenus\t\n" +
+		"Monstera\tdeliciosa\tspecies\tborsigiana\n" +
+		"Monstera\tdeliciosa\tvariety\tborsigiana\n" +
+		"Monstera\tdeliciosa\tf.\tborsigiana\n" +
+		"Monstera\tdeliciosa\tcultivar\t\n" +
+		"Monstera\tdeliciosa\t\tborsigiana\n" +
+		"Araceae\t\torder\t\n" +
+		"Monstera\t\tvar.\tborsigiana\n" +
+		"monstera2\tdeliciosa\tspecies\t\n"
+	want := []Taxon{
+		{Genus: "Monstera"},
+		// The parts below the rank are not kept
+		{Genus: "Monstera"},
+		{Genus: "Monstera", Species: "deliciosa"},
+		{
+			Genus:        "Monstera",
+			Species:      "deliciosa",
+			Rank:         RankVariety,
+			Infraspecies: "borsigiana",
+		},
+		{
+			Genus:        "Monstera",
+			Species:      "deliciosa",
+			Rank:         RankForm,
+			Infraspecies: "borsigiana",
+		},
+		{Genus: "Monstera", Species: "deliciosa"},
+	}
+	got, skipped := readChecklist(t, checklist)
+	if !slices.Equal(got, want) {
+		t.Errorf("taxa = %+v, want %+v", got, want)
+	}
+	// Ranks above the genus, and rows whose taxon is not valid
+	if skipped != 5 {
+		t.Errorf("skipped = %d, want 5", skipped)
+	}
+}
+
+func TestReadChecklistErrors(t *testing.T) {
+	tests := []struct {
+		checklist string
+		want      string
+	}{
+		{"", "Checklist is empty"},
+		{
+			"family\tspecificEpithet\nAraceae\tdeliciosa\n",
+			"Checklist has no genus column",
+		},
+	}
+	for _, test := range tests {
+		_, err := ReadChecklist(
+			strings.NewReader(test.checklist),
+			func(Taxon) error { return nil },
+		)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: error = %v, want %q", test.checklist, err, test.want)
+		}
+	}
+
+	// The error of the callback stops the reading
+	stop := errors.New("stop")
+	var taxa []Taxon
+	skipped, err := ReadChecklist(
+		strings.NewReader("genus\nficus2\nFicus\nMonstera\n"),
+		func(t Taxon) error {
+			taxa = append(taxa, t)
+			return stop
+		},
+	)
+	if err != stop {
+		t.Errorf("error = %v, want %v", err, stop)
+	}
+	if len(taxa) != 1 || taxa[0].Genus != "Ficus" || skipped != 1 {
+		t.Errorf("read %+v, %d skipped, want Ficus, 1 skipped", taxa, skipped)
+	}
+}
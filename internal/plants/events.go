@@ -0,0 +1,88 @@
+package plants
+
+import "fmt"
+
+// EventType is the kind of care event recorded by a plant log. Event types are
+// encoded by their name in JSON.
+type EventType int
+
+// Event types known to Hortus. EventNote is the zero value, free-form entries
+// logged before event types existed are notes.
+const (
+	EventNote EventType = iota
+	EventWatering
+	EventFertilizing
+	EventRepotting
+	EventPruning
+	EventPestTreatment
+	EventFlowering
+	EventHarvest
+	EventPropagation
+	EventMisting
+	EventMove
+)
+
+// Names of the event types, indexed by event type.
+var eventTypeNames = []string{
+	EventNote:          "note",
+	EventWatering:      "watering",
+	EventFertilizing:   "fertilizing",
+	EventRepotting:     "repotting",
+	EventPruning:       "pruning",
+	EventPestTreatment: "pest_treatment",
+	EventFlowering:     "flowering",
+	EventHarvest:       "harvest",
+	EventPropagation:   "propagation",
+	EventMisting:       "misting",
+	EventMove:          "move",
+}
+
+// EventTypes returns all the event types, in the order of their values.
+func EventTypes() []EventType {
+	types := make([]EventType, len(eventTypeNames))
+	for i := range eventTypeNames {
+		types[i] = EventType(i)
+	}
+	return types
+}
+
+// ParseEventType returns the event type of given name.
+func ParseEventType(name string) (EventType, error) {
+	for i, n := range eventTypeNames {
+		if n == name {
+			return EventType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown event type %q", name)
+}
+
+// Valid reports whether e is one of the known event types.
+func (e EventType) Valid() bool {
+	return e >= 0 && int(e) < len(eventTypeNames)
+}
+
+// String returns the name of e.
+func (e EventType) String() string {
+	if !e.Valid() {
+		return fmt.Sprintf("EventType(%d)", int(e))
+	}
+	return eventTypeNames[e]
+}
+
+// MarshalText encodes e as its name. Returns an error if e is not valid.
+func (e EventType) MarshalText() ([]byte, error) {
+	if !e.Valid() {
+		return nil, fmt.Errorf("Unknown event type %d", int(e))
+	}
+	return []byte(eventTypeNames[e]), nil
+}
+
+// UnmarshalText decodes an event type from its name.
+func (e *EventType) UnmarshalText(text []byte) error {
+	t, err := ParseEventType(string(text))
+	if err != nil {
+		return err
+	}
+	*e = t
+	return nil
+}
@@ -0,0 +1,65 @@
+package plants
+
+import (
+	"fmt"
+	"time"
+)
+
+// Role of a member of a household, the actions it allows on the plants of the
+// household.
+type Role string
+
+const (
+	// Manages the household, its members and its invitations, and cares for
+	// its plants.
+	RoleOwner Role = "owner"
+	// Adds, edits and cares for the plants of the household.
+	RoleCaretaker Role = "caretaker"
+	// Only sees the plants of the household.
+	RoleViewer Role = "viewer"
+)
+
+// ParseRole returns the role of given name.
+func ParseRole(name string) (Role, error) {
+	switch r := Role(name); r {
+	case RoleOwner, RoleCaretaker, RoleViewer:
+		return r, nil
+	default:
+		return "", fmt.Errorf("Unknown role %q", name)
+	}
+}
+
+// CanEdit reports whether r allows adding and modifying plants.
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleCaretaker
+}
+
+// Household is a garden shared by its members, which owns plants. Role is the
+// role of the user who requested the household, and Members are only set when
+// a single household is requested.
+type Household struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Members   []Member  `json:"members,omitempty"`
+}
+
+// Member is a user belonging to a household, with a role.
+type Member struct {
+	UserId   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     Role      `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Invitation to join a household with a role. Invitations are used once, and
+// expire at ExpiresAt. The link of the invitation is only known by its holder.
+type Invitation struct {
+	Id            int       `json:"id"`
+	HouseholdId   int       `json:"household_id"`
+	HouseholdName string    `json:"household_name"`
+	Role          Role      `json:"role"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
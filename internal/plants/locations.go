@@ -0,0 +1,72 @@
+package plants
+
+import (
+	"fmt"
+	"slices"
+)
+
+// LocationKind is the level of a location in the hierarchy of the locations of
+// a household: sites contain rooms and beds, which contain shelves.
+type LocationKind string
+
+const (
+	// A house, a garden or any place of its own.
+	LocationSite LocationKind = "site"
+	// A room of a site.
+	LocationRoom LocationKind = "room"
+	// A garden bed of a site.
+	LocationBed LocationKind = "bed"
+	// A shelf of a room or of a bed.
+	LocationShelf LocationKind = "shelf"
+)
+
+// Kinds of the locations that can contain a location of each kind, none for
+// sites.
+var locationParents = map[LocationKind][]LocationKind{
+	LocationSite:  nil,
+	LocationRoom:  {LocationSite},
+	LocationBed:   {LocationSite},
+	LocationShelf: {LocationRoom, LocationBed},
+}
+
+// LocationKinds returns all the location kinds, from the top of the hierarchy.
+func LocationKinds() []LocationKind {
+	return []LocationKind{
+		LocationSite,
+		LocationRoom,
+		LocationBed,
+		LocationShelf,
+	}
+}
+
+// ParseLocationKind returns the location kind of given name.
+func ParseLocationKind(name string) (LocationKind, error) {
+	k := LocationKind(name)
+	if _, ok := locationParents[k]; !ok {
+		return "", fmt.Errorf("Unknown location kind %q", name)
+	}
+	return k, nil
+}
+
+// IsTop reports whether the locations of kind k have no parent location.
+func (k LocationKind) IsTop() bool {
+	return len(locationParents[k]) == 0
+}
+
+// CanContain reports whether a location of kind k can contain a location of
+// kind child.
+func (k LocationKind) CanContain(child LocationKind) bool {
+	return slices.Contains(locationParents[child], k)
+}
+
+// Location is a place of a household where plants live. Locations other than
+// sites are in a parent location, of identifier ParentId. Path is the names of
+// the location and of its ancestors, from the site down, separated by " / ".
+type Location struct {
+	Id          int          `json:"id"`
+	HouseholdId int          `json:"household_id"`
+	ParentId    int          `json:"parent_id,omitempty"`
+	Name        string       `json:"name"`
+	Kind        LocationKind `json:"kind"`
+	Path        string       `json:"path"`
+}
@@ -0,0 +1,171 @@
+package plants
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HybridMarker is the multiplication sign written before the names of hybrid
+// genera and species, without space, such as "×Fatshedera" or
+// "Salix ×sepulcralis".
+const HybridMarker = "×"
+
+// Family names that do not end with "-aceae", conserved by the botanical code
+// alongside their alternative in "-aceae".
+var conservedFamilies = []string{
+	"Compositae",
+	"Cruciferae",
+	"Gramineae",
+	"Guttiferae",
+	"Labiatae",
+	"Leguminosae",
+	"Palmae",
+	"Umbelliferae",
+}
+
+// Letters of botanical names besides the ones of the Latin alphabet: the
+// diaeresis marks that a vowel is pronounced separately, as in "Isoëtes".
+const diaeresisLetters = "äëïöü"
+
+// NamePart is a part of a botanical name. The Latin names are printed in
+// italics, the ranks and the cultivars are not.
+type NamePart struct {
+	Text   string
+	Italic bool
+}
+
+// NameParts returns the parts of the botanical name of t, see Name, such as
+// "Monstera deliciosa", "var.", "borsigiana" and "'Albo Variegata'". Successive
+// italic names form a single part.
+func (t Taxon) NameParts() []NamePart {
+	parts := []NamePart{{t.Genus, true}}
+	if t.Species != "" {
+		parts[0].Text += " " + t.Species
+	}
+	if t.Rank != RankNone {
+		parts = append(
+			parts,
+			NamePart{string(t.Rank), false},
+			NamePart{t.Infraspecies, true},
+		)
+	}
+	if t.Cultivar != "" {
+		parts = append(parts, NamePart{"'" + t.Cultivar + "'", false})
+	}
+	return parts
+}
+
+// Returns s without its hybrid marker, and whether it has one. The marker is
+// either HybridMarker, or the letter x followed by a space, as typed when the
+// multiplication sign is not at hand.
+func cutHybridMarker(s string) (string, bool) {
+	if rest, ok := strings.CutPrefix(s, HybridMarker); ok {
+		return strings.TrimSpace(rest), true
+	}
+	if len(s) > 2 && (s[0] == 'x' || s[0] == 'X') && s[1] == ' ' {
+		return strings.TrimSpace(s[2:]), true
+	}
+	return s, false
+}
+
+// Returns the Latin name s, spelled by spell, with its hybrid marker, if any,
+// written as HybridMarker.
+func spellHybrid(s string, spell func(string) string) string {
+	s, hybrid := cutHybridMarker(s)
+	s = spell(s)
+	if hybrid {
+		return HybridMarker + s
+	}
+	return s
+}
+
+// Checks the parts of the name of the normalized taxon t against the rules of
+// botanical nomenclature.
+func checkNomenclature(t Taxon) error {
+	if t.Family != "" && (!isLatinWord(t.Family) ||
+		!strings.HasSuffix(t.Family, "aceae") &&
+			!slices.Contains(conservedFamilies, t.Family)) {
+		return errors.New(
+			"Family must be a Latin name ending with -aceae, such as Araceae",
+		)
+	}
+	genus, _ := strings.CutPrefix(t.Genus, HybridMarker)
+	if !isLatinWord(genus) {
+		return errors.New(
+			"Genus must be a capitalized Latin name, such as Monstera",
+		)
+	}
+	species, _ := strings.CutPrefix(t.Species, HybridMarker)
+	if t.Species != "" && !isEpithet(species) {
+		return errors.New(
+			"Species must be a Latin epithet in lower case, such as deliciosa",
+		)
+	}
+	if t.Infraspecies != "" && !isEpithet(t.Infraspecies) {
+		return errors.New(
+			"Infraspecific name must be a Latin epithet in lower case",
+		)
+	}
+	if t.Cultivar != "" && !isCultivar(t.Cultivar) {
+		return errors.New(
+			"Cultivar must only contain letters, digits, spaces, dashes, " +
+				"dots and apostrophes",
+		)
+	}
+	return nil
+}
+
+// Reports whether s is a capitalized Latin word of at least two letters, such
+// as a genus or a family name.
+func isLatinWord(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	if r < 'A' || r > 'Z' || len(s) == size {
+		return false
+	}
+	for _, r := range s[size:] {
+		if !isLatinLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether s is a specific or infraspecific epithet: a Latin word in
+// lower case of at least two letters, whose parts may be joined by dashes, such
+// as "uva-ursi".
+func isEpithet(s string) bool {
+	if utf8.RuneCountInString(s) < 2 {
+		return false
+	}
+	for _, word := range strings.Split(s, "-") {
+		if word == "" {
+			return false
+		}
+		for _, r := range word {
+			if !isLatinLetter(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Reports whether r is a lower case letter of a botanical name.
+func isLatinLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || strings.ContainsRune(diaeresisLetters, r)
+}
+
+// Reports whether s is a cultivar epithet, without its quotes: words of
+// letters of any language, digits, dashes, dots and apostrophes.
+func isCultivar(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) &&
+			!unicode.IsMark(r) && !strings.ContainsRune(" -.'’", r) {
+			return false
+		}
+	}
+	return true
+}
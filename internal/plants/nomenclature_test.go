@@ -0,0 +1,35 @@
+package plants
+
+import "testing"
+
+func TestCutHybridMarker(t *testing.T) {
+	tests := []struct {
+		s      string
+		want   string
+		hybrid bool
+	}{
+		{"×Fatshedera", "Fatshedera", true},
+		{"× Fatshedera", "Fatshedera", true},
+		{"x Fatshedera", "Fatshedera", true},
+		{"X sepulcralis", "sepulcralis", true},
+		{"x  sepulcralis", "sepulcralis", true},
+		{"Fatshedera", "Fatshedera", false},
+		{"xanthosoma", "xanthosoma", false},
+		{"x", "x", false},
+		{"x ", "x ", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		got, hybrid := cutHybridMarker(test.s)
+		if got != test.want || hybrid != test.hybrid {
+			t.Errorf(
+				"%q: cut = %q, %t, want %q, %t",
+				test.s,
+				got,
+				hybrid,
+				test.want,
+				test.hybrid,
+			)
+		}
+	}
+}
@@ -1,26 +1,135 @@
 package plants
 
+import "time"
+
 // PlantShortDesc type encapsulates the short description of a plant: its
-// identifier and common name.
+// identifier, common name, creation date, the identifier of its location, if
+// any, and its tags in alphabetical order.
 type PlantShortDesc struct {
-	Id         int    `json:"id"`
-	CommonName string `json:"common_name"`
+	Id         int       `json:"id"`
+	CommonName string    `json:"common_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LocationId int       `json:"location_id,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+}
+
+// PlantPage is a page of the list of plants. Next is the URL of the next page,
+// empty if this page is the last one.
+type PlantPage struct {
+	Plants []PlantShortDesc `json:"plants"`
+	Next   string           `json:"next,omitempty"`
 }
 
-// Represents a plant by its name, its scientific name and its log entries.
+// Represents a plant by its name, its scientific name, its tags and its log
+// entries. The scientific name is the genus and the species of its taxon, if
+// any. The plant belongs to the household of identifier HouseholdId and
+// lives in the location of identifier LocationId, whose path is LocationPath,
+// if any.
 type Plant struct {
 	Id           int        `json:"id"`
 	CommonName   string     `json:"common_name"`
 	GenericName  string     `json:"generic_name"`
 	SpecificName string     `json:"specific_name"`
+	Taxon        *Taxon     `json:"taxon,omitempty"`
+	HouseholdId  int        `json:"household_id,omitempty"`
+	LocationId   int        `json:"location_id,omitempty"`
+	LocationPath string     `json:"location_path,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
 	Logs         []PlantLog `json:"logs"`
 }
 
 // Represents a plant log by the plant to wich it belongs, its identifier, its
-// description and its type
+// description, its type, when the event occurred and when it was recorded.
 type PlantLog struct {
-	Id        int    `json:"id"`
-	PlantId   int    `json:"plant_id"`
-	Desc      string `json:"desc"`
-	EventType int    `json:"event_type"`
+	Id         int       `json:"id"`
+	PlantId    int       `json:"plant_id"`
+	Desc       string    `json:"desc"`
+	EventType  EventType `json:"event_type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// SearchResult is a plant or a log entry matching a search. Kind is either
+// "plant" or "log", LogId is only set for log entries. The higher the rank,
+// the better the result matches the search.
+type SearchResult struct {
+	Kind       string            `json:"kind"`
+	PlantId    int               `json:"plant_id"`
+	LogId      int               `json:"log_id,omitempty"`
+	CommonName string            `json:"common_name"`
+	Snippet    []SnippetFragment `json:"snippet"`
+	Rank       float64           `json:"rank"`
+}
+
+// SnippetFragment is a piece of the text of a search result. Match is true if
+// the fragment matches the search.
+type SnippetFragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// Photo is an image of a plant, possibly illustrating one of its log entries,
+// in which case LogId is set. The image itself is stored apart, under Key, and
+// a smaller copy of it under ThumbnailKey if it was made. TakenAt is the time
+// the image was captured, if known.
+type Photo struct {
+	Id           int       `json:"id"`
+	PlantId      int       `json:"plant_id"`
+	LogId        int       `json:"log_id,omitempty"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Caption      string    `json:"caption"`
+	TakenAt      time.Time `json:"taken_at,omitzero"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// User is an account of the web server. Each user owns a collection of plants.
+type User struct {
+	Id        int       `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Scope of an API token, the kind of requests it allows.
+type Scope string
+
+const (
+	// Allows the GET and HEAD requests.
+	ScopeRead Scope = "read"
+	// Allows all the requests.
+	ScopeWrite Scope = "write"
+)
+
+// Token is a bearer token of the API. It is issued to the user of UserId, or
+// to a service such as the web server if UserId is 0. The token itself is only
+// known by its holder, RevokedAt is set once it is revoked.
+type Token struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id,omitempty"`
+	Name      string    `json:"name"`
+	Scope     Scope     `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	RevokedAt time.Time `json:"revoked_at,omitzero"`
+}
+
+// ImportReport is the result of the import of a spreadsheet of plants: the
+// number of rows read, the errors found in them, and the identifiers of the
+// plants added, in the order of the rows. No plant is added if there are
+// errors, or if the import is a dry run.
+type ImportReport struct {
+	Rows   int           `json:"rows"`
+	DryRun bool          `json:"dry_run"`
+	Errors []ImportError `json:"errors"`
+	Ids    []int         `json:"ids"`
+}
+
+// ImportError is an invalid value of a row of a spreadsheet. Row is the line
+// of the row in the file, starting at 1 with the header, and Column the name
+// of the column of the value in the header, empty if the whole row is invalid.
+type ImportError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
 }
@@ -0,0 +1,41 @@
+package plants
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Maximum number of characters of a tag.
+const TagMaxLen = 32
+
+// TagCount is a tag and the number of plants having it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ParseTag returns the tag written s: trimmed, in lower case, with its inner
+// spaces replaced by dashes, such as "toxic-to-cats". Tags are made of letters,
+// digits, dashes and underscores, and are at most 32 characters long.
+func ParseTag(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", errors.New("Tag is not valid utf8")
+	}
+	tag := strings.Join(strings.Fields(strings.ToLower(s)), "-")
+	if tag == "" {
+		return "", errors.New("Tag is empty")
+	}
+	if utf8.RuneCountInString(tag) > TagMaxLen {
+		return "", errors.New("Tag length is greater than 32")
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", errors.New(
+				"Tag must only contain letters, digits, dashes and underscores",
+			)
+		}
+	}
+	return tag, nil
+}
@@ -0,0 +1,224 @@
+package plants
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// InfraRank is the rank of a taxon below the species, written as abbreviated
+// in botanical names.
+type InfraRank string
+
+const (
+	// No rank below the species.
+	RankNone InfraRank = ""
+	// Subspecies.
+	RankSubspecies InfraRank = "subsp."
+	// Variety.
+	RankVariety InfraRank = "var."
+	// Form.
+	RankForm InfraRank = "f."
+)
+
+// Spellings of the infraspecific ranks, without their final dot.
+var infraRanks = map[string]InfraRank{
+	"":           RankNone,
+	"subsp":      RankSubspecies,
+	"ssp":        RankSubspecies,
+	"subspecies": RankSubspecies,
+	"var":        RankVariety,
+	"variety":    RankVariety,
+	"f":          RankForm,
+	"fo":         RankForm,
+	"forma":      RankForm,
+	"form":       RankForm,
+}
+
+// Maximum number of characters of each part of the name of a taxon.
+const taxonPartMaxLen = 255
+
+// ParseInfraRank returns the infraspecific rank written s, such as "var." or
+// "subsp", ignoring case.
+func ParseInfraRank(s string) (InfraRank, error) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+	r, ok := infraRanks[s]
+	if !ok {
+		return "", errors.New(
+			"Infraspecific rank must be one of subsp., var. or f.",
+		)
+	}
+	return r, nil
+}
+
+// Taxon is a botanical taxon plants belong to, from the family down to the
+// cultivar. Only the genus is required. The infraspecific rank and name go
+// together, and need a species.
+type Taxon struct {
+	Id           int       `json:"id"`
+	Family       string    `json:"family,omitempty"`
+	Genus        string    `json:"genus"`
+	Species      string    `json:"species,omitempty"`
+	Rank         InfraRank `json:"rank,omitempty"`
+	Infraspecies string    `json:"infraspecies,omitempty"`
+	Cultivar     string    `json:"cultivar,omitempty"`
+}
+
+// Name returns the botanical name of t, such as
+// "Monstera deliciosa var. borsigiana 'Albo Variegata'". The family is not part
+// of the name.
+func (t Taxon) Name() string {
+	var parts []string
+	for _, part := range t.NameParts() {
+		parts = append(parts, part.Text)
+	}
+	return strings.Join(parts, " ")
+}
+
+// MarshalJSON encodes t as a json object with its name, see Name.
+func (t Taxon) MarshalJSON() ([]byte, error) {
+	type taxon Taxon
+	return json.Marshal(struct {
+		taxon
+		Name string `json:"name"`
+	}{taxon(t), t.Name()})
+}
+
+// NormalizeTaxon returns t spelled the way botanical names are written: the
+// family and the genus capitalized, the species and the infraspecific name in
+// lower case, the hybrid markers written HybridMarker, and the cultivar without
+// quotes, starting with a capital letter. Spaces are trimmed and runs of spaces
+// collapsed. Returns an error if t is not a valid taxon, see checkNomenclature.
+func NormalizeTaxon(t Taxon) (Taxon, error) {
+	for _, part := range []string{
+		t.Family,
+		t.Genus,
+		t.Species,
+		t.Infraspecies,
+		t.Cultivar,
+	} {
+		if !utf8.ValidString(part) {
+			return Taxon{}, errors.New("Taxon name is not valid utf8")
+		}
+		if utf8.RuneCountInString(part) > taxonPartMaxLen {
+			return Taxon{}, errors.New(
+				"Taxon name part length is greater than 255",
+			)
+		}
+	}
+
+	var err error
+	t.Family = capitalize(collapseSpaces(t.Family))
+	t.Genus = spellHybrid(collapseSpaces(t.Genus), capitalize)
+	t.Species = spellHybrid(collapseSpaces(t.Species), strings.ToLower)
+	t.Rank, err = ParseInfraRank(string(t.Rank))
+	if err != nil {
+		return Taxon{}, err
+	}
+	t.Infraspecies = strings.ToLower(collapseSpaces(t.Infraspecies))
+	t.Cultivar = upperFirst(
+		collapseSpaces(strings.Trim(t.Cultivar, " '\"‘’")),
+	)
+
+	if t.Genus == "" {
+		return Taxon{}, errors.New("Genus is required")
+	}
+	if (t.Rank == RankNone) != (t.Infraspecies == "") {
+		return Taxon{}, errors.New(
+			"Infraspecific rank and name must be given together",
+		)
+	}
+	if t.Rank != RankNone && t.Species == "" {
+		return Taxon{}, errors.New("Infraspecific name requires a species")
+	}
+	err = checkNomenclature(t)
+	if err != nil {
+		return Taxon{}, err
+	}
+	return t, nil
+}
+
+// Returns s trimmed, with its runs of spaces replaced by a single space.
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Returns s with its first letter in upper case and the others in lower case.
+func capitalize(s string) string {
+	return upperFirst(strings.ToLower(s))
+}
+
+// Returns s with its first letter in upper case.
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// NameCheck tells whether a botanical name is in the catalog of taxa, and the
+// names of the catalog it may be a misspelling of, closest first.
+type NameCheck struct {
+	Known       bool     `json:"known"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// SuggestNames returns the names of candidates that name may be a misspelling
+// of, at most n of them, closest first. The names are compared ignoring case,
+// and may differ by one edit, two for names longer than 5 letters.
+func SuggestNames(name string, candidates []string, n int) []string {
+	type suggestion struct {
+		name string
+		dist int
+	}
+	name = strings.ToLower(name)
+	maxDist := 1
+	if utf8.RuneCountInString(name) > 5 {
+		maxDist = 2
+	}
+
+	var suggestions []suggestion
+	for _, c := range candidates {
+		d := editDistance(name, strings.ToLower(c))
+		if d <= maxDist {
+			suggestions = append(suggestions, suggestion{c, d})
+		}
+	}
+	slices.SortFunc(suggestions, func(a, b suggestion) int {
+		return cmp.Or(cmp.Compare(a.dist, b.dist), strings.Compare(a.name, b.name))
+	})
+
+	names := []string{}
+	for _, s := range suggestions[:min(n, len(suggestions))] {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Returns the Levenshtein distance between a and b, the number of runes to
+// insert, delete or replace to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
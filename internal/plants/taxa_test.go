@@ -0,0 +1,322 @@
+package plants
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTaxon(t *testing.T) {
+	tests := []struct {
+		taxon Taxon
+		want  Taxon
+	}{
+		{
+			Taxon{Genus: " monstera ", Species: "Deliciosa"},
+			Taxon{Genus: "Monstera", Species: "deliciosa"},
+		},
+		{
+			Taxon{Family: "ARACEAE", Genus: "MONSTERA"},
+			Taxon{Family: "Araceae", Genus: "Monstera"},
+		},
+		// Hybrid markers
+		{
+			Taxon{Genus: "×fatshedera", Species: "lizei"},
+			Taxon{Genus: "×Fatshedera", Species: "lizei"},
+		},
+		{
+			Taxon{Genus: "x  fatshedera"},
+			Taxon{Genus: "×Fatshedera"},
+		},
+		{
+			Taxon{Genus: "Salix", Species: "X sepulcralis"},
+			Taxon{Genus: "Salix", Species: "×sepulcralis"},
+		},
+		{
+			Taxon{Genus: "Salix", Species: "× Sepulcralis"},
+			Taxon{Genus: "Salix", Species: "×sepulcralis"},
+		},
+		{
+			Taxon{Genus: "xanthosoma"},
+			Taxon{Genus: "Xanthosoma"},
+		},
+		// Cultivars
+		{
+			Taxon{Genus: "Monstera", Cultivar: "'thai  constellation'"},
+			Taxon{Genus: "Monstera", Cultivar: "Thai constellation"},
+		},
+		{
+			Taxon{Genus: "Monstera", Cultivar: "‘Albo Variegata’"},
+			Taxon{Genus: "Monstera", Cultivar: "Albo Variegata"},
+		},
+		{
+			Taxon{Genus: "Rosa", Cultivar: "\"Pierre de Ronsard\""},
+			Taxon{Genus: "Rosa", Cultivar: "Pierre de Ronsard"},
+		},
+		{
+			Taxon{Genus: "Rosa", Cultivar: "Grace d'Été"},
+			Taxon{Genus: "Rosa", Cultivar: "Grace d'Été"},
+		},
+		// Infraspecific ranks
+		{
+			Taxon{
+				Genus:        "Monstera",
+				Species:      "deliciosa",
+				Rank:         "VAR",
+				Infraspecies: "Borsigiana",
+			},
+			Taxon{
+				Genus:        "Monstera",
+				Species:      "deliciosa",
+				Rank:         RankVariety,
+				Infraspecies: "borsigiana",
+			},
+		},
+		{
+			Taxon{
+				Genus:        "Arctostaphylos",
+				Species:      "uva-ursi",
+				Rank:         "ssp.",
+				Infraspecies: "coactilis",
+			},
+			Taxon{
+				Genus:        "Arctostaphylos",
+				Species:      "uva-ursi",
+				Rank:         RankSubspecies,
+				Infraspecies: "coactilis",
+			},
+		},
+		{
+			Taxon{
+				Genus:        "Hydrangea",
+				Species:      "macrophylla",
+				Rank:         " forma ",
+				Infraspecies: "normalis",
+			},
+			Taxon{
+				Genus:        "Hydrangea",
+				Species:      "macrophylla",
+				Rank:         RankForm,
+				Infraspecies: "normalis",
+			},
+		},
+		// Conserved family names
+		{
+			Taxon{Family: "compositae", Genus: "Bellis"},
+			Taxon{Family: "Compositae", Genus: "Bellis"},
+		},
+		{
+			Taxon{Family: "LEGUMINOSAE", Genus: "Lupinus"},
+			Taxon{Family: "Leguminosae", Genus: "Lupinus"},
+		},
+		// Diaeresis letters
+		{
+			Taxon{Genus: "ISOËTES", Species: "lacustris"},
+			Taxon{Genus: "Isoëtes", Species: "lacustris"},
+		},
+		{
+			Taxon{Genus: "Erysimum", Species: "Cheiranthoïdes"},
+			Taxon{Genus: "Erysimum", Species: "cheiranthoïdes"},
+		},
+	}
+	for _, test := range tests {
+		got, err := NormalizeTaxon(test.taxon)
+		if err != nil {
+			t.Errorf("%+v: %v", test.taxon, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%+v: taxon = %+v, want %+v", test.taxon, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeTaxonErrors(t *testing.T) {
+	tests := []struct {
+		taxon Taxon
+		want  string
+	}{
+		{Taxon{Genus: "Monstera\xff"}, "Taxon name is not valid utf8"},
+		{
+			Taxon{Genus: "Monstera", Cultivar: strings.Repeat("a", 256)},
+			"Taxon name part length is greater than 255",
+		},
+		{Taxon{Species: "deliciosa"}, "Genus is required"},
+		{
+			Taxon{Genus: "Monstera", Species: "deliciosa", Rank: "cv."},
+			"Infraspecific rank must be one of subsp., var. or f.",
+		},
+		{
+			Taxon{Genus: "Monstera", Species: "deliciosa", Rank: "var."},
+			"Infraspecific rank and name must be given together",
+		},
+		{
+			Taxon{
+				Genus:        "Monstera",
+				Species:      "deliciosa",
+				Infraspecies: "alba",
+			},
+			"Infraspecific rank and name must be given together",
+		},
+		{
+			Taxon{Genus: "Monstera", Rank: "var.", Infraspecies: "borsigiana"},
+			"Infraspecific name requires a species",
+		},
+		{
+			Taxon{Family: "Asteridae", Genus: "Bellis"},
+			"Family must be a Latin name ending with -aceae, such as Araceae",
+		},
+		{
+			Taxon{Family: "Ros aceae", Genus: "Rosa"},
+			"Family must be a Latin name ending with -aceae, such as Araceae",
+		},
+		{
+			Taxon{Genus: "M"},
+			"Genus must be a capitalized Latin name, such as Monstera",
+		},
+		{
+			Taxon{Genus: "Monstera deliciosa"},
+			"Genus must be a capitalized Latin name, such as Monstera",
+		},
+		{
+			Taxon{Genus: " × "},
+			"Genus must be a capitalized Latin name, such as Monstera",
+		},
+		{
+			Taxon{Genus: "Ficus2"},
+			"Genus must be a capitalized Latin name, such as Monstera",
+		},
+		{
+			Taxon{Genus: "Émilia"},
+			"Genus must be a capitalized Latin name, such as Monstera",
+		},
+		{
+			Taxon{Genus: "Monstera", Species: "d"},
+			"Species must be a Latin epithet in lower case, such as deliciosa",
+		},
+		{
+			Taxon{Genus: "Arctostaphylos", Species: "uva-"},
+			"Species must be a Latin epithet in lower case, such as deliciosa",
+		},
+		{
+			Taxon{Genus: "Monstera", Species: "deliciosa!"},
+			"Species must be a Latin epithet in lower case, such as deliciosa",
+		},
+		{
+			Taxon{
+				Genus:        "Monstera",
+				Species:      "deliciosa",
+				Rank:         "var.",
+				Infraspecies: "borsigiana alba",
+			},
+			"Infraspecific name must be a Latin epithet in lower case",
+		},
+		{
+			Taxon{Genus: "Monstera", Cultivar: "Albo/Variegata"},
+			"Cultivar must only contain letters, digits, spaces, dashes, " +
+				"dots and apostrophes",
+		},
+	}
+	for _, test := range tests {
+		_, err := NormalizeTaxon(test.taxon)
+		if err == nil {
+			t.Errorf("%+v: no error, want %q", test.taxon, test.want)
+			continue
+		}
+		if err.Error() != test.want {
+			t.Errorf("%+v: error = %q, want %q", test.taxon, err, test.want)
+		}
+	}
+}
+
+func TestTaxonName(t *testing.T) {
+	taxon := Taxon{
+		Family:       "Araceae",
+		Genus:        "Monstera",
+		Species:      "deliciosa",
+		Rank:         RankVariety,
+		Infraspecies: "borsigiana",
+		Cultivar:     "Albo Variegata",
+	}
+	want := "Monstera deliciosa var. borsigiana 'Albo Variegata'"
+	if got := taxon.Name(); got != want {
+		t.Errorf("name = %q, want %q", got, want)
+	}
+	taxon = Taxon{Genus: "×Fatshedera"}
+	if got := taxon.Name(); got != "×Fatshedera" {
+		t.Errorf("name = %q, want %q", got, "×Fatshedera")
+	}
+}
+
+func TestSuggestNames(t *testing.T) {
+	candidates := []string{
+		"Cosmos",
+		"Costus",
+		"Iris",
+		"Ixia",
+		"Monstera",
+		"Monstera adansonii",
+		"Monstera deliciosa",
+		"Rosa",
+	}
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{"MONSTERA DELICIOSA", 5, []string{"Monstera deliciosa"}},
+		{"monstera delicosa", 5, []string{"Monstera deliciosa"}},
+		{"monstra", 5, []string{"Monstera"}},
+		// Two edits for names longer than 5 letters, one otherwise
+		{"mnstra", 5, []string{"Monstera"}},
+		{"rsa", 5, []string{"Rosa"}},
+		{"rs", 5, []string{}},
+		{"rossaaa", 5, []string{}},
+		// Closest first, then in alphabetical order
+		{"costus", 5, []string{"Costus", "Cosmos"}},
+		{"iria", 5, []string{"Iris", "Ixia"}},
+		{"iria", 1, []string{"Iris"}},
+		{"iria", 0, []string{}},
+		{"philodendron", 5, []string{}},
+	}
+	for _, test := range tests {
+		got := SuggestNames(test.name, candidates, test.n)
+		if got == nil || !slices.Equal(got, test.want) {
+			t.Errorf(
+				"%q, %d: suggestions = %#v, want %#v",
+				test.name,
+				test.n,
+				got,
+				test.want,
+			)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"rosa", "", 4},
+		{"", "rosa", 4},
+		{"rosa", "rosa", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"monstera", "monstra", 1},
+		{"isoëtes", "isoetes", 1},
+		{"×fatshedera", "fatshedera", 1},
+	}
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf(
+				"%q, %q: distance = %d, want %d",
+				test.a,
+				test.b,
+				got,
+				test.want,
+			)
+		}
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -15,7 +17,13 @@ var (
 	NewPlantRoute    = "/plants/new/"
 	PlantInfoRoute   = "/plants/{id}/"
 	NewPlantLogRoute = "/plants/log/{id}/"
+	EditPlantRoute   = "/plants/edit/{id}/"
+	DeletePlantRoute = "/plants/delete/{id}/"
+	EditLogRoute     = "/plants/log/edit/{id}/{logId}/"
+	DeleteLogRoute   = "/plants/log/delete/{id}/{logId}/"
+	SearchRoute      = "/search/"
 	plantsListUrl    = "/plants/"
+	searchUrl        = "/search/"
 	notAllowed       = "Method not allowed"
 )
 
@@ -24,83 +32,279 @@ type HandlerEnv struct {
 	templates *template.Template
 	webUrl    string
 	apiUrl    string
+	apiToken  string
 	navBar    navBarLinks
+	sessions  *sessionStore
 }
 
-func New(webUrl, apiUrl string) (HandlerEnv, error) {
-	t, err := template.ParseFiles(
+// Returns the environment of the handlers of the web server at webUrl, which
+// sends its requests to the API at apiUrl with the service token apiToken.
+func New(webUrl, apiUrl, apiToken string) (HandlerEnv, error) {
+	funcs := template.FuncMap{
+		"eventLabel":        eventLabel,
+		"roleLabel":         roleLabel,
+		"locationKindLabel": locationKindLabel,
+		"botanicalName":     botanicalName,
+	}
+	t, err := template.New("").Funcs(funcs).ParseFiles(
 		"templates/meta-tags.gohtml",
 		"templates/nav-bar.gohtml",
+		"templates/tag-chip.gohtml",
+		"templates/taxon-names.gohtml",
 		"templates/index.gohtml",
 		"templates/newPlant.gohtml",
 		"templates/plantInfo.gohtml",
 		"templates/newPlantLog.gohtml",
+		"templates/editPlant.gohtml",
+		"templates/deletePlant.gohtml",
+		"templates/editPlantLog.gohtml",
+		"templates/search.gohtml",
+		"templates/today.gohtml",
+		"templates/login.gohtml",
+		"templates/signup.gohtml",
+		"templates/tokens.gohtml",
+		"templates/households.gohtml",
+		"templates/household.gohtml",
+		"templates/invitation.gohtml",
+		"templates/locations.gohtml",
+		"templates/importPlants.gohtml",
 	)
 	if err != nil {
 		return HandlerEnv{}, err
 	}
-	navBar := navBarLinks{webUrl + "/", webUrl + "/plants/new/"}
-	return HandlerEnv{t, webUrl, apiUrl, navBar}, nil
+	navBar := navBarLinks{
+		webUrl + "/",
+		webUrl + "/plants/new/",
+		webUrl + "/search/",
+		webUrl + "/today/",
+		webUrl + LocationsRoute,
+		webUrl + ImportPlantsRoute,
+		webUrl + HouseholdsRoute,
+		webUrl + TokensRoute,
+		webUrl + LogoutRoute,
+		"",
+	}
+	return HandlerEnv{
+		t,
+		webUrl,
+		apiUrl,
+		apiToken,
+		navBar,
+		newSessionStore(),
+	}, nil
 }
 
-// Encapsulates the nav bar links
+// Encapsulates the nav bar links and the name of the logged in user
 type navBarLinks struct {
-	Home     string
-	AddPlant string
+	Home       string
+	AddPlant   string
+	Search     string
+	Today      string
+	Locations  string
+	Import     string
+	Households string
+	Tokens     string
+	Logout     string
+	User       string
+}
+
+// Returns the nav bar links with the name of the user of the session of r.
+func (e *HandlerEnv) navBarFor(r *http.Request) navBarLinks {
+	navBar := e.navBar
+	if user, ok := currentUser(r); ok {
+		navBar.User = user.Username
+	}
+	return navBar
 }
 
-// Encapsulates the common name of a plant and a link to the web page displaying
-// more detailed information.
+// Encapsulates the common name of a plant, its tags and a link to the web page
+// displaying more detailed information.
 type plantLink struct {
 	Link       string
 	CommonName string
+	Tags       []string
 }
 
-// Encapsulates plant links and the nav bar. Used by index page template.
-type plantLinksWithNavBar struct {
+// Encapsulates the links to the plants of a page of the plants list that are
+// in the location of given path, empty for the plants in no location.
+type plantGroup struct {
+	Path       string
 	PlantLinks []plantLink
-	NavBar     navBarLinks
 }
 
-// Encapsulates a plant and the nav bar. Used by plant information page
+// Encapsulates plant links grouped by location, the locations of the user, the
+// tag cloud of the plants of the user and the nav bar. Used by index page
 // template.
-type plantInfoWithNavBar struct {
-	Plant  plants.Plant
+type plantLinksWithNavBar struct {
+	Groups    []plantGroup
+	Locations []plants.Location
+	Cloud     []cloudTag
+	// Links to the first and next pages, empty if there are none
+	First  string
+	Next   string
+	Query  listQuery
 	NavBar navBarLinks
 }
 
-// Encaplusates a plant identifier and the nav bar. Used by new plant log page
-// template.
+// Encapsulates the sort and filters of the plants list, as selected in the
+// index page.
+type listQuery struct {
+	Sort     string
+	Order    string
+	Generic  string
+	Specific string
+	Location string
+	// Tags separated by commas
+	Tag   string
+	Match string
+}
+
+// Query parameters of the plants list forwarded to the API.
+var listParams = []string{
+	"limit",
+	"sort",
+	"order",
+	"cursor",
+	"generic",
+	"specific",
+	"location",
+	"tag",
+	"match",
+}
+
+// Encapsulates a plant and the nav bar. Used by plant information page
+// template, which also shows the photos of the plant and of its log entries, by
+// log entry identifier, and the locations of its household it can be moved to.
+type plantInfoWithNavBar struct {
+	Plant     plants.Plant
+	Photos    []plants.Photo
+	LogPhotos map[int][]plants.Photo
+	Locations []plants.Location
+	NavBar    navBarLinks
+}
+
+// Encaplusates a plant identifier, the event types a log entry can have and the
+// nav bar. Used by new plant log page template.
 type plantIdWithNavBar struct {
-	Id     int
-	NavBar navBarLinks
+	Id         int
+	EventTypes []plants.EventType
+	NavBar     navBarLinks
+}
+
+// Encapsulates a log entry, the event types it can have and the nav bar. Used by
+// the log entry edition page template.
+type plantLogWithNavBar struct {
+	Log        plants.PlantLog
+	EventTypes []plants.EventType
+	NavBar     navBarLinks
+}
+
+// Encapsulates a search query, its results and the nav bar. Used by the search
+// page template.
+type searchResultsWithNavBar struct {
+	Query   string
+	Results []plants.SearchResult
+	NavBar  navBarLinks
+}
+
+// Labels of the event types, as displayed in the pages.
+var eventLabels = map[plants.EventType]string{
+	plants.EventNote:          "Note",
+	plants.EventWatering:      "Arrosage",
+	plants.EventFertilizing:   "Engrais",
+	plants.EventRepotting:     "Rempotage",
+	plants.EventPruning:       "Taille",
+	plants.EventPestTreatment: "Traitement",
+	plants.EventFlowering:     "Floraison",
+	plants.EventHarvest:       "Récolte",
+	plants.EventPropagation:   "Bouturage",
+	plants.EventMisting:       "Brumisation",
+	plants.EventMove:          "Déplacement",
+}
+
+// Returns the label of event type e, or its name if it has no label.
+func eventLabel(e plants.EventType) string {
+	label, ok := eventLabels[e]
+	if !ok {
+		return e.String()
+	}
+	return label
 }
 
 // Returns a handler for the "/" or "/index.html" URL.
 // The request method should be GET. The handler sends a GET request to the API
-// that fetches the plants list and sends back to the client a HTML document
-// with the plants list.
+// that fetches a page of the plants list and sends back to the client a HTML
+// document with the page, grouped by location, links to the other pages and the
+// tag cloud of the plants of the user. The sort, order, filter and cursor query
+// parameters of the request are forwarded to the API.
 func (e *HandlerEnv) IndexHandler() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			resp, err := http.Get(e.apiUrl + plantsListUrl)
+			query := r.URL.Query()
+			params := url.Values{}
+			for _, param := range listParams {
+				for _, v := range query[param] {
+					if v != "" {
+						params.Add(param, v)
+					}
+				}
+			}
+			resp, err := e.apiGet(r, plantsListUrl+"?"+params.Encode())
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
 
 			dec := json.NewDecoder(resp.Body)
-			var plants []plants.PlantShortDesc
-			err = dec.Decode(&plants)
+			var page plants.PlantPage
+			err = dec.Decode(&page)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			links := plantsShortDescToPlantLinks(plants, e.webUrl)
-			linksWithNav := plantLinksWithNavBar{links, e.navBar}
-			err = e.templates.ExecuteTemplate(w, "index.gohtml", linksWithNav)
+
+			locations, ok := e.getLocations(w, r)
+			if !ok {
+				return
+			}
+			cloud, ok := e.getTagCloud(w, r)
+			if !ok {
+				return
+			}
+			data := plantLinksWithNavBar{
+				Groups:    groupPlantLinks(page.Plants, locations, e.webUrl),
+				Locations: locations,
+				Cloud:     cloud,
+				Query: listQuery{
+					Sort:     params.Get("sort"),
+					Order:    params.Get("order"),
+					Generic:  params.Get("generic"),
+					Specific: params.Get("specific"),
+					Location: params.Get("location"),
+					Tag:      strings.Join(params["tag"], ", "),
+					Match:    params.Get("match"),
+				},
+				NavBar: e.navBarFor(r),
+			}
+			if params.Has("cursor") {
+				params.Del("cursor")
+				data.First = e.webUrl + "/?" + params.Encode()
+			}
+			if page.Next != "" {
+				// The API link has the same query parameters as the web page
+				next, err := url.Parse(page.Next)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				data.Next = e.webUrl + "/?" + next.RawQuery
+			}
+			err = e.templates.ExecuteTemplate(w, "index.gohtml", data)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
@@ -112,7 +316,8 @@ func (e *HandlerEnv) IndexHandler() func(http.ResponseWriter, *http.Request) {
 
 // Returns a handler for the "/plants/new" URL.
 // The request method should be either GET or POST. If it is GET, returns an
-// html page with a form to add a new plant. The submit button sends a POST
+// html page with a form to add a new plant to one of the households of the
+// user in which the user can add plants. The submit button sends a POST
 // request to the same URL. If the request method is POST, parses the form and
 // sends a POST request to the API to add the new plant, redirecting to the
 // plant's information page.
@@ -120,7 +325,12 @@ func (e *HandlerEnv) NewPlantHandler() func(http.ResponseWriter, *http.Request)
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			err := e.templates.ExecuteTemplate(w, "newPlant.gohtml", e.navBar)
+			households, ok := e.getHouseholds(w, r)
+			if !ok {
+				return
+			}
+			data := householdsWithNavBar{households, e.navBarFor(r)}
+			err := e.templates.ExecuteTemplate(w, "newPlant.gohtml", data)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
@@ -130,17 +340,24 @@ func (e *HandlerEnv) NewPlantHandler() func(http.ResponseWriter, *http.Request)
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
+			if !checkPlantNames(w, r, plants.Plant{}) {
+				return
+			}
 			data := url.Values{}
 			data.Set("common-name", r.PostForm.Get("common-name"))
 			data.Set("generic-name", r.PostForm.Get("generic-name"))
 			data.Set("specific-name", r.PostForm.Get("specific-name"))
-			url := e.apiUrl + plantsListUrl + "new/"
-			resp, err := http.PostForm(url, data)
+			data.Set("household-id", r.PostForm.Get("household-id"))
+			path := plantsListUrl + "new/"
+			resp, err := e.apiFormRequest(r, http.MethodPost, path, data)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
 
 			body, err := io.ReadAll(resp.Body)
 			if err != nil {
@@ -149,7 +366,7 @@ func (e *HandlerEnv) NewPlantHandler() func(http.ResponseWriter, *http.Request)
 			}
 			id := string(body)
 
-			url = e.webUrl + plantsListUrl + id
+			url := e.webUrl + plantsListUrl + id
 			http.Redirect(
 				w,
 				r,
@@ -157,14 +374,15 @@ func (e *HandlerEnv) NewPlantHandler() func(http.ResponseWriter, *http.Request)
 				http.StatusSeeOther,
 			)
 		default:
-			http.Error(w, notAllowed, http.StatusMethodNotAllowed)			
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
 		}
 	}
 }
 
 // Returns a handler for the "/plants/{id}" URL.
 // The request method should be GET. On success, returns an html document with
-// relevant information on the plant requested. If the requested plant
+// relevant information on the plant requested and a form to move it to another
+// location of its household. If the requested plant
 // identifier is not a number, an error response is sent. This handler sends
 // a GET request to the API to fetch the relevant plant information, if an error
 // occurs, the handler sends back to the client an error response.
@@ -175,29 +393,313 @@ func (e *HandlerEnv) PlantInfoHandler() func(http.ResponseWriter, *http.Request)
 			return
 		}
 
-		url := e.apiUrl + plantsListUrl + r.PathValue("id")
-		resp, err := http.Get(url)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		plant, ok := e.getPlant(w, r, r.PathValue("id"))
+		if !ok {
+			return
+		}
+		photos, ok := e.getPhotos(w, r, r.PathValue("id"))
+		if !ok {
+			return
+		}
+		locations, ok := e.getLocations(w, r)
+		if !ok {
 			return
 		}
-		defer resp.Body.Close()
 
-		dec := json.NewDecoder(resp.Body)
-		var plantInfo plants.Plant
-		err = dec.Decode(&plantInfo)
+		data := plantInfoWithNavBar{
+			Plant:     plant,
+			LogPhotos: make(map[int][]plants.Photo),
+			NavBar:    e.navBarFor(r),
+		}
+		for _, l := range locations {
+			if l.HouseholdId == plant.HouseholdId {
+				data.Locations = append(data.Locations, l)
+			}
+		}
+		for _, photo := range photos {
+			if photo.LogId != 0 {
+				data.LogPhotos[photo.LogId] = append(
+					data.LogPhotos[photo.LogId],
+					photo,
+				)
+			} else {
+				data.Photos = append(data.Photos, photo)
+			}
+		}
+		err := e.templates.ExecuteTemplate(w, "plantInfo.gohtml", data)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/edit/{id}/" URL.
+// The request method should be either GET or POST. If it is GET, returns an
+// html page with a form to edit the names of the plant. The submit button sends
+// a POST request to the same URL, that sends a PUT request to the API with the
+// new names and redirects to the plant's information page.
+func (e *HandlerEnv) EditPlantHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			e.executePlantTemplate(w, r, r.PathValue("id"), "editPlant.gohtml")
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			current, ok := e.getPlant(w, r, r.PathValue("id"))
+			if !ok {
+				return
+			}
+			if !checkPlantNames(w, r, current) {
+				return
+			}
+			data := url.Values{}
+			data.Set("common-name", r.PostForm.Get("common-name"))
+			data.Set("generic-name", r.PostForm.Get("generic-name"))
+			data.Set("specific-name", r.PostForm.Get("specific-name"))
+			path := plantsListUrl + r.PathValue("id") + "/"
+			resp, err := e.apiFormRequest(r, http.MethodPut, path, data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+
+			url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+			http.Redirect(w, r, url, http.StatusSeeOther)
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/delete/{id}/" URL.
+// The request method should be either GET or POST. If it is GET, returns an
+// html page asking to confirm the deletion of the plant and its log. The
+// confirmation button sends a POST request to the same URL, that sends a DELETE
+// request to the API and redirects to the index page.
+func (e *HandlerEnv) DeletePlantHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			e.executePlantTemplate(w, r, r.PathValue("id"), "deletePlant.gohtml")
+		case http.MethodPost:
+			path := plantsListUrl + r.PathValue("id") + "/"
+			resp, err := e.apiFormRequest(r, http.MethodDelete, path, nil)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+
+			http.Redirect(w, r, e.webUrl+"/", http.StatusSeeOther)
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/log/edit/{id}/{logId}/" URL.
+// The request method should be either GET or POST. If it is GET, returns an
+// html page with a form to edit the log entry of identifier logId of the plant
+// of identifier id. The submit button sends a POST request to the same URL, that
+// sends a PUT request to the API with the new values of the entry and redirects
+// to the plant's information page.
+func (e *HandlerEnv) EditPlantLogHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := "/plants/log/" + r.PathValue("id") + "/" +
+			r.PathValue("logId") + "/"
+		switch r.Method {
+		case http.MethodGet:
+			resp, err := e.apiGet(r, path)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+
+			var plantLog plants.PlantLog
+			err = json.NewDecoder(resp.Body).Decode(&plantLog)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data := plantLogWithNavBar{plantLog, plants.EventTypes(), e.navBarFor(r)}
+			err = e.templates.ExecuteTemplate(w, "editPlantLog.gohtml", data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data := url.Values{}
+			data.Set("new-entry", r.PostForm.Get("entry"))
+			data.Set("event-type", r.PostForm.Get("event-type"))
+			data.Set("occurred-at", r.PostForm.Get("occurred-at"))
+			resp, err := e.apiFormRequest(r, http.MethodPut, path, data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+
+			url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+			http.Redirect(w, r, url, http.StatusSeeOther)
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/log/delete/{id}/{logId}/" URL.
+// The request method should be POST. Sends a DELETE request to the API for the
+// log entry of identifier logId of the plant of identifier id and redirects to
+// the plant's information page.
+func (e *HandlerEnv) DeletePlantLogHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
 			return
 		}
 
-		data := plantInfoWithNavBar{plantInfo, e.navBar}
-		err = e.templates.ExecuteTemplate(w, "plantInfo.gohtml", data)
+		path := "/plants/log/" + r.PathValue("id") + "/" +
+			r.PathValue("logId") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, nil)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// Fetches the plant of given identifier from the API and executes the template
+// of given name with it.
+func (e *HandlerEnv) executePlantTemplate(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+	name string,
+) {
+	plantInfo, ok := e.getPlant(w, r, id)
+	if !ok {
+		return
+	}
+
+	data := plantInfoWithNavBar{Plant: plantInfo, NavBar: e.navBarFor(r)}
+	err := e.templates.ExecuteTemplate(w, name, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Fetches the plant of given identifier from the API, on behalf of the user of
+// r. If it fails, sends an error response and returns false.
+func (e *HandlerEnv) getPlant(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+) (plants.Plant, bool) {
+	var plantInfo plants.Plant
+	resp, err := e.apiGet(r, plantsListUrl+id+"/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return plantInfo, false
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return plantInfo, false
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&plantInfo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return plantInfo, false
+	}
+	return plantInfo, true
+}
+
+// Sends a GET request to the API at given path, on behalf of the user of r.
+func (e *HandlerEnv) apiGet(r *http.Request, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		r.Context(),
+		http.MethodGet,
+		e.apiUrl+path,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.setApiHeaders(req, r)
+	return http.DefaultClient.Do(req)
+}
+
+// Sends a request of given method to the API at given path, with data url
+// encoded in the body, on behalf of the user of r.
+func (e *HandlerEnv) apiFormRequest(
+	r *http.Request,
+	method string,
+	path string,
+	data url.Values,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		r.Context(),
+		method,
+		e.apiUrl+path,
+		strings.NewReader(data.Encode()),
+	)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	e.setApiHeaders(req, r)
+	return http.DefaultClient.Do(req)
+}
+
+// Body of the error responses of the API.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// If resp is an error response from the API, sends its message back to the
+// client with the same status code and returns true.
+func forwardApiError(w http.ResponseWriter, resp *http.Response) bool {
+	if resp.StatusCode < 400 {
+		return false
+	}
+	var body apiError
+	err := json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil || body.Error == "" {
+		body.Error = http.StatusText(resp.StatusCode)
+	}
+	http.Error(w, body.Error, resp.StatusCode)
+	return true
 }
 
 // Returns a handler for the URL "/plants/log/{id}/".
@@ -210,7 +712,7 @@ func (e *HandlerEnv) NewPlantLogHandler() func(http.ResponseWriter, *http.Reques
 		}
 		switch r.Method {
 		case http.MethodGet:
-			data := plantIdWithNavBar{id, e.navBar}
+			data := plantIdWithNavBar{id, plants.EventTypes(), e.navBarFor(r)}
 			err = e.templates.ExecuteTemplate(w, "newPlantLog.gohtml", data)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -224,15 +726,20 @@ func (e *HandlerEnv) NewPlantLogHandler() func(http.ResponseWriter, *http.Reques
 			}
 			data := url.Values{}
 			data.Set("new-entry", r.PostForm.Get("new-entry"))
-			url := e.apiUrl + "/plants/log/" + r.PathValue("id") + "/"
-			resp, err := http.PostForm(url, data)
+			data.Set("occurred-at", r.PostForm.Get("occurred-at"))
+			data.Set("event-type", r.PostForm.Get("event-type"))
+			path := "/plants/log/" + r.PathValue("id") + "/"
+			resp, err := e.apiFormRequest(r, http.MethodPost, path, data)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
 
-			url = e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+			url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
 			http.Redirect(
 				w,
 				r,
@@ -246,6 +753,46 @@ func (e *HandlerEnv) NewPlantLogHandler() func(http.ResponseWriter, *http.Reques
 	}
 }
 
+// Returns a handler for the "/search/" URL.
+// The request method should be GET. Returns an html page with the plants and
+// log entries matching the "q" query parameter, the matching words being
+// highlighted. Without query, the page only has the search form.
+func (e *HandlerEnv) SearchHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		data := searchResultsWithNavBar{
+			Query:  strings.TrimSpace(r.URL.Query().Get("q")),
+			NavBar: e.navBarFor(r),
+		}
+		if data.Query != "" {
+			params := url.Values{"q": {data.Query}}
+			resp, err := e.apiGet(r, searchUrl+"?"+params.Encode())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+
+			err = json.NewDecoder(resp.Body).Decode(&data.Results)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		err := e.templates.ExecuteTemplate(w, "search.gohtml", data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // converts a slice of plant short descriptions to a slice of plant links
 func plantsShortDescToPlantLinks(
 	psd []plants.PlantShortDesc,
@@ -256,6 +803,34 @@ func plantsShortDescToPlantLinks(
 		plantLinks[i].Link =
 			link + plantsListUrl + strconv.Itoa(plant.Id)
 		plantLinks[i].CommonName = plant.CommonName
+		plantLinks[i].Tags = plant.Tags
 	}
 	return plantLinks
 }
+
+// Groups the links to the plants of psd by location, in the order of the
+// locations, the plants in no location coming last. The plants keep their
+// order within a group, and the empty groups are left out.
+func groupPlantLinks(
+	psd []plants.PlantShortDesc,
+	locations []plants.Location,
+	link string,
+) []plantGroup {
+	links := plantsShortDescToPlantLinks(psd, link)
+	index := make(map[int]int, len(locations))
+	groups := make([]plantGroup, len(locations)+1)
+	for i, l := range locations {
+		index[l.Id] = i
+		groups[i].Path = l.Path
+	}
+	for i, plant := range psd {
+		g, ok := index[plant.LocationId]
+		if !ok {
+			g = len(locations)
+		}
+		groups[g].PlantLinks = append(groups[g].PlantLinks, links[i])
+	}
+	return slices.DeleteFunc(groups, func(g plantGroup) bool {
+		return len(g.PlantLinks) == 0
+	})
+}
@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+var (
+	HouseholdsRoute       = "/households/"
+	HouseholdRoute        = "/households/{id}/"
+	DeleteHouseholdRoute  = "/households/{id}/delete/"
+	MemberRoute           = "/households/{id}/members/{userId}/"
+	RemoveMemberRoute     = "/households/{id}/members/{userId}/remove/"
+	InvitationsRoute      = "/households/{id}/invitations/"
+	DeleteInvitationRoute = "/households/{id}/invitations/{invitationId}/delete/"
+	JoinRoute             = "/invitations/{token}/"
+	householdsUrl         = "/households/"
+	invitationsUrl        = "/invitations/"
+)
+
+// Roles of the members of a household, in the order of the role selects.
+var roles = []plants.Role{
+	plants.RoleOwner,
+	plants.RoleCaretaker,
+	plants.RoleViewer,
+}
+
+// Labels of the roles of the members of a household, displayed by the pages.
+var roleLabels = map[plants.Role]string{
+	plants.RoleOwner:     "Propriétaire",
+	plants.RoleCaretaker: "Jardinier",
+	plants.RoleViewer:    "Observateur",
+}
+
+// Encapsulates the households of the user and the nav bar. Used by the
+// households page template.
+type householdsWithNavBar struct {
+	Households []plants.Household
+	NavBar     navBarLinks
+}
+
+// Encapsulates a household with its members, its pending invitations if the
+// user is an owner, the link of the invitation just created if any, the
+// identifier of the user and the nav bar. Used by the household page template.
+type householdWithNavBar struct {
+	Household   plants.Household
+	Invitations []plants.Invitation
+	Link        string
+	UserId      int
+	Roles       []plants.Role
+	NavBar      navBarLinks
+}
+
+// Encapsulates an invitation, the secret of its link and the nav bar. Used by
+// the invitation page template.
+type invitationWithNavBar struct {
+	Invitation plants.Invitation
+	Token      string
+	NavBar     navBarLinks
+}
+
+// Returns the label of role r, or its name if it has no label.
+func roleLabel(r plants.Role) string {
+	label, ok := roleLabels[r]
+	if !ok {
+		return string(r)
+	}
+	return label
+}
+
+// Returns a handler for the "/households/" URL.
+// The request method should be GET or POST. If it is GET, fetches the
+// households of the user from the API and sends back an html page listing
+// them. If it is POST, sends a POST request to the API that creates a household
+// named by the "name" form value, and redirects to the page of the household.
+func (e *HandlerEnv) HouseholdsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			households, ok := e.getHouseholds(w, r)
+			if !ok {
+				return
+			}
+			data := householdsWithNavBar{households, e.navBarFor(r)}
+			err := e.templates.ExecuteTemplate(w, "households.gohtml", data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data := url.Values{}
+			data.Set("name", r.PostForm.Get("name"))
+			resp, err := e.apiFormRequest(
+				r,
+				http.MethodPost,
+				householdsUrl,
+				data,
+			)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(
+				w,
+				r,
+				e.webUrl+householdsUrl+string(body)+"/",
+				http.StatusSeeOther,
+			)
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/households/{id}/" URL.
+// The request method should be GET or POST. If it is GET, fetches the household
+// of given identifier from the API and sends back an html page with its members
+// and, for its owners, its pending invitations. If it is POST, sends a PATCH
+// request to the API that renames the household after the "name" form value,
+// and redirects to the page of the household.
+func (e *HandlerEnv) HouseholdHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			e.executeHouseholdTemplate(w, r, id, "")
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data := url.Values{}
+			data.Set("name", r.PostForm.Get("name"))
+			path := householdsUrl + strconv.Itoa(id) + "/"
+			resp, err := e.apiFormRequest(r, http.MethodPatch, path, data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+			http.Redirect(w, r, e.webUrl+path, http.StatusSeeOther)
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/households/{id}/delete/" URL.
+// The request method should be POST. Sends a DELETE request to the API that
+// deletes the household of given identifier, and redirects to the households
+// page.
+func (e *HandlerEnv) DeleteHouseholdHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := householdsUrl + r.PathValue("id") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, url.Values{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		http.Redirect(w, r, e.webUrl+HouseholdsRoute, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/households/{id}/members/{userId}/" URL.
+// The request method should be POST. Sends a PATCH request to the API that
+// gives the member of identifier userId the role of the "role" form value, and
+// redirects to the page of the household.
+func (e *HandlerEnv) MemberHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		household := householdsUrl + r.PathValue("id") + "/"
+		path := household + "members/" + r.PathValue("userId") + "/"
+		data := url.Values{}
+		data.Set("role", r.PostForm.Get("role"))
+		resp, err := e.apiFormRequest(r, http.MethodPatch, path, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		http.Redirect(w, r, e.webUrl+household, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/households/{id}/members/{userId}/remove/" URL.
+// The request method should be POST. Sends a DELETE request to the API that
+// removes the member of identifier userId from the household, and redirects to
+// the page of the household, or to the households page if the user left the
+// household.
+func (e *HandlerEnv) RemoveMemberHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		household := householdsUrl + r.PathValue("id") + "/"
+		path := household + "members/" + r.PathValue("userId") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, url.Values{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		user, _ := currentUser(r)
+		if r.PathValue("userId") == strconv.Itoa(user.Id) {
+			household = HouseholdsRoute
+		}
+		http.Redirect(w, r, e.webUrl+household, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/households/{id}/invitations/" URL.
+// The request method should be POST. Sends a POST request to the API that
+// creates an invitation to the household with the role of the "role" form
+// value, and sends back the page of the household with the link of the
+// invitation, which is only shown once.
+func (e *HandlerEnv) InvitationsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		path := householdsUrl + strconv.Itoa(id) + "/invitations/"
+		data := url.Values{}
+		data.Set("role", r.PostForm.Get("role"))
+		data.Set("days", r.PostForm.Get("days"))
+		resp, err := e.apiFormRequest(r, http.MethodPost, path, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+		var created struct {
+			Secret string `json:"token"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&created)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		link := e.webUrl + invitationsUrl + created.Secret + "/"
+		e.executeHouseholdTemplate(w, r, id, link)
+	}
+}
+
+// Returns a handler for the
+// "/households/{id}/invitations/{invitationId}/delete/" URL.
+// The request method should be POST. Sends a DELETE request to the API that
+// deletes the invitation of identifier invitationId, and redirects to the page
+// of the household.
+func (e *HandlerEnv) DeleteInvitationHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		household := householdsUrl + r.PathValue("id") + "/"
+		path := household + "invitations/" + r.PathValue("invitationId") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, url.Values{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		http.Redirect(w, r, e.webUrl+household, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/invitations/{token}/" URL.
+// The request method should be GET or POST. If it is GET, fetches the
+// invitation of the link from the API and sends back an html page asking the
+// user to join the household. If it is POST, sends a POST request to the API
+// that accepts the invitation, and redirects to the page of the household.
+func (e *HandlerEnv) JoinHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PathValue("token")
+		path := invitationsUrl + url.PathEscape(token) + "/"
+		resp, err := e.apiGet(r, path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+		var inv plants.Invitation
+		err = json.NewDecoder(resp.Body).Decode(&inv)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			data := invitationWithNavBar{inv, token, e.navBarFor(r)}
+			err = e.templates.ExecuteTemplate(w, "invitation.gohtml", data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		case http.MethodPost:
+			resp, err := e.apiFormRequest(r, http.MethodPost, path, url.Values{})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+			household := householdsUrl + strconv.Itoa(inv.HouseholdId) + "/"
+			http.Redirect(w, r, e.webUrl+household, http.StatusSeeOther)
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Fetches the households of the user of r from the API. If it fails, sends an
+// error response and returns false.
+func (e *HandlerEnv) getHouseholds(
+	w http.ResponseWriter,
+	r *http.Request,
+) ([]plants.Household, bool) {
+	resp, err := e.apiGet(r, householdsUrl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return nil, false
+	}
+
+	var households []plants.Household
+	err = json.NewDecoder(resp.Body).Decode(&households)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return households, true
+}
+
+// Fetches the household of given identifier, and its invitations if the user of
+// r is an owner, from the API and sends back the household page with the link
+// of the invitation just created, if any.
+func (e *HandlerEnv) executeHouseholdTemplate(
+	w http.ResponseWriter,
+	r *http.Request,
+	id int,
+	link string,
+) {
+	path := householdsUrl + strconv.Itoa(id) + "/"
+	resp, err := e.apiGet(r, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return
+	}
+
+	user, _ := currentUser(r)
+	data := householdWithNavBar{
+		Link:   link,
+		UserId: user.Id,
+		Roles:  roles,
+		NavBar: e.navBarFor(r),
+	}
+	err = json.NewDecoder(resp.Body).Decode(&data.Household)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if data.Household.Role == plants.RoleOwner {
+		resp, err := e.apiGet(r, path+"invitations/")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+		err = json.NewDecoder(resp.Body).Decode(&data.Invitations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = e.templates.ExecuteTemplate(w, "household.gohtml", data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
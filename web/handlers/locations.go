@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/url"
+)
+
+var (
+	LocationsRoute      = "/locations/"
+	LocationRoute       = "/locations/{id}/"
+	DeleteLocationRoute = "/locations/{id}/delete/"
+	MovePlantRoute      = "/plants/move/{id}/"
+	locationsUrl        = "/locations/"
+)
+
+// Labels of the location kinds, displayed by the pages.
+var locationKindLabels = map[plants.LocationKind]string{
+	plants.LocationSite:  "Site",
+	plants.LocationRoom:  "Pièce",
+	plants.LocationBed:   "Parcelle",
+	plants.LocationShelf: "Étagère",
+}
+
+// Encapsulates the locations and the households of the user, the location
+// kinds and the nav bar. Used by the locations page template.
+type locationsWithNavBar struct {
+	Locations  []plants.Location
+	Households []plants.Household
+	Kinds      []plants.LocationKind
+	NavBar     navBarLinks
+}
+
+// Returns the label of location kind k, or its name if it has no label.
+func locationKindLabel(k plants.LocationKind) string {
+	label, ok := locationKindLabels[k]
+	if !ok {
+		return string(k)
+	}
+	return label
+}
+
+// Returns a handler for the "/locations/" URL.
+// The request method should be GET or POST. If it is GET, fetches the locations
+// and the households of the user from the API and sends back an html page
+// listing the locations with forms to add, rename and delete them. If it is
+// POST, sends a POST request to the API that creates a location from the
+// "name", "kind", "household-id" and "parent-id" form values, and redirects to
+// the locations page.
+func (e *HandlerEnv) LocationsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			locations, ok := e.getLocations(w, r)
+			if !ok {
+				return
+			}
+			households, ok := e.getHouseholds(w, r)
+			if !ok {
+				return
+			}
+			data := locationsWithNavBar{
+				locations,
+				households,
+				plants.LocationKinds(),
+				e.navBarFor(r),
+			}
+			err := e.templates.ExecuteTemplate(w, "locations.gohtml", data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data := url.Values{}
+			data.Set("name", r.PostForm.Get("name"))
+			data.Set("kind", r.PostForm.Get("kind"))
+			if plants.LocationKind(data.Get("kind")).IsTop() {
+				data.Set("household-id", r.PostForm.Get("household-id"))
+			} else {
+				data.Set("parent-id", r.PostForm.Get("parent-id"))
+			}
+			resp, err := e.apiFormRequest(
+				r,
+				http.MethodPost,
+				locationsUrl,
+				data,
+			)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+
+			http.Redirect(w, r, e.webUrl+LocationsRoute, http.StatusSeeOther)
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/locations/{id}/" URL.
+// The request method should be POST. Sends a PATCH request to the API that
+// renames the location of given identifier after the "name" form value, and
+// redirects to the locations page.
+func (e *HandlerEnv) LocationHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		data := url.Values{}
+		data.Set("name", r.PostForm.Get("name"))
+		path := locationsUrl + r.PathValue("id") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodPatch, path, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		http.Redirect(w, r, e.webUrl+LocationsRoute, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/locations/{id}/delete/" URL.
+// The request method should be POST. Sends a DELETE request to the API that
+// deletes the location of given identifier, and redirects to the locations
+// page.
+func (e *HandlerEnv) DeleteLocationHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := locationsUrl + r.PathValue("id") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, url.Values{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		http.Redirect(w, r, e.webUrl+LocationsRoute, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/plants/move/{id}/" URL.
+// The request method should be POST. Sends a POST request to the API that moves
+// the plant of given identifier to the location of the "location-id" form
+// value, with the "entry" form value as the note of the move, and redirects to
+// the plant's information page.
+func (e *HandlerEnv) MovePlantHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		data := url.Values{}
+		data.Set("location-id", r.PostForm.Get("location-id"))
+		data.Set("entry", r.PostForm.Get("entry"))
+		path := plantsListUrl + "move/" + r.PathValue("id") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodPost, path, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// Fetches the locations of the user of r from the API, ordered by household and
+// path. If it fails, sends an error response and returns false.
+func (e *HandlerEnv) getLocations(
+	w http.ResponseWriter,
+	r *http.Request,
+) ([]plants.Location, bool) {
+	resp, err := e.apiGet(r, locationsUrl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return nil, false
+	}
+
+	var locations []plants.Location
+	err = json.NewDecoder(resp.Body).Decode(&locations)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return locations, true
+}
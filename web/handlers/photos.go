@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"log"
+	"net/http"
+)
+
+var (
+	AddPhotoRoute    = "/plants/photos/{id}/"
+	PhotoRoute       = "/photos/{id}/{photoId}/"
+	ThumbnailRoute   = "/photos/{id}/{photoId}/thumbnail/"
+	DeletePhotoRoute = "/plants/photos/delete/{id}/{photoId}/"
+	photosUrl        = "/plants/photos/"
+	// Maximum size of an upload forwarded to the API, a bit more than the
+	// maximum size of a photo accepted by the API.
+	maxUploadSize int64 = 11 << 20
+)
+
+// Headers of the API response sending a photo that are forwarded to clients.
+var photoHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Cache-Control",
+	"Content-Security-Policy",
+	"X-Content-Type-Options",
+}
+
+// Returns a handler for the "/plants/photos/{id}/" URL.
+// The request method should be POST. Forwards the multipart form of the request,
+// holding the "photo" file, its "caption" and the optional "log-id" of the log
+// entry it illustrates, to the API that adds the photo to the plant of given
+// identifier. Redirects to the page of the plant on success.
+func (e *HandlerEnv) AddPhotoHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		// The form is streamed to the API, which checks its content
+		req, err := http.NewRequestWithContext(
+			r.Context(),
+			http.MethodPost,
+			e.apiUrl+photosUrl+r.PathValue("id")+"/",
+			http.MaxBytesReader(w, r.Body, maxUploadSize),
+		)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+		e.setApiHeaders(req, r)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/photos/{id}/{photoId}/" URL.
+// The request method should be GET. Fetches the image of the photo of
+// identifier photoId of the plant of identifier id from the API and sends it
+// back.
+func (e *HandlerEnv) PhotoHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		e.forwardImage(
+			w,
+			r,
+			photosUrl+r.PathValue("id")+"/"+r.PathValue("photoId")+"/",
+		)
+	}
+}
+
+// Returns a handler for the "/photos/{id}/{photoId}/thumbnail/" URL.
+// The request method should be GET. Fetches the thumbnail of the photo of
+// identifier photoId of the plant of identifier id from the API and sends it
+// back.
+func (e *HandlerEnv) ThumbnailHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		e.forwardImage(
+			w,
+			r,
+			photosUrl+r.PathValue("id")+"/"+r.PathValue("photoId")+
+				"/thumbnail/",
+		)
+	}
+}
+
+// Fetches the image at given path of the API, on behalf of the user of r, and
+// sends it back with the headers of photoHeaders.
+func (e *HandlerEnv) forwardImage(
+	w http.ResponseWriter,
+	r *http.Request,
+	path string,
+) {
+	resp, err := e.apiGet(r, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return
+	}
+
+	for _, header := range photoHeaders {
+		if v := resp.Header.Get(header); v != "" {
+			w.Header().Set(header, v)
+		}
+	}
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		log.Printf("handlers: %v", err)
+	}
+}
+
+// Returns a handler for the "/plants/photos/delete/{id}/{photoId}/" URL.
+// The request method should be POST. Sends a DELETE request to the API that
+// deletes the photo of identifier photoId of the plant of identifier id, then
+// redirects to the page of the plant.
+func (e *HandlerEnv) DeletePhotoHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := photosUrl + r.PathValue("id") + "/" + r.PathValue("photoId") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// Fetches the metadata of the photos of the plant of given identifier from the
+// API, on behalf of the user of r. If it fails, sends an error response and
+// returns false.
+func (e *HandlerEnv) getPhotos(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+) ([]plants.Photo, bool) {
+	resp, err := e.apiGet(r, photosUrl+id+"/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return nil, false
+	}
+
+	var photos []plants.Photo
+	err = json.NewDecoder(resp.Body).Decode(&photos)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return photos, true
+}
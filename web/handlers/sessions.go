@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	LoginRoute      = "/login/"
+	LogoutRoute     = "/logout/"
+	SignupRoute     = "/signup/"
+	usersUrl        = "/users/"
+	sessionCookie   = "hortus_session"
+	sessionLifetime = 7 * 24 * time.Hour
+	// Header holding the identifier of the user on whose behalf a request is
+	// sent to the API.
+	userHeader = "X-Hortus-User"
+)
+
+// Logged in user of a session.
+type session struct {
+	user    plants.User
+	expires time.Time
+}
+
+// Sessions of the web server, by session token. Sessions are kept in memory and
+// are lost when the server restarts.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+// Key of the user of the session of a request in its context.
+type userKey struct{}
+
+// Encapsulates the values of the login and signup forms and the error of their
+// last submission. Used by the login and signup page templates.
+type loginForm struct {
+	Username string
+	Error    string
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]session)}
+}
+
+// Creates a session for the user and returns its token. The expired sessions
+// are removed.
+func (s *sessionStore) create(user plants.User) (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for t, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = session{user, now.Add(sessionLifetime)}
+	return token, nil
+}
+
+// Returns the user of the session of given token, and false if there is no
+// such session or if it has expired.
+func (s *sessionStore) get(token string) (plants.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok {
+		return plants.User{}, false
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, token)
+		return plants.User{}, false
+	}
+	return sess.user, true
+}
+
+func (s *sessionStore) delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// Returns the user of the session of r, and false if r has no valid session.
+func (e *HandlerEnv) sessionUser(r *http.Request) (plants.User, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return plants.User{}, false
+	}
+	return e.sessions.get(c.Value)
+}
+
+// Returns the user of the session of r, stored in its context by RequireLogin,
+// and false if there is none.
+func currentUser(r *http.Request) (plants.User, bool) {
+	user, ok := r.Context().Value(userKey{}).(plants.User)
+	return user, ok
+}
+
+// Sets the headers of req, a request to the API: the service token of the web
+// server, and the user header to the identifier of the user of r, if any.
+func (e *HandlerEnv) setApiHeaders(req *http.Request, r *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+e.apiToken)
+	if user, ok := currentUser(r); ok {
+		req.Header.Set(userHeader, strconv.Itoa(user.Id))
+	}
+}
+
+// Returns a handler calling h with the user of the session of the request in
+// its context. Requests without a valid session are redirected to the login
+// page.
+func (e *HandlerEnv) RequireLogin(
+	h func(http.ResponseWriter, *http.Request),
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := e.sessionUser(r)
+		if !ok {
+			http.Redirect(w, r, e.webUrl+LoginRoute, http.StatusSeeOther)
+			return
+		}
+		ctx := context.WithValue(r.Context(), userKey{}, user)
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// Returns a handler for the "/login/" URL.
+// The request method should be GET or POST. If it is GET, sends back the login
+// page. If it is POST, checks the "username" and "password" form values with
+// the API, then opens a session and redirects to the index page. If they do not
+// match, sends back the login page with an error message.
+func (e *HandlerEnv) LoginHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			e.executeLoginTemplate(w, "login.gohtml", loginForm{}, http.StatusOK)
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			e.login(w, r, r.PostForm, "login.gohtml")
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/signup/" URL.
+// The request method should be GET or POST. If it is GET, sends back the signup
+// page. If it is POST, sends a POST request to the API that creates a user
+// account from the "username" and "password" form values, then logs in as this
+// user. If the API refuses the account, sends back the signup page with the
+// error message of the API.
+func (e *HandlerEnv) SignupHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			e.executeLoginTemplate(w, "signup.gohtml", loginForm{}, http.StatusOK)
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if r.PostForm.Get("password") != r.PostForm.Get("confirm") {
+				form := loginForm{
+					r.PostForm.Get("username"),
+					"Les mots de passe ne correspondent pas.",
+				}
+				e.executeLoginTemplate(
+					w,
+					"signup.gohtml",
+					form,
+					http.StatusBadRequest,
+				)
+				return
+			}
+
+			data := url.Values{}
+			data.Set("username", r.PostForm.Get("username"))
+			data.Set("password", r.PostForm.Get("password"))
+			resp, err := e.apiFormRequest(r, http.MethodPost, usersUrl, data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				var body apiError
+				json.NewDecoder(resp.Body).Decode(&body)
+				form := loginForm{data.Get("username"), body.Error}
+				e.executeLoginTemplate(w, "signup.gohtml", form, resp.StatusCode)
+				return
+			}
+			if forwardApiError(w, resp) {
+				return
+			}
+			e.login(w, r, data, "signup.gohtml")
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Returns a handler for the "/logout/" URL.
+// The request method should be POST. Closes the session of the request and
+// redirects to the login page.
+func (e *HandlerEnv) LogoutHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			e.sessions.delete(c.Value)
+		}
+		http.SetCookie(w, e.sessionCookie("", -1))
+		http.Redirect(w, r, e.webUrl+LoginRoute, http.StatusSeeOther)
+	}
+}
+
+// Checks the "username" and "password" values of data with the API, then opens
+// a session, sets its cookie and redirects to the index page. If they do not
+// match, sends back the template of given name with an error message.
+func (e *HandlerEnv) login(
+	w http.ResponseWriter,
+	r *http.Request,
+	data url.Values,
+	name string,
+) {
+	form := url.Values{}
+	form.Set("username", data.Get("username"))
+	form.Set("password", data.Get("password"))
+	resp, err := e.apiFormRequest(r, http.MethodPost, usersUrl+"login/", form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		e.executeLoginTemplate(
+			w,
+			name,
+			loginForm{form.Get("username"), "Identifiants invalides."},
+			http.StatusUnauthorized,
+		)
+		return
+	}
+	if forwardApiError(w, resp) {
+		return
+	}
+
+	var user plants.User
+	err = json.NewDecoder(resp.Body).Decode(&user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token, err := e.sessions.create(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, e.sessionCookie(token, int(sessionLifetime.Seconds())))
+	http.Redirect(w, r, e.webUrl+IndexRoute, http.StatusSeeOther)
+}
+
+// Returns the session cookie of given token and max age. The cookie is only
+// sent over https if the web server is served over https.
+func (e *HandlerEnv) sessionCookie(token string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(e.webUrl, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Sends back the login or signup template of given name, with given status
+// code.
+func (e *HandlerEnv) executeLoginTemplate(
+	w http.ResponseWriter,
+	name string,
+	form loginForm,
+	code int,
+) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	err := e.templates.ExecuteTemplate(w, name, form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"io"
+	"log"
+	"net/http"
+)
+
+var (
+	ImportPlantsRoute = "/plants/import/"
+	ExportPlantsRoute = "/plants/export/"
+	ExportLogsRoute   = "/plants/export/logs/"
+	// Maximum size of a spreadsheet forwarded to the API, a bit more than the
+	// maximum size of a spreadsheet accepted by the API.
+	maxImportUploadSize int64 = 2 << 20
+)
+
+// Headers of the API response sending a spreadsheet that are forwarded to
+// clients.
+var spreadsheetHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Disposition",
+}
+
+// Encapsulates the households of the user, the report of the last import, if
+// any, and the nav bar links.
+type importWithNavBar struct {
+	Households []plants.Household
+	Report     *plants.ImportReport
+	NavBar     navBarLinks
+}
+
+// Returns a handler for the "/plants/import/" URL.
+// The request method should be GET or POST. If it is GET, sends back an html
+// page with links to export the plants and their log entries, and a form to
+// import plants from a spreadsheet in one of the households of the user in
+// which the user can add plants. If it is POST, forwards the multipart form of
+// the request, holding the "file" of the spreadsheet, the "household-id", the
+// names of the columns of the fields of the plants and "dry-run", to the API
+// that imports the plants, and sends back the page with the report of the
+// import.
+func (e *HandlerEnv) ImportPlantsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var report *plants.ImportReport
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			// The form is streamed to the API, which checks its content
+			req, err := http.NewRequestWithContext(
+				r.Context(),
+				http.MethodPost,
+				e.apiUrl+ImportPlantsRoute,
+				http.MaxBytesReader(w, r.Body, maxImportUploadSize),
+			)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+			e.setApiHeaders(req, r)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			// Invalid rows are reported along with the valid ones
+			if resp.StatusCode != http.StatusUnprocessableEntity &&
+				forwardApiError(w, resp) {
+				return
+			}
+
+			var body struct {
+				plants.ImportReport
+				Error string `json:"error"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if body.Error != "" {
+				http.Error(w, body.Error, resp.StatusCode)
+				return
+			}
+			report = &body.ImportReport
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		households, ok := e.getHouseholds(w, r)
+		if !ok {
+			return
+		}
+		data := importWithNavBar{households, report, e.navBarFor(r)}
+		err := e.templates.ExecuteTemplate(w, "importPlants.gohtml", data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Returns a handler for the "/plants/export/" URL.
+// The request method should be GET. Fetches the plants of the user from the API
+// as a CSV file and sends it back.
+func (e *HandlerEnv) ExportPlantsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		e.forwardSpreadsheet(w, r, ExportPlantsRoute)
+	}
+}
+
+// Returns a handler for the "/plants/export/logs/" URL.
+// The request method should be GET. Fetches the log entries of the plants of
+// the user from the API as a CSV file and sends it back.
+func (e *HandlerEnv) ExportLogsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		e.forwardSpreadsheet(w, r, ExportLogsRoute)
+	}
+}
+
+// Fetches the spreadsheet at given path of the API, on behalf of the user of r,
+// and sends it back with the headers of spreadsheetHeaders.
+func (e *HandlerEnv) forwardSpreadsheet(
+	w http.ResponseWriter,
+	r *http.Request,
+	path string,
+) {
+	resp, err := e.apiGet(r, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return
+	}
+
+	for _, header := range spreadsheetHeaders {
+		if v := resp.Header.Get(header); v != "" {
+			w.Header().Set(header, v)
+		}
+	}
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		log.Printf("handlers: %v", err)
+	}
+}
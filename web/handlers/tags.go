@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/url"
+)
+
+var (
+	AddTagsRoute   = "/plants/tags/{id}/"
+	RemoveTagRoute = "/plants/tags/delete/{id}/{tag}/"
+	tagsUrl        = "/tags/"
+	plantTagsUrl   = "/plants/tags/"
+	// Font sizes, in percent, of the least and of the most used tags of the tag
+	// cloud.
+	minTagSize = 80
+	maxTagSize = 200
+)
+
+// Tag of the tag cloud of the index page, displayed with a font size growing
+// with the number of plants having it.
+type cloudTag struct {
+	Name  string
+	Count int
+	Size  int
+}
+
+// Returns a handler for the "/plants/tags/{id}/" URL.
+// The request method should be POST. Sends a POST request to the API that adds
+// the tags of the "tag" form value, separated by commas, to the plant of given
+// identifier, and redirects to the plant's information page.
+func (e *HandlerEnv) AddTagsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		data := url.Values{}
+		data.Set("tag", r.PostForm.Get("tag"))
+		path := plantTagsUrl + r.PathValue("id") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodPost, path, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// Returns a handler for the "/plants/tags/delete/{id}/{tag}/" URL.
+// The request method should be POST. Sends a DELETE request to the API that
+// removes the tag from the plant of given identifier, and redirects to the
+// plant's information page.
+func (e *HandlerEnv) RemoveTagHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := plantTagsUrl + r.PathValue("id") + "/" +
+			url.PathEscape(r.PathValue("tag")) + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, url.Values{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		url := e.webUrl + plantsListUrl + r.PathValue("id") + "/"
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// Fetches the tags of the plants of the user of r from the API and returns
+// them as a tag cloud, in alphabetical order. If it fails, sends an error
+// response and returns false.
+func (e *HandlerEnv) getTagCloud(
+	w http.ResponseWriter,
+	r *http.Request,
+) ([]cloudTag, bool) {
+	resp, err := e.apiGet(r, tagsUrl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return nil, false
+	}
+
+	var counts []plants.TagCount
+	err = json.NewDecoder(resp.Body).Decode(&counts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	most := 1
+	for _, c := range counts {
+		most = max(most, c.Count)
+	}
+	cloud := make([]cloudTag, len(counts))
+	for i, c := range counts {
+		cloud[i] = cloudTag{
+			c.Name,
+			c.Count,
+			minTagSize + (maxTagSize-minTagSize)*(c.Count-1)/max(most-1, 1),
+		}
+	}
+	return cloud, true
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"github.com/mgmu/hortus/internal/plants"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	TaxonNamesRoute = "/taxa/names/"
+	CheckTaxonRoute = "/taxa/check/"
+	taxaUrl         = "/taxa/"
+)
+
+// Returns a handler for the "/taxa/names/" URL.
+// The request method should be GET. Fetches from the API the genera or the
+// species of the catalog of taxa completing the "genus" and "species" query
+// values, and sends them back as json. Used by the forms naming plants.
+func (e *HandlerEnv) TaxonNamesHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		e.forwardTaxa(w, r, taxaUrl+"names/")
+	}
+}
+
+// Returns a handler for the "/taxa/check/" URL.
+// The request method should be GET. Checks with the API whether the catalog of
+// taxa has the genus and the species of the "genus" and "species" query values,
+// and sends back the result, with the names they may be a misspelling of, as
+// json. Used by the forms naming plants.
+func (e *HandlerEnv) CheckTaxonHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		e.forwardTaxa(w, r, taxaUrl+"check/")
+	}
+}
+
+// Fetches the json response of the API at given path, with the "genus" and
+// "species" query values of r, and sends it back.
+func (e *HandlerEnv) forwardTaxa(
+	w http.ResponseWriter,
+	r *http.Request,
+	path string,
+) {
+	query := url.Values{}
+	for _, key := range []string{"genus", "species"} {
+		if r.URL.Query().Has(key) {
+			query.Set(key, r.URL.Query().Get(key))
+		}
+	}
+	resp, err := e.apiGet(r, path+"?"+query.Encode())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+	if forwardApiError(w, resp) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		log.Printf("handlers: %v", err)
+	}
+}
+
+// Returns the botanical name of t as html, its Latin names in italics, see
+// plants.Taxon.NameParts.
+func botanicalName(t plants.Taxon) template.HTML {
+	var b strings.Builder
+	for i, part := range t.NameParts() {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		text := template.HTMLEscapeString(part.Text)
+		if part.Italic {
+			text = "<i>" + text + "</i>"
+		}
+		b.WriteString(text)
+	}
+	return template.HTML(b.String())
+}
+
+// Checks the "generic-name" and "specific-name" form values of r against the
+// rules of botanical nomenclature, see plants.NormalizeTaxon, before sending
+// them to the API. Names equal to the ones of current, the plant being edited,
+// are not checked again, as the API keeps them. If they are not valid, sends an
+// error response and returns false.
+func checkPlantNames(
+	w http.ResponseWriter,
+	r *http.Request,
+	current plants.Plant,
+) bool {
+	gen := strings.TrimSpace(r.PostForm.Get("generic-name"))
+	spe := strings.TrimSpace(r.PostForm.Get("specific-name"))
+	if gen == "" && spe == "" {
+		return true
+	}
+	if gen == current.GenericName && spe == current.SpecificName {
+		return true
+	}
+	_, err := plants.NormalizeTaxon(plants.Taxon{Genus: gen, Species: spe})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
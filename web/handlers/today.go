@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/url"
+)
+
+var (
+	TodayRoute        = "/today/"
+	CompleteTaskRoute = "/today/{id}/{scheduleId}/"
+	tasksUrl          = "/tasks/"
+)
+
+// Encapsulates the care tasks due today, grouped by event type, and the nav
+// bar. Used by the today page template.
+type taskGroupsWithNavBar struct {
+	Groups []taskGroup
+	NavBar navBarLinks
+}
+
+// Care tasks of the same event type.
+type taskGroup struct {
+	EventType plants.EventType
+	Tasks     []plants.CareTask
+}
+
+// Returns a handler for the "/today/" URL.
+// The request method should be GET. Fetches from the API the care tasks of all
+// the plants that are due today or overdue, and sends back an html page listing
+// them grouped by event type. Each task has a checkbox completing it, see
+// CompleteTaskHandler.
+func (e *HandlerEnv) TodayHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp, err := e.apiGet(r, tasksUrl)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		var tasks []plants.CareTask
+		err = json.NewDecoder(resp.Body).Decode(&tasks)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data := taskGroupsWithNavBar{groupTasks(tasks), e.navBarFor(r)}
+		err = e.templates.ExecuteTemplate(w, "today.gohtml", data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Returns a handler for the "/today/{id}/{scheduleId}/" URL.
+// The request method should be POST. Sends a POST request to the API that
+// completes the care task of the schedule of identifier scheduleId of the plant
+// of identifier id, adding an entry described by the "entry" form value to the
+// log of the plant. Redirects to the today page on success.
+func (e *HandlerEnv) CompleteTaskHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		path := "/plants/tasks/" + r.PathValue("id") + "/" +
+			r.PathValue("scheduleId") + "/"
+		data := url.Values{"entry": {r.PostForm.Get("entry")}}
+		resp, err := e.apiFormRequest(r, http.MethodPost, path, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		http.Redirect(w, r, e.webUrl+TodayRoute, http.StatusSeeOther)
+	}
+}
+
+// Groups tasks by event type, in the order of plants.EventTypes. The order of
+// the tasks of a group is kept.
+func groupTasks(tasks []plants.CareTask) []taskGroup {
+	groups := []taskGroup{}
+	for _, event := range plants.EventTypes() {
+		group := taskGroup{EventType: event}
+		for _, t := range tasks {
+			if t.EventType == event {
+				group.Tasks = append(group.Tasks, t)
+			}
+		}
+		if len(group.Tasks) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/mgmu/hortus/internal/plants"
+	"net/http"
+	"net/url"
+)
+
+var (
+	TokensRoute      = "/tokens/"
+	RevokeTokenRoute = "/tokens/revoke/{id}/"
+	tokensUrl        = "/tokens/"
+)
+
+// Encapsulates the API tokens of the user, the secret of the token just issued
+// if any, and the nav bar. Used by the tokens page template.
+type tokensWithNavBar struct {
+	Tokens []plants.Token
+	Secret string
+	NavBar navBarLinks
+}
+
+// Returns a handler for the "/tokens/" URL.
+// The request method should be GET or POST. If it is GET, fetches the API tokens
+// of the user from the API and sends back an html page listing them. If it is
+// POST, sends a POST request to the API that issues a token named by the "name"
+// form value, of the scope given by the "scope" form value, and sends back the
+// page with the secret of the new token, which is only shown once.
+func (e *HandlerEnv) TokensHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var secret string
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data := url.Values{}
+			data.Set("name", r.PostForm.Get("name"))
+			data.Set("scope", r.PostForm.Get("scope"))
+			resp, err := e.apiFormRequest(r, http.MethodPost, tokensUrl, data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer resp.Body.Close()
+			if forwardApiError(w, resp) {
+				return
+			}
+			var issued struct {
+				Secret string `json:"token"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&issued)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			secret = issued.Secret
+		default:
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp, err := e.apiGet(r, tokensUrl)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		var tokens []plants.Token
+		err = json.NewDecoder(resp.Body).Decode(&tokens)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data := tokensWithNavBar{tokens, secret, e.navBarFor(r)}
+		err = e.templates.ExecuteTemplate(w, "tokens.gohtml", data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Returns a handler for the "/tokens/revoke/{id}/" URL.
+// The request method should be POST. Sends a DELETE request to the API that
+// revokes the API token of given identifier, and redirects to the tokens page.
+func (e *HandlerEnv) RevokeTokenHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, notAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := tokensUrl + r.PathValue("id") + "/"
+		resp, err := e.apiFormRequest(r, http.MethodDelete, path, url.Values{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		if forwardApiError(w, resp) {
+			return
+		}
+
+		http.Redirect(w, r, e.webUrl+TokensRoute, http.StatusSeeOther)
+	}
+}
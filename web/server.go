@@ -27,17 +27,145 @@ func main() {
 		os.Exit(1)
 	}
 
+	hortusApiToken := os.Getenv("HORTUS_API_TOKEN")
+	if hortusApiToken == "" {
+		fmt.Fprintf(os.Stderr, "No token provided for Hortus API\n")
+		os.Exit(1)
+	}
+
 	hortusWeb := protocol + "://" + hortusWebIp + ":" + webPort
 	hortusApi := protocol + "://" + hortusApiIp + ":" + apiPort
-	env, err := handlers.New(hortusWeb, hortusApi)
+	env, err := handlers.New(hortusWeb, hortusApi, hortusApiToken)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
-	http.HandleFunc(handlers.IndexRoute, env.IndexHandler())
-	http.HandleFunc(handlers.NewPlantRoute, env.NewPlantHandler())
-	http.HandleFunc(handlers.PlantInfoRoute, env.PlantInfoHandler())
-	http.HandleFunc(handlers.NewPlantLogRoute, env.NewPlantLogHandler())
+	http.HandleFunc(handlers.IndexRoute, env.RequireLogin(env.IndexHandler()))
+	http.HandleFunc(
+		handlers.NewPlantRoute,
+		env.RequireLogin(env.NewPlantHandler()),
+	)
+	http.HandleFunc(
+		handlers.PlantInfoRoute,
+		env.RequireLogin(env.PlantInfoHandler()),
+	)
+	http.HandleFunc(
+		handlers.NewPlantLogRoute,
+		env.RequireLogin(env.NewPlantLogHandler()),
+	)
+	http.HandleFunc(
+		handlers.EditPlantRoute,
+		env.RequireLogin(env.EditPlantHandler()),
+	)
+	http.HandleFunc(
+		handlers.DeletePlantRoute,
+		env.RequireLogin(env.DeletePlantHandler()),
+	)
+	http.HandleFunc(
+		handlers.EditLogRoute,
+		env.RequireLogin(env.EditPlantLogHandler()),
+	)
+	http.HandleFunc(
+		handlers.DeleteLogRoute,
+		env.RequireLogin(env.DeletePlantLogHandler()),
+	)
+	http.HandleFunc(handlers.SearchRoute, env.RequireLogin(env.SearchHandler()))
+	http.HandleFunc(handlers.TodayRoute, env.RequireLogin(env.TodayHandler()))
+	http.HandleFunc(
+		handlers.AddPhotoRoute,
+		env.RequireLogin(env.AddPhotoHandler()),
+	)
+	http.HandleFunc(handlers.PhotoRoute, env.RequireLogin(env.PhotoHandler()))
+	http.HandleFunc(
+		handlers.ThumbnailRoute,
+		env.RequireLogin(env.ThumbnailHandler()),
+	)
+	http.HandleFunc(
+		handlers.DeletePhotoRoute,
+		env.RequireLogin(env.DeletePhotoHandler()),
+	)
+	http.HandleFunc(
+		handlers.CompleteTaskRoute,
+		env.RequireLogin(env.CompleteTaskHandler()),
+	)
+	http.HandleFunc(handlers.TokensRoute, env.RequireLogin(env.TokensHandler()))
+	http.HandleFunc(
+		handlers.RevokeTokenRoute,
+		env.RequireLogin(env.RevokeTokenHandler()),
+	)
+	http.HandleFunc(
+		handlers.HouseholdsRoute,
+		env.RequireLogin(env.HouseholdsHandler()),
+	)
+	http.HandleFunc(
+		handlers.HouseholdRoute,
+		env.RequireLogin(env.HouseholdHandler()),
+	)
+	http.HandleFunc(
+		handlers.DeleteHouseholdRoute,
+		env.RequireLogin(env.DeleteHouseholdHandler()),
+	)
+	http.HandleFunc(handlers.MemberRoute, env.RequireLogin(env.MemberHandler()))
+	http.HandleFunc(
+		handlers.RemoveMemberRoute,
+		env.RequireLogin(env.RemoveMemberHandler()),
+	)
+	http.HandleFunc(
+		handlers.InvitationsRoute,
+		env.RequireLogin(env.InvitationsHandler()),
+	)
+	http.HandleFunc(
+		handlers.DeleteInvitationRoute,
+		env.RequireLogin(env.DeleteInvitationHandler()),
+	)
+	http.HandleFunc(handlers.JoinRoute, env.RequireLogin(env.JoinHandler()))
+	http.HandleFunc(
+		handlers.LocationsRoute,
+		env.RequireLogin(env.LocationsHandler()),
+	)
+	http.HandleFunc(
+		handlers.LocationRoute,
+		env.RequireLogin(env.LocationHandler()),
+	)
+	http.HandleFunc(
+		handlers.DeleteLocationRoute,
+		env.RequireLogin(env.DeleteLocationHandler()),
+	)
+	http.HandleFunc(
+		handlers.MovePlantRoute,
+		env.RequireLogin(env.MovePlantHandler()),
+	)
+	http.HandleFunc(
+		handlers.AddTagsRoute,
+		env.RequireLogin(env.AddTagsHandler()),
+	)
+	http.HandleFunc(
+		handlers.RemoveTagRoute,
+		env.RequireLogin(env.RemoveTagHandler()),
+	)
+	http.HandleFunc(
+		handlers.TaxonNamesRoute,
+		env.RequireLogin(env.TaxonNamesHandler()),
+	)
+	http.HandleFunc(
+		handlers.CheckTaxonRoute,
+		env.RequireLogin(env.CheckTaxonHandler()),
+	)
+	http.HandleFunc(
+		handlers.ImportPlantsRoute,
+		env.RequireLogin(env.ImportPlantsHandler()),
+	)
+	http.HandleFunc(
+		handlers.ExportPlantsRoute,
+		env.RequireLogin(env.ExportPlantsHandler()),
+	)
+	http.HandleFunc(
+		handlers.ExportLogsRoute,
+		env.RequireLogin(env.ExportLogsHandler()),
+	)
+	http.HandleFunc(handlers.LoginRoute, env.LoginHandler())
+	http.HandleFunc(handlers.SignupRoute, env.SignupHandler())
+	http.HandleFunc(handlers.LogoutRoute, env.LogoutHandler())
 
 	err = http.ListenAndServe(":8081", nil)
 	fmt.Fprintf(os.Stderr, "ListenAndServe: %v\n", err)